@@ -0,0 +1,51 @@
+package common
+
+import (
+	"github.com/gin-gonic/gin"
+	"go-web-mini/config"
+	"go-web-mini/util"
+)
+
+// 当前请求的集成方API Key在gin context中的key
+const ApiKeyContextKey = "apiKey"
+
+// 获取当前请求的集成方API Key, 不是集成方请求时返回nil
+func GetApiKey(c *gin.Context) *config.ApiKeyConfig {
+	if v, exists := c.Get(ApiKeyContextKey); exists {
+		return v.(*config.ApiKeyConfig)
+	}
+	return nil
+}
+
+// 获取当前请求的集成方允许获取的用户字段, 不是集成方请求或未限制时返回nil
+func GetApiKeyUserFields(c *gin.Context) []string {
+	if apiKey := GetApiKey(c); apiKey != nil {
+		return apiKey.UserFields
+	}
+	return nil
+}
+
+// 当前请求的API Key与登录用户是否匹配
+// 绑定了集成方账号的API Key只能由这些账号使用, 集成方账号的请求也必须带上绑定的API Key(不能去掉API Key获取全部字段)
+func ApiKeyMatchesUser(c *gin.Context, username string) bool {
+	username = util.NormalizeUsername(username)
+	if apiKey := GetApiKey(c); apiKey != nil && len(apiKey.Usernames) > 0 {
+		return apiKeyBoundTo(apiKey, username)
+	}
+	for _, apiKey := range config.Conf.Security.ApiKeys {
+		if apiKeyBoundTo(apiKey, username) {
+			return false
+		}
+	}
+	return true
+}
+
+// API Key是否绑定了该集成方账号
+func apiKeyBoundTo(apiKey *config.ApiKeyConfig, username string) bool {
+	for _, name := range apiKey.Usernames {
+		if util.NormalizeUsername(name) == username {
+			return true
+		}
+	}
+	return false
+}
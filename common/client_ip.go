@@ -0,0 +1,67 @@
+package common
+
+import (
+	"github.com/gin-gonic/gin"
+	"go-web-mini/config"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// 获取客户端IP
+// 只有连接的远端地址为可信代理(system.trusted-proxies)时才使用X-Forwarded-For和X-Real-Ip, 否则使用远端地址, 防止客户端伪造请求头
+// X-Forwarded-For从右往左跳过可信代理, 第一个不是可信代理的地址为客户端IP
+func ClientIP(c *gin.Context) string {
+	remoteIp := remoteIP(c.Request)
+	if remoteIp == nil {
+		return strings.TrimSpace(c.Request.RemoteAddr)
+	}
+	if !isTrustedProxy(remoteIp) {
+		return remoteIp.String()
+	}
+	if forwardedFor := c.GetHeader("X-Forwarded-For"); forwardedFor != "" {
+		ips := strings.Split(forwardedFor, ",")
+		for i := len(ips) - 1; i >= 0; i-- {
+			ip := net.ParseIP(strings.TrimSpace(ips[i]))
+			if ip == nil {
+				break
+			}
+			if i == 0 || !isTrustedProxy(ip) {
+				return ip.String()
+			}
+		}
+	}
+	if ip := net.ParseIP(strings.TrimSpace(c.GetHeader("X-Real-Ip"))); ip != nil {
+		return ip.String()
+	}
+	return remoteIp.String()
+}
+
+// 请求是否由可信代理转发(连接的远端地址为可信代理), 只有这时才能信任X-Forwarded-*请求头
+func FromTrustedProxy(r *http.Request) bool {
+	remoteIp := remoteIP(r)
+	return remoteIp != nil && isTrustedProxy(remoteIp)
+}
+
+// 连接的远端IP, 解析失败时返回nil
+func remoteIP(r *http.Request) net.IP {
+	host, _, err := net.SplitHostPort(strings.TrimSpace(r.RemoteAddr))
+	if err != nil {
+		host = strings.TrimSpace(r.RemoteAddr)
+	}
+	return net.ParseIP(host)
+}
+
+// IP是否为可信代理, 可信代理可以配置为IP或CIDR(如10.0.0.0/8)
+func isTrustedProxy(ip net.IP) bool {
+	for _, proxy := range config.Conf.System.TrustedProxies {
+		if strings.Contains(proxy, "/") {
+			if _, ipNet, err := net.ParseCIDR(proxy); err == nil && ipNet.Contains(ip) {
+				return true
+			}
+		} else if proxyIp := net.ParseIP(proxy); proxyIp != nil && proxyIp.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,61 @@
+package common
+
+import (
+	"github.com/gin-gonic/gin"
+	"go-web-mini/config"
+	"net/http/httptest"
+	"testing"
+)
+
+// 创建来自remoteAddr且带有指定请求头的请求上下文
+func newClientIpContext(remoteAddr string, headers map[string]string) *gin.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("GET", "/api/base/login", nil)
+	c.Request.RemoteAddr = remoteAddr
+	for key, value := range headers {
+		c.Request.Header.Set(key, value)
+	}
+	return c
+}
+
+func TestClientIP(t *testing.T) {
+	defer func(system *config.SystemConfig) { config.Conf.System = system }(config.Conf.System)
+	config.Conf.System = &config.SystemConfig{TrustedProxies: []string{"10.0.0.0/8", "192.0.2.1"}}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		headers    map[string]string
+		want       string
+	}{
+		{"直接访问", "203.0.113.5:1234", nil, "203.0.113.5"},
+		{"不是可信代理时忽略转发请求头", "203.0.113.5:1234", map[string]string{"X-Forwarded-For": "198.51.100.1", "X-Real-Ip": "198.51.100.2"}, "203.0.113.5"},
+		{"可信代理转发", "192.0.2.1:1234", map[string]string{"X-Forwarded-For": "198.51.100.1"}, "198.51.100.1"},
+		{"跳过多级可信代理", "10.0.0.2:1234", map[string]string{"X-Forwarded-For": "198.51.100.1, 10.0.0.3"}, "198.51.100.1"},
+		{"客户端伪造的地址在最左边", "10.0.0.2:1234", map[string]string{"X-Forwarded-For": "1.1.1.1, 198.51.100.1"}, "198.51.100.1"},
+		{"可信代理使用X-Real-Ip", "192.0.2.1:1234", map[string]string{"X-Real-Ip": "198.51.100.2"}, "198.51.100.2"},
+		{"可信代理没有转发请求头", "192.0.2.1:1234", nil, "192.0.2.1"},
+	}
+	for _, tt := range tests {
+		if got := ClientIP(newClientIpContext(tt.remoteAddr, tt.headers)); got != tt.want {
+			t.Errorf("%s: 客户端IP为%s, 期望为%s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestFromTrustedProxy(t *testing.T) {
+	defer func(system *config.SystemConfig) { config.Conf.System = system }(config.Conf.System)
+	config.Conf.System = &config.SystemConfig{TrustedProxies: []string{"10.0.0.0/8"}}
+
+	if !FromTrustedProxy(newClientIpContext("10.1.2.3:443", nil).Request) {
+		t.Error("来自可信代理网段的请求未被信任")
+	}
+	if FromTrustedProxy(newClientIpContext("203.0.113.5:443", nil).Request) {
+		t.Error("来自其他地址的请求被信任")
+	}
+	// 未配置可信代理时不信任任何地址
+	config.Conf.System = &config.SystemConfig{}
+	if FromTrustedProxy(newClientIpContext("10.1.2.3:443", nil).Request) {
+		t.Error("未配置可信代理时请求被信任")
+	}
+}
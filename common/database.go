@@ -4,13 +4,32 @@ import (
 	"fmt"
 	"go-web-mini/config"
 	"go-web-mini/model"
+	"go-web-mini/util"
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
+	"time"
+	"unicode/utf8"
 )
 
 // 全局mysql数据库变量
 var DB *gorm.DB
 
+// mysql连接池默认配置, 与config.yml中的配置相同
+const (
+	defaultMysqlMaxOpenConns    = 100
+	defaultMysqlMaxIdleConns    = 20
+	defaultMysqlConnMaxLifetime = 3600 // 秒
+	defaultMysqlConnMaxIdleTime = 600  // 秒
+)
+
+// 配置值不大于0时使用默认值
+func positiveOrDefault(value int, defaultValue int) int {
+	if value <= 0 {
+		return defaultValue
+	}
+	return value
+}
+
 // 初始化mysql数据库
 func InitMysql() {
 	dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=%s&collation=%s&%s",
@@ -52,20 +71,116 @@ func InitMysql() {
 	if config.Conf.Mysql.LogMode {
 		db.Debug()
 	}
+	// 设置连接池
+	sqlDB, err := db.DB()
+	if err != nil {
+		Log.Panicf("获取mysql连接池异常: %v", err)
+	}
+	// 配置文件中没有连接池配置(或不大于0)时使用默认值
+	maxOpenConns := positiveOrDefault(config.Conf.Mysql.MaxOpenConns, defaultMysqlMaxOpenConns)
+	maxIdleConns := positiveOrDefault(config.Conf.Mysql.MaxIdleConns, defaultMysqlMaxIdleConns)
+	connMaxLifetime := positiveOrDefault(config.Conf.Mysql.ConnMaxLifetime, defaultMysqlConnMaxLifetime)
+	connMaxIdleTime := positiveOrDefault(config.Conf.Mysql.ConnMaxIdleTime, defaultMysqlConnMaxIdleTime)
+	sqlDB.SetMaxOpenConns(maxOpenConns)
+	sqlDB.SetMaxIdleConns(maxIdleConns)
+	sqlDB.SetConnMaxLifetime(time.Second * time.Duration(connMaxLifetime))
+	sqlDB.SetConnMaxIdleTime(time.Second * time.Duration(connMaxIdleTime))
+	Log.Infof("mysql连接池配置: 最大打开连接数: %d, 最大空闲连接数: %d, 连接最大存活时间: %ds, 空闲连接最大存活时间: %ds",
+		maxOpenConns,
+		maxIdleConns,
+		connMaxLifetime,
+		connMaxIdleTime,
+	)
 	// 全局DB赋值
 	DB = db
 	// 自动迁移表结构
 	dbAutoMigrate()
+	// 规范化之前保存的用户名
+	if err := NormalizeStoredUsernames(DB); err != nil {
+		Log.Panicf("规范化用户名异常: %v", err)
+	}
 	Log.Infof("初始化mysql数据库完成! dsn: %s", showDsn)
 }
 
 // 自动迁移表结构
 func dbAutoMigrate() {
+	// 用户角色使用自定义中间表(包含角色过期时间)
+	if err := DB.SetupJoinTable(&model.User{}, "Roles", &model.UserRole{}); err != nil {
+		Log.Panicf("设置用户角色中间表异常: %v", err)
+	}
+	if err := DB.SetupJoinTable(&model.Role{}, "Users", &model.UserRole{}); err != nil {
+		Log.Panicf("设置用户角色中间表异常: %v", err)
+	}
 	DB.AutoMigrate(
 		&model.User{},
+		&model.UserRole{},
 		&model.Role{},
 		&model.Menu{},
 		&model.Api{},
 		&model.OperationLog{},
+		&model.PolicyChangeLog{},
+		&model.CacheInvalidation{},
+		&model.PasswordHistory{},
 	)
 }
+
+// 规范化之前保存的大小写混合的用户名(去除首尾空白并转为小写), 查询用户名时使用username = ?, 可以使用唯一索引
+// 规范化后重复的用户名(如Admin和admin)只保留一个: 已经是规范化用户名的用户优先, 否则保留ID最小的用户
+// 其余用户改为"用户名_ID", 需要管理员通知这些用户使用新的用户名登录
+func NormalizeStoredUsernames(db *gorm.DB) error {
+	var users []model.User
+	if err := db.Unscoped().Select("id, username").Order("id").Find(&users).Error; err != nil {
+		return err
+	}
+	groups := make(map[string][]model.User)
+	for _, user := range users {
+		username := util.NormalizeUsername(user.Username)
+		groups[username] = append(groups[username], user)
+	}
+	renames := make(map[uint]string)
+	for username, group := range groups {
+		keep := 0
+		for i, user := range group {
+			if user.Username == username {
+				keep = i
+				break
+			}
+		}
+		for i, user := range group {
+			newUsername := username
+			if i != keep {
+				suffix := fmt.Sprintf("_%d", user.ID)
+				// 用户名最多20个字符(按字符而不是字节计算, 避免截断多字节字符)
+				if utf8.RuneCountInString(newUsername)+len(suffix) > 20 {
+					newUsername = string([]rune(newUsername)[:20-len(suffix)])
+				}
+				newUsername += suffix
+				Log.Warnf("用户名%s与%s规范化后重复, 用户ID为%d的用户名修改为%s", user.Username, group[keep].Username, user.ID, newUsername)
+			}
+			if newUsername != user.Username {
+				renames[user.ID] = newUsername
+			}
+		}
+	}
+	if len(renames) == 0 {
+		return nil
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		// 先改为临时用户名, 避免规范化后的用户名与还未修改的用户名冲突
+		for id := range renames {
+			err := tx.Unscoped().Model(&model.User{}).Where("id = ?", id).UpdateColumn("username", fmt.Sprintf("#%d", id)).Error
+			if err != nil {
+				return err
+			}
+		}
+		for id, username := range renames {
+			err := tx.Unscoped().Model(&model.User{}).Where("id = ?", id).
+				UpdateColumns(map[string]interface{}{"username": username, "version": gorm.Expr("version + 1")}).Error
+			if err != nil {
+				return err
+			}
+		}
+		Log.Infof("规范化用户名完成, 共修改%d个用户名", len(renames))
+		return nil
+	})
+}
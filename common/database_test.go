@@ -0,0 +1,84 @@
+package common
+
+import (
+	"go-web-mini/model"
+	"go.uber.org/zap"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestNormalizeStoredUsernames(t *testing.T) {
+	Log = zap.NewNop().Sugar()
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("打开测试数据库失败: %v", err)
+	}
+	if err := db.AutoMigrate(&model.User{}); err != nil {
+		t.Fatalf("迁移用户表失败: %v", err)
+	}
+	// sqlite的唯一索引区分大小写, 可以保存规范化之前大小写不同的用户名
+	for _, username := range []string{"Admin", "admin", "Bob", " carol ", "Dave", "DAVE"} {
+		user := model.User{Username: username, Password: "hash", Mobile: "mobile-" + username}
+		if err := db.Create(&user).Error; err != nil {
+			t.Fatalf("创建测试用户失败: %v", err)
+		}
+	}
+
+	if err := NormalizeStoredUsernames(db); err != nil {
+		t.Fatalf("规范化用户名失败: %v", err)
+	}
+	var users []model.User
+	db.Order("id").Find(&users)
+	want := []string{"admin_1", "admin", "bob", "carol", "dave", "dave_6"}
+	for i, user := range users {
+		if user.Username != want[i] {
+			t.Errorf("用户ID为%d的用户名为%s, 期望为%s", user.ID, user.Username, want[i])
+		}
+	}
+	if users[0].Version != 1 || users[1].Version != 0 {
+		t.Errorf("修改用户名后版本号为%d, 未修改的版本号为%d, 期望为1和0", users[0].Version, users[1].Version)
+	}
+
+	// 再次执行不修改任何用户
+	if err := NormalizeStoredUsernames(db); err != nil {
+		t.Fatalf("再次规范化用户名失败: %v", err)
+	}
+	var changed int64
+	db.Model(&model.User{}).Where("version > ?", 1).Count(&changed)
+	if changed != 0 {
+		t.Errorf("再次规范化修改了%d个用户, 期望为0", changed)
+	}
+}
+
+func TestNormalizeStoredUsernamesMultiByte(t *testing.T) {
+	Log = zap.NewNop().Sugar()
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("打开测试数据库失败: %v", err)
+	}
+	if err := db.AutoMigrate(&model.User{}); err != nil {
+		t.Fatalf("迁移用户表失败: %v", err)
+	}
+	// 不超过20个字符但超过20个字节的用户名, 以及20个字符的用户名
+	for _, username := range []string{"张三李四王五赵六a", "张三李四王五赵六A", "一二三四五六七八九十一二三四五六七八九a", "一二三四五六七八九十一二三四五六七八九A"} {
+		user := model.User{Username: username, Password: "hash", Mobile: "mobile-" + username}
+		if err := db.Create(&user).Error; err != nil {
+			t.Fatalf("创建测试用户失败: %v", err)
+		}
+	}
+
+	if err := NormalizeStoredUsernames(db); err != nil {
+		t.Fatalf("规范化用户名失败: %v", err)
+	}
+	var users []model.User
+	db.Order("id").Find(&users)
+	want := []string{"张三李四王五赵六a", "张三李四王五赵六a_2", "一二三四五六七八九十一二三四五六七八九a", "一二三四五六七八九十一二三四五六七八_4"}
+	for i, user := range users {
+		if user.Username != want[i] || !utf8.ValidString(user.Username) {
+			t.Errorf("用户ID为%d的用户名为%q, 期望为%s", user.ID, user.Username, want[i])
+		}
+	}
+}
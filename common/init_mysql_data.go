@@ -463,6 +463,265 @@ func InitData() {
 			Desc:     "批量删除操作日志",
 			Creator:  "系统",
 		},
+		{
+			Method:   "POST",
+			Path:     "/user/cache/warm",
+			Category: "user",
+			Desc:     "预热用户信息缓存",
+			Creator:  "系统",
+		},
+		{
+			Method:   "GET",
+			Path:     "/user/role/expiring",
+			Category: "user",
+			Desc:     "获取角色即将过期的用户列表",
+			Creator:  "系统",
+		},
+		{
+			Method:   "PATCH",
+			Path:     "/user/role/expires/:userId",
+			Category: "user",
+			Desc:     "更新用户角色的过期时间",
+			Creator:  "系统",
+		},
+		{
+			Method:   "POST",
+			Path:     "/user/merge",
+			Category: "user",
+			Desc:     "合并用户",
+			Creator:  "系统",
+		},
+		{
+			Method:   "POST",
+			Path:     "/base/password/strength",
+			Category: "base",
+			Desc:     "检测密码强度",
+			Creator:  "系统",
+		},
+		{
+			Method:   "GET",
+			Path:     "/base/serverTime",
+			Category: "base",
+			Desc:     "获取服务器时间",
+			Creator:  "系统",
+		},
+		{
+			Method:   "POST",
+			Path:     "/user/import/validate",
+			Category: "user",
+			Desc:     "校验导入用户数据",
+			Creator:  "系统",
+		},
+		{
+			Method:   "GET",
+			Path:     "/user/import/template",
+			Category: "user",
+			Desc:     "下载导入用户模板",
+			Creator:  "系统",
+		},
+		{
+			Method:   "GET",
+			Path:     "/user/role/available/:userId",
+			Category: "user",
+			Desc:     "获取用户可添加的角色",
+			Creator:  "系统",
+		},
+		{
+			Method:   "POST",
+			Path:     "/user/cache/evict",
+			Category: "user",
+			Desc:     "删除指定用户的用户信息缓存",
+			Creator:  "系统",
+		},
+		{
+			Method:   "GET",
+			Path:     "/role/permissions/:roleId",
+			Category: "role",
+			Desc:     "获取角色的全部有效权限",
+			Creator:  "系统",
+		},
+		{
+			Method:   "GET",
+			Path:     "/log/operation/user/:userId",
+			Category: "log",
+			Desc:     "获取对指定用户的操作记录",
+			Creator:  "系统",
+		},
+		{
+			Method:   "GET",
+			Path:     "/base/setup/status",
+			Category: "base",
+			Desc:     "获取是否需要首次安装初始化",
+			Creator:  "系统",
+		},
+		{
+			Method:   "POST",
+			Path:     "/base/setup",
+			Category: "base",
+			Desc:     "首次安装初始化",
+			Creator:  "系统",
+		},
+		{
+			Method:   "PATCH",
+			Path:     "/role/status/batch",
+			Category: "role",
+			Desc:     "批量修改角色状态",
+			Creator:  "系统",
+		},
+		{
+			Method:   "GET",
+			Path:     "/user/stream",
+			Category: "user",
+			Desc:     "流式导出用户列表",
+			Creator:  "系统",
+		},
+		{
+			Method:   "GET",
+			Path:     "/role/apis/history/:roleId",
+			Category: "role",
+			Desc:     "获取角色权限接口变更记录",
+			Creator:  "系统",
+		},
+		{
+			Method:   "POST",
+			Path:     "/user/batch/check",
+			Category: "user",
+			Desc:     "批量操作权限校验",
+			Creator:  "系统",
+		},
+		{
+			Method:   "GET",
+			Path:     "/user/data/export/:userId",
+			Category: "user",
+			Desc:     "导出用户的全部数据",
+			Creator:  "系统",
+		},
+		{
+			Method:   "GET",
+			Path:     "/role/disable/preview/:roleId",
+			Category: "role",
+			Desc:     "预览禁用角色的影响",
+			Creator:  "系统",
+		},
+		{
+			Method:   "GET",
+			Path:     "/user/export",
+			Category: "user",
+			Desc:     "导出用户列表(CSV)",
+			Creator:  "系统",
+		},
+		{
+			Method:   "POST",
+			Path:     "/user/import",
+			Category: "user",
+			Desc:     "导入用户(xlsx或CSV文件)",
+			Creator:  "系统",
+		},
+		{
+			Method:   "GET",
+			Path:     "/role/users/:roleId",
+			Category: "role",
+			Desc:     "获取拥有该角色的用户列表",
+			Creator:  "系统",
+		},
+		{
+			Method:   "PATCH",
+			Path:     "/user/password/reset/:userId",
+			Category: "user",
+			Desc:     "重置其他用户的密码",
+			Creator:  "系统",
+		},
+		{
+			Method:   "GET",
+			Path:     "/user/trash",
+			Category: "user",
+			Desc:     "获取已删除的用户列表",
+			Creator:  "系统",
+		},
+		{
+			Method:   "PATCH",
+			Path:     "/user/restore/batch",
+			Category: "user",
+			Desc:     "批量恢复已删除的用户",
+			Creator:  "系统",
+		},
+		{
+			Method:   "PATCH",
+			Path:     "/user/status/:userId",
+			Category: "user",
+			Desc:     "更新用户状态",
+			Creator:  "系统",
+		},
+		{
+			Method:   "POST",
+			Path:     "/user/role/assign/batch",
+			Category: "user",
+			Desc:     "批量分配角色",
+			Creator:  "系统",
+		},
+		{
+			Method:   "GET",
+			Path:     "/user/sessions",
+			Category: "user",
+			Desc:     "获取当前用户的登录会话",
+			Creator:  "系统",
+		},
+		{
+			Method:   "DELETE",
+			Path:     "/user/sessions/:tokenId",
+			Category: "user",
+			Desc:     "退出当前用户的指定登录会话",
+			Creator:  "系统",
+		},
+		{
+			Method:   "POST",
+			Path:     "/base/login/2fa",
+			Category: "base",
+			Desc:     "两步验证登录",
+			Creator:  "系统",
+		},
+		{
+			Method:   "POST",
+			Path:     "/user/2fa/enroll",
+			Category: "user",
+			Desc:     "生成两步验证密钥",
+			Creator:  "系统",
+		},
+		{
+			Method:   "POST",
+			Path:     "/user/2fa/verify",
+			Category: "user",
+			Desc:     "开启两步验证",
+			Creator:  "系统",
+		},
+		{
+			Method:   "POST",
+			Path:     "/user/2fa/disable",
+			Category: "user",
+			Desc:     "关闭两步验证",
+			Creator:  "系统",
+		},
+		{
+			Method:   "POST",
+			Path:     "/user/avatar",
+			Category: "user",
+			Desc:     "上传头像",
+			Creator:  "系统",
+		},
+		{
+			Method:   "PATCH",
+			Path:     "/user/profile",
+			Category: "user",
+			Desc:     "更新个人资料",
+			Creator:  "系统",
+		},
+		{
+			Method:   "PATCH",
+			Path:     "/user/batch/status",
+			Category: "user",
+			Desc:     "批量更新用户状态",
+			Creator:  "系统",
+		},
 	}
 	newApi := make([]model.Api, 0)
 	newRoleCasbin := make([]model.RoleCasbin, 0)
@@ -482,9 +741,21 @@ func InitData() {
 			// 非管理员拥有基础权限
 			basePaths := []string{
 				"/base/login",
+				"/base/login/2fa",
 				"/base/logout",
 				"/base/refreshToken",
+				"/base/password/strength",
+				"/base/serverTime",
 				"/user/info",
+				"/user/sessions",
+				"/user/sessions/:tokenId",
+				"/user/avatar",
+				"/user/profile",
+				"/user/2fa/enroll",
+				"/user/2fa/verify",
+				"/user/2fa/disable",
+				"/user/batch/check",
+				"/user/data/export/:userId",
 				"/menu/access/tree/:userId",
 			}
 
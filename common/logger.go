@@ -59,23 +59,21 @@ func InitLogger() {
 		//EncodeName:       nil,
 		//ConsoleSeparator: "",
 	}
+	// 日志格式, json格式方便日志采集系统按字段(如request_id、username)检索
 	encoder := zapcore.NewConsoleEncoder(encoderConfig)
+	if config.Conf.Logs.Format == "json" {
+		encoder = zapcore.NewJSONEncoder(encoderConfig)
+	}
 
-	// 日志级别
+	// 日志级别, 低于yml配置中的等级的日志不记录
+	minLevel := config.Conf.Logs.Level
 	highPriority := zap.LevelEnablerFunc(func(level zapcore.Level) bool {
-		return level >= zap.ErrorLevel
+		return level >= zap.ErrorLevel && level >= minLevel
 	})
 	lowPriority := zap.LevelEnablerFunc(func(level zapcore.Level) bool {
-		return level < zap.ErrorLevel && level >= zap.DebugLevel
+		return level < zap.ErrorLevel && level >= minLevel
 	})
 
-	// 当yml配置中的等级大于Error时，lowPriority级别日志停止记录
-	if config.Conf.Logs.Level >= 2 {
-		lowPriority = zap.LevelEnablerFunc(func(level zapcore.Level) bool {
-			return false
-		})
-	}
-
 	// info文件writeSyncer
 	infoFileWriteSyncer := zapcore.AddSync(&lumberjack.Logger{
 		Filename:   infoLogFileName,             //日志文件存放目录，如果文件夹不存在会自动创建
@@ -0,0 +1,103 @@
+package common
+
+import (
+	"github.com/patrickmn/go-cache"
+	"go-web-mini/config"
+	"go-web-mini/util"
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 登录失败锁定状态
+type loginLockState struct {
+	Failures    int       // 本轮连续失败次数
+	LockCount   int       // 衰减时间内的锁定次数
+	LockedUntil time.Time // 锁定截止时间
+}
+
+// 登录失败锁定缓存, 过期时间为锁定次数的衰减时间
+var (
+	loginLockCache = cache.New(24*time.Hour, time.Hour)
+	loginLockMutex sync.Mutex
+)
+
+// 是否开启登录失败锁定
+func loginLockEnabled() bool {
+	return config.Conf.Security.LoginLock != nil && config.Conf.Security.LoginLock.MaxFailures > 0
+}
+
+// 获取登录锁定剩余时间, 未锁定返回0
+func GetLoginLockRemaining(key string) time.Duration {
+	if !loginLockEnabled() {
+		return 0
+	}
+	loginLockMutex.Lock()
+	defer loginLockMutex.Unlock()
+	if v, found := loginLockCache.Get(key); found {
+		if remaining := time.Until(v.(loginLockState).LockedUntil); remaining > 0 {
+			return remaining
+		}
+	}
+	return 0
+}
+
+// 记录一次登录失败, 达到最大失败次数时锁定并返回锁定时间
+// 衰减时间内每次锁定的时间按倍数递增, 直到最大锁定时间
+func RecordLoginFailure(key string) time.Duration {
+	if !loginLockEnabled() {
+		return 0
+	}
+	lockConf := config.Conf.Security.LoginLock
+	loginLockMutex.Lock()
+	defer loginLockMutex.Unlock()
+
+	var state loginLockState
+	if v, found := loginLockCache.Get(key); found {
+		state = v.(loginLockState)
+	}
+	state.Failures++
+	var lockDuration time.Duration
+	if state.Failures >= lockConf.MaxFailures {
+		state.Failures = 0
+		state.LockCount++
+		seconds := float64(lockConf.BaseSeconds) * math.Pow(math.Max(lockConf.Multiplier, 1), float64(state.LockCount-1))
+		if lockConf.MaxSeconds > 0 && seconds > float64(lockConf.MaxSeconds) {
+			seconds = float64(lockConf.MaxSeconds)
+		}
+		lockDuration = time.Duration(seconds) * time.Second
+		state.LockedUntil = time.Now().Add(lockDuration)
+		Log.Warnf("登录失败次数过多, 锁定[%s] %s, 衰减时间内第%d次锁定", key, lockDuration, state.LockCount)
+	}
+	loginLockCache.Set(key, state, time.Hour*time.Duration(lockConf.DecayHours))
+	return lockDuration
+}
+
+// 清除登录失败记录(登录成功后调用)
+func ResetLoginFailure(key string) {
+	loginLockMutex.Lock()
+	defer loginLockMutex.Unlock()
+	loginLockCache.Delete(key)
+}
+
+// 清除用户在所有IP的登录失败记录和锁定(修改、重置密码后调用)
+// 未开启修改密码后清除时不处理
+func ResetUserLoginFailures(username string) {
+	if config.Conf.Security.LoginLock == nil || !config.Conf.Security.LoginLock.ResetOnPasswordChange {
+		return
+	}
+	loginLockMutex.Lock()
+	defer loginLockMutex.Unlock()
+	prefix := LoginLockKey(username, "")
+	for key := range loginLockCache.Items() {
+		if strings.HasPrefix(key, prefix) {
+			loginLockCache.Delete(key)
+		}
+	}
+}
+
+// 生成登录失败锁定的key(用户名+IP)
+func LoginLockKey(username string, ip string) string {
+	return util.NormalizeUsername(username) + "|" + ip
+}
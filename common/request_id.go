@@ -0,0 +1,61 @@
+package common
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"regexp"
+)
+
+// 请求ID的请求头和响应头
+const RequestIdHeader = "X-Request-Id"
+
+// 请求ID在gin上下文中的key
+const requestIdKey = "requestId"
+
+// 请求ID在请求上下文(context.Context)中的key
+type requestIdContextKey struct{}
+
+// 客户端传入的请求ID格式, 不符合时重新生成, 避免日志注入
+var requestIdPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// 是否为有效的请求ID
+func IsValidRequestId(requestId string) bool {
+	return requestIdPattern.MatchString(requestId)
+}
+
+// 生成请求ID
+func NewRequestId() string {
+	return NewTokenId()
+}
+
+// 保存请求ID到gin上下文和请求上下文中, 传入c.Request.Context()的仓库方法同样可以获取到
+func SetRequestId(c *gin.Context, requestId string) {
+	c.Set(requestIdKey, requestId)
+	c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), requestIdContextKey{}, requestId))
+}
+
+// 从gin上下文中获取请求ID, 没有时返回空字符串
+func GetRequestId(c *gin.Context) string {
+	return c.GetString(requestIdKey)
+}
+
+// 从上下文中获取请求ID, 支持gin上下文和请求上下文, 没有时返回空字符串
+func RequestIdFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	if c, ok := ctx.(*gin.Context); ok {
+		return GetRequestId(c)
+	}
+	requestId, _ := ctx.Value(requestIdContextKey{}).(string)
+	return requestId
+}
+
+// 带有请求ID字段的日志, 上下文中没有请求ID时返回全局日志
+func LogWithContext(ctx context.Context) *zap.SugaredLogger {
+	if requestId := RequestIdFromContext(ctx); requestId != "" {
+		return Log.With("request_id", requestId)
+	}
+	return Log
+}
@@ -0,0 +1,31 @@
+package common
+
+import (
+	"time"
+)
+
+// 注册定时任务, 每个任务在单独的goroutine中按固定间隔执行
+// interval小于等于0时不注册
+func AddScheduleJob(name string, interval time.Duration, job func()) {
+	if interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			runScheduleJob(name, job)
+		}
+	}()
+	Log.Infof("注册定时任务[%s]完成, 执行间隔: %s", name, interval)
+}
+
+// 执行定时任务, 任务异常不影响后续执行
+func runScheduleJob(name string, job func()) {
+	defer func() {
+		if err := recover(); err != nil {
+			Log.Errorf("定时任务[%s]执行异常: %v", name, err)
+		}
+	}()
+	job()
+}
@@ -0,0 +1,155 @@
+package common
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"github.com/patrickmn/go-cache"
+	"go-web-mini/config"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 用户登录会话(一次登录及其刷新得到的token共用一个token ID)
+type TokenSession struct {
+	TokenId    string    // token ID(jti)
+	Ip         string    // 登录IP
+	UserAgent  string    // 登录时的浏览器标识
+	LoginAt    time.Time // 登录时间
+	LastSeenAt time.Time // 最后访问时间
+}
+
+// 会话过期时间(token ID的最长有效时间)
+func (s *TokenSession) ExpiresAt() time.Time {
+	return s.LoginAt.Add(TokenIdMaxAge())
+}
+
+// 用户的登录会话
+type userSessions struct {
+	Latest   string                   // 最新登录的token ID, 开启单会话登录时使用
+	Sessions map[string]*TokenSession // key为token ID
+}
+
+// 用户的登录会话, key为用户ID
+// 只保存在本节点内存中, 多节点部署时需要保证同一用户的请求转发到同一节点
+var (
+	userSessionCache = cache.New(24*time.Hour, time.Hour)
+	userSessionMutex sync.Mutex
+)
+
+// 已登出的token ID(jti)黑名单, 保存到token ID最长有效时间后自动删除
+var revokedTokenCache = cache.New(24*time.Hour, time.Hour)
+
+// 生成token ID(jti)
+func NewTokenId() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%d", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
+
+// token ID的最长有效时间(token过期时间+最大刷新时间), 刷新token时jti不变
+func TokenIdMaxAge() time.Duration {
+	return time.Hour * time.Duration(config.Conf.Jwt.Timeout+config.Conf.Jwt.MaxRefresh)
+}
+
+// 记录用户新登录的会话, 之前登录的token在开启单会话登录时失效
+func AddUserSession(userId uint, session *TokenSession) {
+	userSessionMutex.Lock()
+	defer userSessionMutex.Unlock()
+	sessions := getUserSessions(userId)
+	// 清理已过期的会话
+	now := time.Now()
+	for tokenId, s := range sessions.Sessions {
+		if !s.ExpiresAt().After(now) {
+			delete(sessions.Sessions, tokenId)
+		}
+	}
+	sessions.Latest = session.TokenId
+	sessions.Sessions[session.TokenId] = session
+	userSessionCache.Set(userSessionKey(userId), sessions, TokenIdMaxAge())
+}
+
+// 更新会话的最后访问时间
+func TouchUserSession(userId uint, tokenId string) {
+	userSessionMutex.Lock()
+	defer userSessionMutex.Unlock()
+	if session, ok := getUserSessions(userId).Sessions[tokenId]; ok {
+		session.LastSeenAt = time.Now()
+	}
+}
+
+// 获取用户未过期的登录会话, 按登录时间倒序
+func GetUserSessions(userId uint) []TokenSession {
+	userSessionMutex.Lock()
+	defer userSessionMutex.Unlock()
+	now := time.Now()
+	list := make([]TokenSession, 0)
+	for _, session := range getUserSessions(userId).Sessions {
+		if session.ExpiresAt().After(now) {
+			list = append(list, *session)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].LoginAt.After(list[j].LoginAt)
+	})
+	return list
+}
+
+// 删除用户的登录会话并将token ID加入黑名单, 会话不存在时返回false
+func RevokeUserSession(userId uint, tokenId string) bool {
+	userSessionMutex.Lock()
+	defer userSessionMutex.Unlock()
+	sessions := getUserSessions(userId)
+	session, ok := sessions.Sessions[tokenId]
+	if !ok {
+		return false
+	}
+	delete(sessions.Sessions, tokenId)
+	RevokeTokenId(tokenId, session.ExpiresAt())
+	return true
+}
+
+// 是否为用户最新登录的token ID
+// 未开启单会话登录或没有登录记录(如服务重启)时返回true
+func IsUserSessionActive(userId uint, tokenId string) bool {
+	if !config.Conf.Jwt.SingleSession {
+		return true
+	}
+	userSessionMutex.Lock()
+	defer userSessionMutex.Unlock()
+	latest := getUserSessions(userId).Latest
+	return latest == "" || latest == tokenId
+}
+
+// 将token ID加入黑名单直到expireAt, 已过期的不需要加入
+func RevokeTokenId(tokenId string, expireAt time.Time) {
+	ttl := time.Until(expireAt)
+	if tokenId == "" || ttl <= 0 {
+		return
+	}
+	revokedTokenCache.Set(tokenId, struct{}{}, ttl)
+}
+
+// token ID是否已登出
+func IsTokenIdRevoked(tokenId string) bool {
+	if tokenId == "" {
+		return false
+	}
+	_, found := revokedTokenCache.Get(tokenId)
+	return found
+}
+
+// 获取用户的登录会话(没有时返回空的会话), 调用方需要持有userSessionMutex
+func getUserSessions(userId uint) *userSessions {
+	if v, found := userSessionCache.Get(userSessionKey(userId)); found {
+		return v.(*userSessions)
+	}
+	return &userSessions{Sessions: make(map[string]*TokenSession)}
+}
+
+func userSessionKey(userId uint) string {
+	return fmt.Sprintf("session:%d", userId)
+}
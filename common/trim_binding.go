@@ -0,0 +1,26 @@
+package common
+
+import (
+	"github.com/gin-gonic/gin/binding"
+	"go-web-mini/util"
+)
+
+// 绑定请求参数后先去除字符串字段首尾空白再校验
+type trimValidator struct {
+	binding.StructValidator
+}
+
+func (v trimValidator) ValidateStruct(obj interface{}) error {
+	util.TrimStrings(obj)
+	return v.StructValidator.ValidateStruct(obj)
+}
+
+// 开启请求参数字符串自动去除首尾空白
+// 所有通过ShouldBind绑定的请求参数都会处理, 不需要处理的字段使用trim:"-"标签
+func InitTrimBinding() {
+	if _, ok := binding.Validator.(trimValidator); ok {
+		return
+	}
+	binding.Validator = trimValidator{StructValidator: binding.Validator}
+	Log.Info("开启请求参数自动去除首尾空白")
+}
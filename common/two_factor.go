@@ -0,0 +1,66 @@
+package common
+
+import (
+	"github.com/patrickmn/go-cache"
+	"sync"
+	"time"
+)
+
+// 两步验证登录的挑战(密码校验通过后生成, 第二步提交挑战和验证码完成登录)
+type twoFactorChallenge struct {
+	UserId   uint
+	Failures int
+}
+
+// 挑战有效期和最多允许输错验证码的次数
+const (
+	twoFactorChallengeTTL         = 5 * time.Minute
+	twoFactorChallengeMaxFailures = 5
+)
+
+var (
+	twoFactorChallengeCache = cache.New(twoFactorChallengeTTL, time.Minute)
+	twoFactorChallengeMutex sync.Mutex
+)
+
+// 生成两步验证登录的挑战
+func NewTwoFactorChallenge(userId uint) string {
+	challenge := NewTokenId()
+	twoFactorChallengeCache.Set(challenge, &twoFactorChallenge{UserId: userId}, cache.DefaultExpiration)
+	return challenge
+}
+
+// 获取挑战对应的用户ID, 挑战不存在或已过期时返回false
+func GetTwoFactorChallengeUserId(challenge string) (uint, bool) {
+	twoFactorChallengeMutex.Lock()
+	defer twoFactorChallengeMutex.Unlock()
+	v, found := twoFactorChallengeCache.Get(challenge)
+	if !found {
+		return 0, false
+	}
+	return v.(*twoFactorChallenge).UserId, true
+}
+
+// 记录一次验证码错误, 达到最多错误次数时挑战失效, 需要重新输入密码
+// 返回剩余可尝试次数
+func RecordTwoFactorFailure(challenge string) int {
+	twoFactorChallengeMutex.Lock()
+	defer twoFactorChallengeMutex.Unlock()
+	v, found := twoFactorChallengeCache.Get(challenge)
+	if !found {
+		return 0
+	}
+	state := v.(*twoFactorChallenge)
+	state.Failures++
+	remaining := twoFactorChallengeMaxFailures - state.Failures
+	if remaining <= 0 {
+		twoFactorChallengeCache.Delete(challenge)
+		return 0
+	}
+	return remaining
+}
+
+// 两步验证完成后删除挑战, 每个挑战只能使用一次
+func DeleteTwoFactorChallenge(challenge string) {
+	twoFactorChallengeCache.Delete(challenge)
+}
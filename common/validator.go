@@ -5,7 +5,11 @@ import (
 	ut "github.com/go-playground/universal-translator"
 	"github.com/go-playground/validator/v10"
 	ch_translations "github.com/go-playground/validator/v10/translations/zh"
+	"go-web-mini/config"
+	"go-web-mini/util"
+	"go-web-mini/vo"
 	"regexp"
+	"strconv"
 )
 
 // 全局Validate数据校验实列
@@ -23,11 +27,98 @@ func InitValidate() {
 	Validate = validator.New()
 	_ = ch_translations.RegisterDefaultTranslations(Validate, Trans)
 	_ = Validate.RegisterValidation("checkMobile", checkMobile)
+	_ = Validate.RegisterTranslation("checkMobile", Trans, func(ut ut.Translator) error {
+		return ut.Add("checkMobile", "{0}格式不正确", true)
+	}, func(ut ut.Translator, fe validator.FieldError) string {
+		t, _ := ut.T("checkMobile", fe.Field())
+		return t
+	})
+	_ = Validate.RegisterValidation("password_strength", checkPasswordStrength)
+	_ = Validate.RegisterTranslation("password_strength", Trans, func(ut ut.Translator) error {
+		return nil
+	}, translatePasswordStrength)
+	_ = Validate.RegisterValidation("maxCacheUsers", checkMaxCacheUsers)
+	_ = Validate.RegisterTranslation("maxCacheUsers", Trans, func(ut ut.Translator) error {
+		return ut.Add("maxCacheUsers", "{0}最多只能包含{1}项", true)
+	}, func(ut ut.Translator, fe validator.FieldError) string {
+		t, _ := ut.T("maxCacheUsers", fe.Field(), strconv.Itoa(maxCacheRequestUsers()))
+		return t
+	})
+	Validate.RegisterStructValidation(userListRequestValidation, vo.UserListRequest{})
+	_ = Validate.RegisterTranslation("timeRange", Trans, func(ut ut.Translator) error {
+		return ut.Add("timeRange", "开始时间不能晚于结束时间", true)
+	}, func(ut ut.Translator, fe validator.FieldError) string {
+		t, _ := ut.T(fe.Tag())
+		return t
+	})
 	Log.Infof("初始化validator.v10数据校验器完成")
 }
 
+// 中国大陆手机号(1开头, 第二位为3-9, 共11位), 新号段不断增加, 不再按号段细分
+var mobileRegexp = regexp.MustCompile(`^1[3-9]\d{9}$`)
+
+// 手机号格式校验, 为空时不校验(必填字段同时使用required)
 func checkMobile(fl validator.FieldLevel) bool {
-	reg := `^1([38][0-9]|14[579]|5[^4]|16[6]|7[1-35-8]|9[189])\d{8}$`
-	rgx := regexp.MustCompile(reg)
-	return rgx.MatchString(fl.Field().String())
+	mobile := fl.Field().String()
+	return mobile == "" || mobileRegexp.MatchString(mobile)
+}
+
+// 一次预热或删除缓存请求中默认最多指定的用户数量
+const defaultMaxCacheRequestUsers = 500
+
+// 一次预热或删除缓存请求中最多指定的用户数量(cache.max-request-users)
+func maxCacheRequestUsers() int {
+	if config.Conf.Cache == nil || config.Conf.Cache.MaxRequestUsers <= 0 {
+		return defaultMaxCacheRequestUsers
+	}
+	return config.Conf.Cache.MaxRequestUsers
+}
+
+// 预热或删除缓存请求中的用户数量校验, 上限由配置决定, 防止一次请求加载大量用户
+func checkMaxCacheUsers(fl validator.FieldLevel) bool {
+	return fl.Field().Len() <= maxCacheRequestUsers()
+}
+
+// 用户列表查询条件校验: 开始时间不能晚于结束时间(日期格式相同, 可以直接比较字符串)
+func userListRequestValidation(sl validator.StructLevel) {
+	req := sl.Current().Interface().(vo.UserListRequest)
+	if req.StartTime != "" && req.EndTime != "" && req.StartTime > req.EndTime {
+		sl.ReportError(req.EndTime, "EndTime", "EndTime", "timeRange", "")
+	}
+}
+
+// 按配置的密码规则(security.password-min-length、password-min-classes)校验密码强度
+// 请求中的密码为RSA加密后的值, 需要解密后使用Validate.Var(password, "password_strength")校验
+func checkPasswordStrength(fl validator.FieldLevel) bool {
+	return passwordStrengthError(fl.Field().String()) == nil
+}
+
+// 密码强度校验失败的翻译, 返回具体未满足的规则
+func translatePasswordStrength(ut ut.Translator, fe validator.FieldError) string {
+	password, _ := fe.Value().(string)
+	if err := passwordStrengthError(password); err != nil {
+		return err.Error()
+	}
+	return "密码强度不足"
+}
+
+func passwordStrengthError(password string) error {
+	return util.ValidatePasswordStrength(password, config.Conf.Security.PasswordMinLength, config.Conf.Security.PasswordMinClasses)
+}
+
+// 翻译全部字段的校验错误, 返回字段名到错误信息的映射, 方便前端同时提示所有字段
+// err不是校验错误时返回nil
+func TranslateAll(err error) map[string]string {
+	validationErrors, ok := err.(validator.ValidationErrors)
+	if !ok {
+		return nil
+	}
+	messages := make(map[string]string, len(validationErrors))
+	for _, fieldErr := range validationErrors {
+		// 同一字段有多个错误时只保留第一个
+		if _, exists := messages[fieldErr.Field()]; !exists {
+			messages[fieldErr.Field()] = fieldErr.Translate(Trans)
+		}
+	}
+	return messages
 }
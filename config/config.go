@@ -22,6 +22,10 @@ type config struct {
 	Casbin    *CasbinConfig    `mapstructure:"casbin" json:"casbin"`
 	Jwt       *JwtConfig       `mapstructure:"jwt" json:"jwt"`
 	RateLimit *RateLimitConfig `mapstructure:"rate-limit" json:"rateLimit"`
+	Cache     *CacheConfig     `mapstructure:"cache" json:"cache"`
+	Security  *SecurityConfig  `mapstructure:"security" json:"security"`
+	User      *UserConfig      `mapstructure:"user" json:"user"`
+	Storage   *StorageConfig   `mapstructure:"storage" json:"storage"`
 }
 
 // 设置读取配置信息
@@ -34,6 +38,8 @@ func InitConfig() {
 	viper.SetConfigName("config")
 	viper.SetConfigType("yml")
 	viper.AddConfigPath(workDir + "./")
+	// 环境变量覆盖配置
+	enableEnvOverride()
 	// 读取配置信息
 	err = viper.ReadInConfig()
 
@@ -47,6 +53,12 @@ func InitConfig() {
 		// 读取rsa key
 		Conf.System.RSAPublicBytes = util.RSAReadKeyFromFile(Conf.System.RSAPublicKey)
 		Conf.System.RSAPrivateBytes = util.RSAReadKeyFromFile(Conf.System.RSAPrivateKey)
+		// 读取常见密码列表
+		loadPasswordBlocklist()
+		// 读取敏感配置
+		if err := loadSecrets(); err != nil {
+			panic(fmt.Errorf("读取敏感配置失败:%s \n", err))
+		}
 	})
 
 	if err != nil {
@@ -59,7 +71,22 @@ func InitConfig() {
 	// 读取rsa key
 	Conf.System.RSAPublicBytes = util.RSAReadKeyFromFile(Conf.System.RSAPublicKey)
 	Conf.System.RSAPrivateBytes = util.RSAReadKeyFromFile(Conf.System.RSAPrivateKey)
+	// 读取常见密码列表
+	loadPasswordBlocklist()
+	// 读取敏感配置
+	if err := loadSecrets(); err != nil {
+		panic(fmt.Errorf("读取敏感配置失败:%s \n", err))
+	}
+}
 
+// 加载配置的常见密码列表文件, 未配置时只使用内置列表
+func loadPasswordBlocklist() {
+	if Conf.Security == nil || Conf.Security.PasswordBlocklistFile == "" {
+		return
+	}
+	if _, err := util.LoadCommonPasswordsFromFile(Conf.Security.PasswordBlocklistFile); err != nil {
+		panic(fmt.Errorf("读取常见密码列表文件失败:%s \n", err))
+	}
 }
 
 type SystemConfig struct {
@@ -69,8 +96,22 @@ type SystemConfig struct {
 	InitData        bool   `mapstructure:"init-data" json:"initData"`
 	RSAPublicKey    string `mapstructure:"rsa-public-key" json:"rsaPublicKey"`
 	RSAPrivateKey   string `mapstructure:"rsa-private-key" json:"rsaPrivateKey"`
+	StrictJson      bool   `mapstructure:"strict-json" json:"strictJson"`
+	TrimInput       bool   `mapstructure:"trim-input" json:"trimInput"`
+	RequireHttps    bool   `mapstructure:"require-https" json:"requireHttps"`
+	SetupToken      string `mapstructure:"setup-token" json:"-"`
 	RSAPublicBytes  []byte `mapstructure:"-" json:"-"`
 	RSAPrivateBytes []byte `mapstructure:"-" json:"-"`
+	// 只读模式(演示环境)及不受限制的用户名
+	ReadOnly                bool     `mapstructure:"read-only" json:"readOnly"`
+	ReadOnlyExemptUsernames []string `mapstructure:"read-only-exempt-usernames" json:"readOnlyExemptUsernames"`
+	// 列表接口返回数据大小上限(字节, 0为不限制)及超出时的处理方式(warn/reject)
+	ListResponseMaxBytes int    `mapstructure:"list-response-max-bytes" json:"listResponseMaxBytes"`
+	ListResponseSizeMode string `mapstructure:"list-response-size-mode" json:"listResponseSizeMode"`
+	// 列表接口分页信息中是否返回分页链接(first/prev/next/last)
+	PaginationLinks bool `mapstructure:"pagination-links" json:"paginationLinks"`
+	// 可信代理的IP或CIDR, 只有请求来自可信代理时才使用X-Forwarded-For、X-Real-Ip和X-Forwarded-Proto
+	TrustedProxies []string `mapstructure:"trusted-proxies" json:"trustedProxies"`
 }
 
 type LogsConfig struct {
@@ -80,6 +121,7 @@ type LogsConfig struct {
 	MaxBackups int           `mapstructure:"max-backups" json:"maxBackups"`
 	MaxAge     int           `mapstructure:"max-age" json:"maxAge"`
 	Compress   bool          `mapstructure:"compress" json:"compress"`
+	Format     string        `mapstructure:"format" json:"format"`
 }
 
 type MysqlConfig struct {
@@ -93,10 +135,17 @@ type MysqlConfig struct {
 	TablePrefix string `mapstructure:"table-prefix" json:"tablePrefix"`
 	Charset     string `mapstructure:"charset" json:"charset"`
 	Collation   string `mapstructure:"collation" json:"collation"`
+	// 连接池配置(不大于0时使用默认值)
+	MaxOpenConns    int `mapstructure:"max-open-conns" json:"maxOpenConns"`
+	MaxIdleConns    int `mapstructure:"max-idle-conns" json:"maxIdleConns"`
+	ConnMaxLifetime int `mapstructure:"conn-max-lifetime" json:"connMaxLifetime"`
+	ConnMaxIdleTime int `mapstructure:"conn-max-idle-time" json:"connMaxIdleTime"`
 }
 
 type CasbinConfig struct {
-	ModelPath string `mapstructure:"model-path" json:"modelPath"`
+	ModelPath   string `mapstructure:"model-path" json:"modelPath"`
+	DecisionLog bool   `mapstructure:"decision-log" json:"decisionLog"`
+	PolicyAudit bool   `mapstructure:"policy-audit" json:"policyAudit"`
 }
 
 type JwtConfig struct {
@@ -104,9 +153,150 @@ type JwtConfig struct {
 	Key        string `mapstructure:"key" json:"key"`
 	Timeout    int    `mapstructure:"timeout" json:"timeout"`
 	MaxRefresh int    `mapstructure:"max-refresh" json:"maxRefresh"`
+	ServerTime bool   `mapstructure:"server-time" json:"serverTime"`
+	// 登录、刷新token时是否同时写入cookie, 以及cookie是否只通过HTTPS发送
+	SendCookie   bool `mapstructure:"send-cookie" json:"sendCookie"`
+	SecureCookie bool `mapstructure:"secure-cookie" json:"secureCookie"`
+	// 只读请求(GET、HEAD)接受刚过期token的宽限时间, 秒(0表示不开启)
+	ReadLeeway int `mapstructure:"read-leeway" json:"readLeeway"`
+	// 是否只允许用户同时有一个登录会话(新登录后之前的token失效)
+	SingleSession bool `mapstructure:"single-session" json:"singleSession"`
+	// token剩余有效时间低于过期时间的百分之多少时自动续期(0表示不开启)
+	RenewThreshold int `mapstructure:"renew-threshold" json:"renewThreshold"`
 }
 
 type RateLimitConfig struct {
+	FillInterval       int64 `mapstructure:"fill-interval" json:"fillInterval"`
+	Capacity           int64 `mapstructure:"capacity" json:"capacity"`
+	ExportFillInterval int64 `mapstructure:"export-fill-interval" json:"exportFillInterval"`
+	ExportCapacity     int64 `mapstructure:"export-capacity" json:"exportCapacity"`
+	// 按IP或用户名单独限流的接口, key为路由名称(如login、change-password)
+	Routes map[string]*RouteRateLimitConfig `mapstructure:"routes" json:"routes"`
+}
+
+// 按IP或用户名限流的配置
+type RouteRateLimitConfig struct {
+	// 限流维度: ip或username(未登录时使用ip)
+	By string `mapstructure:"by" json:"by"`
+	// 填充一个令牌需要的时间间隔, 毫秒
 	FillInterval int64 `mapstructure:"fill-interval" json:"fillInterval"`
-	Capacity     int64 `mapstructure:"capacity" json:"capacity"`
+	// 桶容量(0表示不限流)
+	Capacity int64 `mapstructure:"capacity" json:"capacity"`
+}
+
+type CacheConfig struct {
+	WarmInterval    int `mapstructure:"warm-interval" json:"warmInterval"`
+	WarmConcurrency int `mapstructure:"warm-concurrency" json:"warmConcurrency"`
+	// 多实例部署时轮询缓存失效记录的间隔, 秒(0表示不开启)
+	InvalidationPollInterval int `mapstructure:"invalidation-poll-interval" json:"invalidationPollInterval"`
+	// 缓存失效记录的保留时间, 分钟
+	InvalidationRetention int `mapstructure:"invalidation-retention" json:"invalidationRetention"`
+	// 一次预热或删除缓存请求中最多指定的用户数量(0时使用默认值500)
+	MaxRequestUsers int `mapstructure:"max-request-users" json:"maxRequestUsers"`
+}
+
+type SecurityConfig struct {
+	SensitiveMinRoleSort uint `mapstructure:"sensitive-min-role-sort" json:"sensitiveMinRoleSort"`
+	PasswordMinLength    int  `mapstructure:"password-min-length" json:"passwordMinLength"`
+	PasswordMinClasses   int  `mapstructure:"password-min-classes" json:"passwordMinClasses"`
+	// 常见密码列表文件
+	PasswordBlocklistFile string `mapstructure:"password-blocklist-file" json:"passwordBlocklistFile"`
+	// 修改密码时不能与最近N次使用的密码相同(包括当前密码, 0表示不限制)
+	PasswordHistoryCount int `mapstructure:"password-history-count" json:"passwordHistoryCount"`
+	// 是否开启角色继承
+	RoleInheritance bool `mapstructure:"role-inheritance" json:"roleInheritance"`
+	// 登录失败锁定
+	LoginLock *LoginLockConfig `mapstructure:"login-lock" json:"loginLock"`
+	// 角色排序重复时的处理方式(allow/warn/reject)
+	RoleSortConflict string `mapstructure:"role-sort-conflict" json:"roleSortConflict"`
+	// 集成方API Key
+	ApiKeys []*ApiKeyConfig `mapstructure:"api-keys" json:"-"`
+	// 是否禁止用户通过任何操作移除自己的最高等级角色
+	SelfRoleGuard bool `mapstructure:"self-role-guard" json:"selfRoleGuard"`
+	// 登录后允许跳转的地址前缀或主机名, 为空时只允许同源地址
+	RedirectAllowlist []string `mapstructure:"redirect-allowlist" json:"redirectAllowlist"`
+	// 两步验证在验证器App中显示的发行方名称
+	TwoFactorIssuer string `mapstructure:"two-factor-issuer" json:"twoFactorIssuer"`
+}
+
+type ApiKeyConfig struct {
+	Name string `mapstructure:"name" json:"name"`
+	Key  string `mapstructure:"key" json:"-"`
+	// 绑定的集成方账号, 这些账号必须带上该API Key访问, 该API Key也只能由这些账号使用
+	Usernames  []string `mapstructure:"usernames" json:"usernames"`
+	UserFields []string `mapstructure:"user-fields" json:"userFields"`
+}
+
+type LoginLockConfig struct {
+	MaxFailures int     `mapstructure:"max-failures" json:"maxFailures"`
+	BaseSeconds int     `mapstructure:"base-seconds" json:"baseSeconds"`
+	Multiplier  float64 `mapstructure:"multiplier" json:"multiplier"`
+	MaxSeconds  int     `mapstructure:"max-seconds" json:"maxSeconds"`
+	DecayHours  int     `mapstructure:"decay-hours" json:"decayHours"`
+	// 修改或重置密码后是否清除该用户的登录失败记录和锁定
+	ResetOnPasswordChange bool `mapstructure:"reset-on-password-change" json:"resetOnPasswordChange"`
+}
+
+type UserConfig struct {
+	RoleExpiringDays uint `mapstructure:"role-expiring-days" json:"roleExpiringDays"`
+	// 更新用户时修改字段需要的角色(字段名 -> 角色关键字列表), 未配置的字段不限制
+	FieldPermissions map[string][]string `mapstructure:"field-permissions" json:"fieldPermissions"`
+	// 获取用户列表时非超级管理员是否必须提供查询条件
+	ListRequireFilter bool `mapstructure:"list-require-filter" json:"listRequireFilter"`
+	// 长期未登录自动禁用
+	InactiveDisableDays     uint     `mapstructure:"inactive-disable-days" json:"inactiveDisableDays"`
+	InactiveCheckInterval   int      `mapstructure:"inactive-check-interval" json:"inactiveCheckInterval"`
+	InactiveExemptUsernames []string `mapstructure:"inactive-exempt-usernames" json:"inactiveExemptUsernames"`
+	// 创建、更新用户时角色ID部分无效的处理策略(strict/lenient)
+	RoleIdsPolicy string `mapstructure:"role-ids-policy" json:"roleIdsPolicy"`
+	// 创建用户、重置密码时未提供密码使用的默认密码(为空时生成随机密码)
+	DefaultPassword string `mapstructure:"default-password" json:"defaultPassword"`
+	// 头像上传
+	Avatar *AvatarConfig `mapstructure:"avatar" json:"avatar"`
+	// 导入用户
+	Import *ImportConfig `mapstructure:"import" json:"import"`
+}
+
+type AvatarConfig struct {
+	// 已废弃, 改为配置storage.local, 未配置storage.local.dir时仍作为头像保存目录
+	Dir string `mapstructure:"dir" json:"dir"`
+	// 已废弃, 改为配置storage.local, 未配置storage.local.dir时仍作为头像访问地址前缀
+	UrlPrefix string `mapstructure:"url-prefix" json:"urlPrefix"`
+	// 头像最大大小, KB
+	MaxSize int64 `mapstructure:"max-size" json:"maxSize"`
+}
+
+type ImportConfig struct {
+	// 导入文件最大大小, KB
+	MaxSize int64 `mapstructure:"max-size" json:"maxSize"`
+	// 最多导入多少行数据(不包括表头)
+	MaxRows int `mapstructure:"max-rows" json:"maxRows"`
+	// 每行最多多少列
+	MaxColumns int `mapstructure:"max-columns" json:"maxColumns"`
+	// xlsx文件中每个xml文件解压后的最大大小, KB
+	MaxUncompressedSize int64 `mapstructure:"max-uncompressed-size" json:"maxUncompressedSize"`
+}
+
+type StorageConfig struct {
+	// 存储类型(local/s3)
+	Type  string              `mapstructure:"type" json:"type"`
+	Local *LocalStorageConfig `mapstructure:"local" json:"local"`
+	S3    *S3StorageConfig    `mapstructure:"s3" json:"s3"`
+}
+
+type LocalStorageConfig struct {
+	// 保存目录(相对运行目录, 也可以填绝对路径)
+	Dir string `mapstructure:"dir" json:"dir"`
+	// 访问地址前缀, 以/开头时作为静态文件路由注册
+	UrlPrefix string `mapstructure:"url-prefix" json:"urlPrefix"`
+}
+
+type S3StorageConfig struct {
+	Endpoint  string `mapstructure:"endpoint" json:"endpoint"`
+	Region    string `mapstructure:"region" json:"region"`
+	Bucket    string `mapstructure:"bucket" json:"bucket"`
+	AccessKey string `mapstructure:"access-key" json:"accessKey"`
+	SecretKey string `mapstructure:"secret-key" json:"-"`
+	// 访问地址前缀(如CDN地址)
+	UrlPrefix string `mapstructure:"url-prefix" json:"urlPrefix"`
 }
@@ -0,0 +1,58 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"github.com/spf13/viper"
+	"go-web-mini/util"
+	"strings"
+)
+
+// 环境变量前缀, 如mysql.password对应GO_WEB_MINI_MYSQL_PASSWORD
+const envPrefix = "GO_WEB_MINI"
+
+// 加密配置值的前缀, 之后为使用系统rsa公钥加密后的base64字符串
+const encryptedPrefix = "rsa:"
+
+// 开启环境变量覆盖配置, 环境变量优先于配置文件
+// 只支持配置文件中已有的配置项(值可以为空)
+func enableEnvOverride() {
+	viper.SetEnvPrefix(envPrefix)
+	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_", "-", "_"))
+	viper.AutomaticEnv()
+}
+
+// 解密加密的敏感配置并校验必需的敏感配置是否存在
+func loadSecrets() error {
+	secrets := map[string]*string{
+		"mysql.password":     &Conf.Mysql.Password,
+		"jwt.key":            &Conf.Jwt.Key,
+		"system.setup-token": &Conf.System.SetupToken,
+	}
+	if Conf.Storage != nil && Conf.Storage.S3 != nil {
+		secrets["storage.s3.secret-key"] = &Conf.Storage.S3.SecretKey
+	}
+	for i, apiKey := range Conf.Security.ApiKeys {
+		secrets[fmt.Sprintf("security.api-keys[%d].key", i)] = &apiKey.Key
+	}
+	for name, value := range secrets {
+		if !strings.HasPrefix(*value, encryptedPrefix) {
+			continue
+		}
+		plain, err := util.RSADecrypt([]byte(strings.TrimPrefix(*value, encryptedPrefix)), Conf.System.RSAPrivateBytes)
+		if err != nil {
+			return fmt.Errorf("解密配置%s失败: %v", name, err)
+		}
+		*value = string(plain)
+	}
+
+	for name, value := range map[string]string{
+		"mysql.username": Conf.Mysql.Username,
+		"jwt.key":        Conf.Jwt.Key,
+	} {
+		if value == "" {
+			return errors.New("缺少必需的配置: " + name)
+		}
+	}
+	return nil
+}
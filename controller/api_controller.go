@@ -46,19 +46,18 @@ func (ac ApiController) GetApis(c *gin.Context) {
 	// 获取
 	apis, total, err := ac.ApiRepository.GetApis(&req)
 	if err != nil {
-		response.Fail(c, nil, "获取接口列表失败")
+		response.ServerError(c, nil, "获取接口列表失败")
 		return
 	}
-	response.Success(c, gin.H{
-		"apis": apis, "total": total,
-	}, "获取接口列表成功")
+	page := response.NewPageData(c, total, int(req.PageNum), int(req.PageSize))
+	response.SuccessPage(c, "apis", apis, page, "获取接口列表成功")
 }
 
 // 获取接口树(按接口Category字段分类)
 func (ac ApiController) GetApiTree(c *gin.Context) {
 	tree, err := ac.ApiRepository.GetApiTree()
 	if err != nil {
-		response.Fail(c, nil, "获取接口树失败")
+		response.ServerError(c, nil, "获取接口树失败")
 		return
 	}
 	response.Success(c, gin.H{
@@ -70,7 +69,7 @@ func (ac ApiController) GetApiTree(c *gin.Context) {
 func (ac ApiController) CreateApi(c *gin.Context) {
 	var req vo.CreateApiRequest
 	// 参数绑定
-	if err := c.ShouldBind(&req); err != nil {
+	if err := bindWriteRequest(c, &req); err != nil {
 		response.Fail(c, nil, err.Error())
 		return
 	}
@@ -85,7 +84,7 @@ func (ac ApiController) CreateApi(c *gin.Context) {
 	ur := repository.NewUserRepository()
 	ctxUser, err := ur.GetCurrentUser(c)
 	if err != nil {
-		response.Fail(c, nil, "获取当前用户信息失败")
+		failCurrentUser(c, err)
 		return
 	}
 
@@ -100,7 +99,7 @@ func (ac ApiController) CreateApi(c *gin.Context) {
 	// 创建接口
 	err = ac.ApiRepository.CreateApi(&api)
 	if err != nil {
-		response.Fail(c, nil, "创建接口失败: "+err.Error())
+		failSave(c, err, "创建接口失败")
 		return
 	}
 
@@ -112,7 +111,7 @@ func (ac ApiController) CreateApi(c *gin.Context) {
 func (ac ApiController) UpdateApiById(c *gin.Context) {
 	var req vo.UpdateApiRequest
 	// 参数绑定
-	if err := c.ShouldBind(&req); err != nil {
+	if err := bindWriteRequest(c, &req); err != nil {
 		response.Fail(c, nil, err.Error())
 		return
 	}
@@ -134,7 +133,7 @@ func (ac ApiController) UpdateApiById(c *gin.Context) {
 	ur := repository.NewUserRepository()
 	ctxUser, err := ur.GetCurrentUser(c)
 	if err != nil {
-		response.Fail(c, nil, "获取当前用户信息失败")
+		failCurrentUser(c, err)
 		return
 	}
 
@@ -148,9 +147,11 @@ func (ac ApiController) UpdateApiById(c *gin.Context) {
 
 	err = ac.ApiRepository.UpdateApiById(uint(apiId), &api)
 	if err != nil {
-		response.Fail(c, nil, "更新接口失败: "+err.Error())
+		failSave(c, err, "更新接口失败")
 		return
 	}
+	// 清理用户权限缓存(缓存中包含接口权限)
+	ur.ClearUserPermissionCache()
 
 	response.Success(c, nil, "更新接口成功")
 }
@@ -159,7 +160,7 @@ func (ac ApiController) UpdateApiById(c *gin.Context) {
 func (ac ApiController) BatchDeleteApiByIds(c *gin.Context) {
 	var req vo.DeleteApiRequest
 	// 参数绑定
-	if err := c.ShouldBind(&req); err != nil {
+	if err := bindWriteRequest(c, &req); err != nil {
 		response.Fail(c, nil, err.Error())
 		return
 	}
@@ -173,9 +174,11 @@ func (ac ApiController) BatchDeleteApiByIds(c *gin.Context) {
 	// 删除接口
 	err := ac.ApiRepository.BatchDeleteApiByIds(req.ApiIds)
 	if err != nil {
-		response.Fail(c, nil, "删除接口失败: "+err.Error())
+		response.ServerError(c, nil, "删除接口失败: "+err.Error())
 		return
 	}
+	// 清理用户权限缓存(缓存中包含接口权限)
+	repository.NewUserRepository().ClearUserPermissionCache()
 
 	response.Success(c, nil, "删除接口成功")
 }
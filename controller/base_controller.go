@@ -0,0 +1,25 @@
+package controller
+
+import (
+	"github.com/gin-gonic/gin"
+	"go-web-mini/response"
+	"time"
+)
+
+type IBaseController interface {
+	GetServerTime(c *gin.Context) // 获取服务器时间
+}
+
+type BaseController struct {
+}
+
+func NewBaseController() IBaseController {
+	baseController := BaseController{}
+	return baseController
+}
+
+// 获取服务器时间(RFC3339, UTC)
+// 前端可用于校正本地时钟偏差, 准确计算token剩余有效期
+func (bc BaseController) GetServerTime(c *gin.Context) {
+	response.Success(c, gin.H{"serverTime": time.Now().UTC().Format(time.RFC3339)}, "获取服务器时间成功")
+}
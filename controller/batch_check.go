@@ -0,0 +1,203 @@
+package controller
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/thoas/go-funk"
+	"go-web-mini/dto"
+	"go-web-mini/middleware"
+	"go-web-mini/model"
+	"go-web-mini/repository"
+	"go-web-mini/response"
+)
+
+// 批量操作对应的接口(用于校验当前用户是否拥有该接口的权限)
+var batchOperationApis = map[string][2]string{
+	"user-delete":  {"/user/delete/batch", "DELETE"},
+	"user-restore": {"/user/restore/batch", "PATCH"},
+	"user-disable": {"/user/batch/status", "PATCH"},
+	"user-enable":  {"/user/batch/status", "PATCH"},
+	"role-delete":  {"/role/delete/batch", "DELETE"},
+	"role-disable": {"/role/status/batch", "PATCH"},
+	"role-enable":  {"/role/status/batch", "PATCH"},
+}
+
+// 批量操作的权限校验, 只返回每一项的校验结果, 不做任何修改
+// 批量操作接口与权限校验接口共用, 保证两者的校验规则一致
+func checkBatchOperation(ctxUser model.User, minSort uint, operation string, ids []uint) ([]*dto.BatchCheckResultDto, error) {
+	switch operation {
+	case "user-delete":
+		return checkUserBatchDelete(ctxUser, minSort, ids)
+	case "user-restore":
+		return checkUserBatchRestore(minSort, ids)
+	case "user-disable", "user-enable":
+		return checkUserBatchStatus(ctxUser, minSort, operation, ids)
+	case "role-delete", "role-disable", "role-enable":
+		return checkRoleBatchOperation(ctxUser, minSort, operation, ids)
+	}
+	return nil, fmt.Errorf("不支持的批量操作: %s", operation)
+}
+
+// 批量删除用户的权限校验
+func checkUserBatchDelete(ctxUser model.User, minSort uint, userIds []uint) ([]*dto.BatchCheckResultDto, error) {
+	ur := repository.NewUserRepository()
+	sortMap, err := ur.GetUserMinRoleSortMapByIds(userIds)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]*dto.BatchCheckResultDto, 0, len(userIds))
+	for _, userId := range funk.Uniq(userIds).([]uint) {
+		result := &dto.BatchCheckResultDto{Id: userId}
+		userSort, ok := sortMap[userId]
+		result.Checks = append(result.Checks, newPermissionCheck("exists", ok, fmt.Sprintf("未获取到ID为%d的用户", userId)))
+		// 不能删除自己
+		result.Checks = append(result.Checks, newPermissionCheck("self", userId != ctxUser.ID, "不能删除自己"))
+		// 不能删除比自己角色排序低(等级高)或相同的用户, 与创建、更新用户的校验一致
+		result.Checks = append(result.Checks, newPermissionCheck("hierarchy", ok && minSort < userSort, "用户不能删除比自己角色等级高的或者相同等级的用户"))
+		results = append(results, finishBatchCheck(result))
+	}
+	return results, nil
+}
+
+// 批量分配角色的用户权限校验(角色本身的等级由调用方校验)
+// 不能更改自己的角色, 不能更改比自己角色等级高的或者相同等级的用户
+func checkUserBatchAssignRole(ctxUser model.User, minSort uint, userIds []uint) ([]*dto.BatchCheckResultDto, error) {
+	ur := repository.NewUserRepository()
+	sortMap, err := ur.GetUserMinRoleSortMapByIds(userIds)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]*dto.BatchCheckResultDto, 0, len(userIds))
+	for _, userId := range funk.Uniq(userIds).([]uint) {
+		result := &dto.BatchCheckResultDto{Id: userId}
+		userSort, ok := sortMap[userId]
+		result.Checks = append(result.Checks, newPermissionCheck("exists", ok, fmt.Sprintf("未获取到ID为%d的用户", userId)))
+		result.Checks = append(result.Checks, newPermissionCheck("self", userId != ctxUser.ID, "不能更改自己的角色"))
+		result.Checks = append(result.Checks, newPermissionCheck("hierarchy", ok && minSort < userSort, "用户不能更新比自己角色等级高的或者相同等级的用户"))
+		results = append(results, finishBatchCheck(result))
+	}
+	return results, nil
+}
+
+// 批量禁用、启用用户的权限校验, 与修改单个用户状态的校验一致
+// 不能禁用自己, 不能修改比自己角色等级高的或者相同等级的用户的状态(启用自己时不受限制)
+func checkUserBatchStatus(ctxUser model.User, minSort uint, operation string, userIds []uint) ([]*dto.BatchCheckResultDto, error) {
+	ur := repository.NewUserRepository()
+	sortMap, err := ur.GetUserMinRoleSortMapByIds(userIds)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]*dto.BatchCheckResultDto, 0, len(userIds))
+	for _, userId := range funk.Uniq(userIds).([]uint) {
+		result := &dto.BatchCheckResultDto{Id: userId}
+		userSort, ok := sortMap[userId]
+		isSelf := userId == ctxUser.ID
+		result.Checks = append(result.Checks, newPermissionCheck("exists", ok, fmt.Sprintf("未获取到ID为%d的用户", userId)))
+		if operation == "user-disable" {
+			result.Checks = append(result.Checks, newPermissionCheck("self", !isSelf, "不能禁用自己"))
+		}
+		result.Checks = append(result.Checks, newPermissionCheck("hierarchy", ok && (isSelf || minSort < userSort), "用户不能修改比自己角色等级高的或者相同等级的用户的状态"))
+		results = append(results, finishBatchCheck(result))
+	}
+	return results, nil
+}
+
+// 批量恢复已删除用户的权限校验
+// 恢复后的用户拥有删除前的角色, 与删除用户相同, 不能恢复比自己角色等级高的或者相同等级的用户
+func checkUserBatchRestore(minSort uint, userIds []uint) ([]*dto.BatchCheckResultDto, error) {
+	ur := repository.NewUserRepository()
+	sortMap, err := ur.GetDeletedUserMinRoleSortMapByIds(userIds)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]*dto.BatchCheckResultDto, 0, len(userIds))
+	for _, userId := range funk.Uniq(userIds).([]uint) {
+		result := &dto.BatchCheckResultDto{Id: userId}
+		userSort, ok := sortMap[userId]
+		result.Checks = append(result.Checks, newPermissionCheck("exists", ok, fmt.Sprintf("回收站中未获取到ID为%d的用户", userId)))
+		result.Checks = append(result.Checks, newPermissionCheck("hierarchy", ok && minSort < userSort, "用户不能恢复比自己角色等级高的或者相同等级的用户"))
+		results = append(results, finishBatchCheck(result))
+	}
+	return results, nil
+}
+
+// 批量删除、禁用、启用角色的权限校验
+func checkRoleBatchOperation(ctxUser model.User, minSort uint, operation string, roleIds []uint) ([]*dto.BatchCheckResultDto, error) {
+	rr := repository.NewRoleRepository()
+	roles, err := rr.GetRolesByIds(roleIds)
+	if err != nil {
+		return nil, err
+	}
+	roleMap := make(map[uint]*model.Role, len(roles))
+	for _, role := range roles {
+		roleMap[role.ID] = role
+	}
+	hierarchyReason := "不能修改比自己角色等级高或相等的角色"
+	if operation == "role-delete" {
+		hierarchyReason = "不能删除比自己角色等级高或相等的角色"
+	}
+
+	results := make([]*dto.BatchCheckResultDto, 0, len(roleIds))
+	// 已通过校验的将被删除或禁用的角色, 累计校验是否会移除自己的最高等级角色
+	removedIds := make([]uint, 0, len(roleIds))
+	for _, roleId := range funk.Uniq(roleIds).([]uint) {
+		result := &dto.BatchCheckResultDto{Id: roleId}
+		role, ok := roleMap[roleId]
+		result.Checks = append(result.Checks, newPermissionCheck("exists", ok, "未获取到角色信息"))
+		result.Checks = append(result.Checks, newPermissionCheck("hierarchy", ok && minSort < role.Sort, hierarchyReason))
+		if operation != "role-enable" {
+			if err := checkSelfRoleRemoval(ctxUser, append(removedIds, roleId)); err != nil {
+				result.Checks = append(result.Checks, newPermissionCheck("self", false, err.Error()))
+			} else {
+				result.Checks = append(result.Checks, newPermissionCheck("self", true, ""))
+			}
+		}
+		results = append(results, finishBatchCheck(result))
+		if result.Allowed {
+			removedIds = append(removedIds, roleId)
+		}
+	}
+	return results, nil
+}
+
+// 批量操作接口的权限校验
+func checkBatchOperationApi(ctxUser model.User, operation string) (*dto.PermissionCheckDto, error) {
+	api, ok := batchOperationApis[operation]
+	if !ok {
+		return nil, fmt.Errorf("不支持的批量操作: %s", operation)
+	}
+	isPass, err := middleware.HasApiPermission(ctxUser, api[0], api[1])
+	if err != nil {
+		return nil, err
+	}
+	return newPermissionCheck("permission", isPass, fmt.Sprintf("没有接口%s %s的权限", api[1], api[0])), nil
+}
+
+// 批量操作校验未通过时的响应, 对象不存在返回400, 其余(自我保护、角色等级)返回403
+func failBatchCheck(c *gin.Context, result *dto.BatchCheckResultDto) {
+	check := result.FailedCheck()
+	if check == nil || check.Check == "exists" {
+		response.Fail(c, nil, result.FailReason())
+		return
+	}
+	response.Forbidden(c, nil, check.Reason)
+}
+
+// 生成权限校验项, 通过时不返回原因
+func newPermissionCheck(check string, passed bool, reason string) *dto.PermissionCheckDto {
+	if passed {
+		reason = ""
+	}
+	return &dto.PermissionCheckDto{Check: check, Passed: passed, Reason: reason}
+}
+
+// 所有校验项都通过才允许操作
+func finishBatchCheck(result *dto.BatchCheckResultDto) *dto.BatchCheckResultDto {
+	result.Allowed = true
+	for _, check := range result.Checks {
+		if !check.Passed {
+			result.Allowed = false
+		}
+	}
+	return result
+}
@@ -0,0 +1,49 @@
+package controller
+
+import (
+	"go-web-mini/common"
+	"go-web-mini/model"
+	"go-web-mini/testutil"
+	"testing"
+	"time"
+)
+
+func TestUserBatchChecksIgnoreExpiredTopRole(t *testing.T) {
+	setupControllerTest(t)
+	admin := testutil.CreateRole(t, "admin", 1)
+	manager := testutil.CreateRole(t, "manager", 2)
+	user := testutil.CreateRole(t, "user", 3)
+	alice := testutil.CreateUser(t, "alice", "passwd", manager)
+	bob := testutil.CreateUser(t, "bob", "passwd", admin, user)
+	// bob的最高等级角色已过期, 实际等级为user
+	expiredAt := time.Now().Add(-time.Hour)
+	common.DB.Model(&model.UserRole{}).Where("user_id = ? AND role_id = ?", bob.ID, admin.ID).Update("expires_at", expiredAt)
+
+	ctxUser := model.User{Model: alice.Model, Username: alice.Username, Roles: alice.Roles}
+	checks := map[string]func() (bool, error){
+		"user-delete": func() (bool, error) {
+			results, err := checkUserBatchDelete(ctxUser, manager.Sort, []uint{bob.ID})
+			return err == nil && results[0].Allowed, err
+		},
+		"user-disable": func() (bool, error) {
+			results, err := checkUserBatchStatus(ctxUser, manager.Sort, "user-disable", []uint{bob.ID})
+			return err == nil && results[0].Allowed, err
+		},
+		"user-assign-role": func() (bool, error) {
+			results, err := checkUserBatchAssignRole(ctxUser, manager.Sort, []uint{bob.ID})
+			return err == nil && results[0].Allowed, err
+		},
+	}
+	for operation, check := range checks {
+		if allowed, err := check(); err != nil || !allowed {
+			t.Errorf("%s: 目标用户的最高等级角色已过期时返回%v, %v, 期望允许", operation, allowed, err)
+		}
+	}
+
+	// 回收站中的用户同样不计算已过期的角色
+	common.DB.Delete(&model.User{}, bob.ID)
+	results, err := checkUserBatchRestore(manager.Sort, []uint{bob.ID})
+	if err != nil || !results[0].Allowed {
+		t.Errorf("user-restore: 目标用户的最高等级角色已过期时返回%+v, %v, 期望允许", results, err)
+	}
+}
@@ -0,0 +1,32 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"go-web-mini/config"
+	"strings"
+)
+
+// 绑定写接口(新增、更新、删除等)的请求参数
+// 开启严格JSON模式时, JSON请求体中包含未知字段返回错误并提示字段名, 其他情况与ShouldBind相同
+func bindWriteRequest(c *gin.Context, obj interface{}) error {
+	if !config.Conf.System.StrictJson || c.ContentType() != binding.MIMEJSON || c.Request.Body == nil {
+		return c.ShouldBind(obj)
+	}
+	decoder := json.NewDecoder(c.Request.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(obj); err != nil {
+		// 未知字段的错误信息为: json: unknown field "nikname"
+		if field := strings.TrimPrefix(err.Error(), "json: unknown field "); field != err.Error() {
+			return fmt.Errorf("请求参数中包含未知字段: %s", strings.Trim(field, `"`))
+		}
+		return err
+	}
+	// 与ShouldBind相同, 绑定后使用gin的校验器(开启时会去除字符串首尾空白)
+	if binding.Validator == nil {
+		return nil
+	}
+	return binding.Validator.ValidateStruct(obj)
+}
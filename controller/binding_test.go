@@ -0,0 +1,28 @@
+package controller
+
+import (
+	"go-web-mini/config"
+	"go-web-mini/testutil"
+	"net/http"
+	"testing"
+)
+
+func TestBindWriteRequestStrictJson(t *testing.T) {
+	setupControllerTest(t)
+	bob := testutil.CreateUser(t, "bob", "passwd", testutil.CreateRole(t, "user", 3))
+	body := map[string]string{"mobile": bob.Mobile, "nikname": "Bob"}
+
+	// 默认忽略未知字段
+	c, w := newTestContext(t, bob, http.MethodPatch, "/api/user/profile", body)
+	NewUserController().UpdateProfile(c)
+	if code, msg := decodeTestResponse(t, w); code != http.StatusOK {
+		t.Fatalf("未开启严格JSON模式时返回%d %s, 期望为200", code, msg)
+	}
+
+	config.Conf.System.StrictJson = true
+	c, w = newTestContext(t, bob, http.MethodPatch, "/api/user/profile", body)
+	NewUserController().UpdateProfile(c)
+	if code, msg := decodeTestResponse(t, w); code != http.StatusBadRequest || msg != "请求参数中包含未知字段: nikname" {
+		t.Errorf("开启严格JSON模式时返回%d %s, 期望提示未知字段nikname", code, msg)
+	}
+}
@@ -0,0 +1,68 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"go-web-mini/common"
+	"go-web-mini/model"
+	"go-web-mini/repository"
+	"go-web-mini/testutil"
+	"net/http/httptest"
+	"testing"
+)
+
+// 初始化控制器测试使用的数据库、数据校验器和默认配置
+func setupControllerTest(t *testing.T) {
+	t.Helper()
+	testutil.SetupDB(t)
+	common.InitValidate()
+	gin.SetMode(gin.TestMode)
+}
+
+// 创建当前用户为ctxUser的测试请求, body为nil时没有请求体, params为路径参数(名称和值交替)
+func newTestContext(t *testing.T, ctxUser *model.User, method string, target string, body interface{}, params ...string) (*gin.Context, *httptest.ResponseRecorder) {
+	t.Helper()
+	var buf bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			t.Fatalf("序列化请求体失败: %v", err)
+		}
+	}
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(method, target, &buf)
+	c.Request.Header.Set("Content-Type", "application/json")
+	for i := 0; i+1 < len(params); i += 2 {
+		c.Params = append(c.Params, gin.Param{Key: params[i], Value: params[i+1]})
+	}
+	if ctxUser != nil {
+		// 每个测试使用新的数据库, 删除之前测试留下的用户信息缓存
+		repository.NewUserRepository().DeleteUserInfoCache(ctxUser.ID)
+		c.Set("user", model.User{Model: ctxUser.Model, Username: ctxUser.Username})
+	}
+	return c, w
+}
+
+// 解析测试请求的响应
+func decodeTestResponse(t *testing.T, w *httptest.ResponseRecorder) (int, string) {
+	t.Helper()
+	var resp struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("解析响应失败: %v, 响应为%s", err, w.Body.String())
+	}
+	return resp.Code, resp.Message
+}
+
+// 从数据库获取角色
+func loadTestRole(t *testing.T, id uint) model.Role {
+	t.Helper()
+	var role model.Role
+	if err := common.DB.First(&role, id).Error; err != nil {
+		t.Fatalf("获取测试角色失败: %v", err)
+	}
+	return role
+}
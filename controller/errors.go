@@ -0,0 +1,26 @@
+package controller
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"go-web-mini/repository"
+	"go-web-mini/response"
+)
+
+// 获取当前用户失败时返回前端, 未登录时返回401, 其他(如查询数据库失败)返回500
+func failCurrentUser(c *gin.Context, err error) {
+	if errors.Is(err, repository.ErrUserNotLoggedIn) {
+		response.Unauthorized(c, nil, err.Error())
+		return
+	}
+	response.ServerError(c, nil, err.Error())
+}
+
+// 保存数据失败时返回前端, 唯一索引冲突(数据已存在)返回400, 其他返回500
+func failSave(c *gin.Context, err error, message string) {
+	if errors.Is(err, repository.ErrDuplicateKey) {
+		response.Fail(c, nil, message+": "+err.Error())
+		return
+	}
+	response.ServerError(c, nil, message+": "+err.Error())
+}
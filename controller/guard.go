@@ -0,0 +1,41 @@
+package controller
+
+import (
+	"errors"
+	"go-web-mini/config"
+	"go-web-mini/model"
+)
+
+// 校验当前用户是否会因本次操作失去自己的最高等级角色(避免把自己锁在外面)
+// removedRoleIds 本次操作中被删除、禁用或移除的角色ID
+// 所有修改角色的操作(更新用户角色、批量删除角色、批量修改角色状态等)统一使用该校验
+func checkSelfRoleRemoval(ctxUser model.User, removedRoleIds []uint) error {
+	if !config.Conf.Security.SelfRoleGuard || len(removedRoleIds) == 0 {
+		return nil
+	}
+	removed := make(map[uint]bool, len(removedRoleIds))
+	for _, roleId := range removedRoleIds {
+		removed[roleId] = true
+	}
+
+	// 比较移除前后当前用户正常状态角色的最小排序
+	var currentMinSort, remainMinSort uint
+	for _, role := range ctxUser.Roles {
+		if role.Status != 1 || role.IsExpired() {
+			continue
+		}
+		if currentMinSort == 0 || role.Sort < currentMinSort {
+			currentMinSort = role.Sort
+		}
+		if !removed[role.ID] && (remainMinSort == 0 || role.Sort < remainMinSort) {
+			remainMinSort = role.Sort
+		}
+	}
+	if currentMinSort == 0 {
+		return nil
+	}
+	if remainMinSort == 0 || remainMinSort > currentMinSort {
+		return errors.New("不能移除自己的最高等级角色")
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package controller
+
+import (
+	"go-web-mini/common"
+	"go-web-mini/config"
+	"go-web-mini/model"
+	"go-web-mini/testutil"
+	"go-web-mini/vo"
+	"net/http"
+	"strconv"
+	"testing"
+)
+
+func TestCheckSelfRoleRemoval(t *testing.T) {
+	config.Conf.Security = &config.SecurityConfig{SelfRoleGuard: true}
+	admin := &model.Role{Status: 1, Sort: 1}
+	admin.ID = 1
+	backup := &model.Role{Status: 1, Sort: 1}
+	backup.ID = 2
+	user := &model.Role{Status: 1, Sort: 3}
+	user.ID = 3
+	ctxUser := model.User{Roles: []*model.Role{admin, user}}
+
+	if err := checkSelfRoleRemoval(ctxUser, []uint{admin.ID}); err == nil {
+		t.Error("移除自己的最高等级角色成功, 期望失败")
+	}
+	if err := checkSelfRoleRemoval(ctxUser, []uint{user.ID}); err != nil {
+		t.Errorf("移除非最高等级角色返回%v, 期望成功", err)
+	}
+	// 还有其他相同等级的角色
+	if err := checkSelfRoleRemoval(model.User{Roles: []*model.Role{admin, backup}}, []uint{admin.ID}); err != nil {
+		t.Errorf("还有相同等级的角色时返回%v, 期望成功", err)
+	}
+	config.Conf.Security.SelfRoleGuard = false
+	if err := checkSelfRoleRemoval(ctxUser, []uint{admin.ID}); err != nil {
+		t.Errorf("未开启校验时返回%v, 期望成功", err)
+	}
+}
+
+func TestUpdateUserByIdRejectsSelf(t *testing.T) {
+	setupControllerTest(t)
+	config.Conf.Security.SelfRoleGuard = true
+	admin := testutil.CreateRole(t, "admin", 1)
+	user := testutil.CreateRole(t, "user", 3)
+	alice := testutil.CreateUser(t, "alice", "passwd", admin, user)
+
+	// 在用户管理中更新自己(如移除自己的最高等级角色)直接拒绝
+	req := vo.CreateUserRequest{Username: "alice", Mobile: alice.Mobile, Status: 1, RoleIds: []uint{user.ID}}
+	c, w := newTestContext(t, alice, http.MethodPatch, "/api/user/update/1", req, "userId", strconv.Itoa(int(alice.ID)))
+	NewUserController().UpdateUserById(c)
+
+	if code, msg := decodeTestResponse(t, w); code != http.StatusForbidden || msg != "不能更新自己, 请到个人中心更新个人资料" {
+		t.Errorf("更新自己返回%d %s, 期望为403 不能更新自己, 请到个人中心更新个人资料", code, msg)
+	}
+	var count int64
+	common.DB.Table("user_roles").Where("user_id = ?", alice.ID).Count(&count)
+	if count != 2 {
+		t.Errorf("更新自己被拒绝后角色数量为%d, 期望不变", count)
+	}
+}
+
+func TestUpdateRoleByIdRejectsDisablingOwnTopRole(t *testing.T) {
+	setupControllerTest(t)
+	config.Conf.Security.SelfRoleGuard = true
+	admin := testutil.CreateRole(t, "admin", 1)
+	alice := testutil.CreateUser(t, "alice", "passwd", admin)
+
+	req := vo.CreateRoleRequest{Name: "admin", Keyword: "admin", Status: 2, Sort: 2}
+	c, w := newTestContext(t, alice, http.MethodPatch, "/api/role/update/1", req, "roleId", strconv.Itoa(int(admin.ID)))
+	NewRoleController().UpdateRoleById(c)
+
+	if code, _ := decodeTestResponse(t, w); code != http.StatusForbidden {
+		t.Errorf("禁用自己的最高等级角色返回%d, 期望为403", code)
+	}
+	if role := loadTestRole(t, admin.ID); role.Status != 1 {
+		t.Errorf("角色状态为%d, 期望不变", role.Status)
+	}
+}
+
+func TestCheckRoleBatchOperationRejectsOwnTopRole(t *testing.T) {
+	setupControllerTest(t)
+	config.Conf.Security.SelfRoleGuard = true
+	admin := testutil.CreateRole(t, "admin", 1)
+	other := testutil.CreateRole(t, "other", 3)
+	ctxUser := model.User{Roles: []*model.Role{admin}}
+
+	for _, operation := range []string{"role-delete", "role-disable"} {
+		results, err := checkRoleBatchOperation(ctxUser, 1, operation, []uint{admin.ID, other.ID})
+		if err != nil {
+			t.Fatalf("%s校验失败: %v", operation, err)
+		}
+		if results[0].Allowed || results[0].FailedCheck() == nil {
+			t.Errorf("%s自己的最高等级角色校验通过, 期望不通过", operation)
+		}
+		selfPassed := true
+		for _, check := range results[0].Checks {
+			if check.Check == "self" {
+				selfPassed = check.Passed
+			}
+		}
+		if selfPassed {
+			t.Errorf("%s自己的最高等级角色时未校验自我保护", operation)
+		}
+		if !results[1].Allowed {
+			t.Errorf("%s其他角色校验不通过: %s", operation, results[1].FailReason())
+		}
+	}
+}
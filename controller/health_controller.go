@@ -0,0 +1,74 @@
+package controller
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"go-web-mini/common"
+	"go-web-mini/repository"
+	"go-web-mini/response"
+	"net/http"
+	"time"
+)
+
+type IHealthController interface {
+	Health(c *gin.Context) // 存活检查
+	Ready(c *gin.Context)  // 就绪检查(数据库、缓存)
+}
+
+type HealthController struct {
+	UserRepository repository.IUserRepository
+}
+
+func NewHealthController() IHealthController {
+	userRepository := repository.NewUserRepository()
+	healthController := HealthController{UserRepository: userRepository}
+	return healthController
+}
+
+// 就绪检查访问数据库的超时时间
+const readyCheckTimeout = 3 * time.Second
+
+// 存活检查, 进程能响应请求即返回200
+func (hc HealthController) Health(c *gin.Context) {
+	response.Success(c, gin.H{"ok": true}, "服务正常")
+}
+
+// 就绪检查, 检查数据库和用户信息缓存是否可用
+// 任一依赖不可用时返回503, 让编排系统暂停向该实例转发请求
+func (hc HealthController) Ready(c *gin.Context) {
+	checks := gin.H{}
+	ok := true
+
+	dbStatus := "ok"
+	if err := pingDB(c.Request.Context()); err != nil {
+		dbStatus = err.Error()
+		ok = false
+	}
+	checks["database"] = dbStatus
+
+	cacheStatus := "ok"
+	if err := hc.UserRepository.PingUserInfoCache(); err != nil {
+		cacheStatus = err.Error()
+		ok = false
+	}
+	checks["cache"] = cacheStatus
+
+	if !ok {
+		common.Log.Warnf("就绪检查失败: %v", checks)
+		response.Response(c, http.StatusServiceUnavailable, http.StatusServiceUnavailable, gin.H{"ok": false, "checks": checks}, "服务未就绪")
+		return
+	}
+	response.Success(c, gin.H{"ok": true, "checks": checks}, "服务已就绪")
+}
+
+// 通过底层sql.DB执行SELECT 1检查数据库连接
+func pingDB(ctx context.Context) error {
+	sqlDB, err := common.DB.DB()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, readyCheckTimeout)
+	defer cancel()
+	var one int
+	return sqlDB.QueryRowContext(ctx, "SELECT 1").Scan(&one)
+}
@@ -36,7 +36,7 @@ func NewMenuController() IMenuController {
 func (mc MenuController) GetMenus(c *gin.Context) {
 	menus, err := mc.MenuRepository.GetMenus()
 	if err != nil {
-		response.Fail(c, nil, "获取菜单列表失败: "+err.Error())
+		response.ServerError(c, nil, "获取菜单列表失败: "+err.Error())
 		return
 	}
 	response.Success(c, gin.H{"menus": menus}, "获取菜单列表成功")
@@ -46,7 +46,7 @@ func (mc MenuController) GetMenus(c *gin.Context) {
 func (mc MenuController) GetMenuTree(c *gin.Context) {
 	menuTree, err := mc.MenuRepository.GetMenuTree()
 	if err != nil {
-		response.Fail(c, nil, "获取菜单树失败: "+err.Error())
+		response.ServerError(c, nil, "获取菜单树失败: "+err.Error())
 		return
 	}
 	response.Success(c, gin.H{"menuTree": menuTree}, "获取菜单树成功")
@@ -56,7 +56,7 @@ func (mc MenuController) GetMenuTree(c *gin.Context) {
 func (mc MenuController) CreateMenu(c *gin.Context) {
 	var req vo.CreateMenuRequest
 	// 参数绑定
-	if err := c.ShouldBind(&req); err != nil {
+	if err := bindWriteRequest(c, &req); err != nil {
 		response.Fail(c, nil, err.Error())
 		return
 	}
@@ -71,7 +71,7 @@ func (mc MenuController) CreateMenu(c *gin.Context) {
 	ur := repository.NewUserRepository()
 	ctxUser, err := ur.GetCurrentUser(c)
 	if err != nil {
-		response.Fail(c, nil, "获取当前用户信息失败")
+		failCurrentUser(c, err)
 		return
 	}
 
@@ -95,7 +95,7 @@ func (mc MenuController) CreateMenu(c *gin.Context) {
 
 	err = mc.MenuRepository.CreateMenu(&menu)
 	if err != nil {
-		response.Fail(c, nil, "创建菜单失败: "+err.Error())
+		failSave(c, err, "创建菜单失败")
 		return
 	}
 	response.Success(c, nil, "创建菜单成功")
@@ -105,7 +105,7 @@ func (mc MenuController) CreateMenu(c *gin.Context) {
 func (mc MenuController) UpdateMenuById(c *gin.Context) {
 	var req vo.UpdateMenuRequest
 	// 参数绑定
-	if err := c.ShouldBind(&req); err != nil {
+	if err := bindWriteRequest(c, &req); err != nil {
 		response.Fail(c, nil, err.Error())
 		return
 	}
@@ -127,7 +127,7 @@ func (mc MenuController) UpdateMenuById(c *gin.Context) {
 	ur := repository.NewUserRepository()
 	ctxUser, err := ur.GetCurrentUser(c)
 	if err != nil {
-		response.Fail(c, nil, "获取当前用户信息失败")
+		failCurrentUser(c, err)
 		return
 	}
 
@@ -151,9 +151,11 @@ func (mc MenuController) UpdateMenuById(c *gin.Context) {
 
 	err = mc.MenuRepository.UpdateMenuById(uint(menuId), &menu)
 	if err != nil {
-		response.Fail(c, nil, "更新菜单失败: "+err.Error())
+		failSave(c, err, "更新菜单失败")
 		return
 	}
+	// 清理用户权限缓存(缓存中包含菜单树)
+	ur.ClearUserPermissionCache()
 
 	response.Success(c, nil, "更新菜单成功")
 
@@ -163,7 +165,7 @@ func (mc MenuController) UpdateMenuById(c *gin.Context) {
 func (mc MenuController) BatchDeleteMenuByIds(c *gin.Context) {
 	var req vo.DeleteMenuRequest
 	// 参数绑定
-	if err := c.ShouldBind(&req); err != nil {
+	if err := bindWriteRequest(c, &req); err != nil {
 		response.Fail(c, nil, err.Error())
 		return
 	}
@@ -175,9 +177,11 @@ func (mc MenuController) BatchDeleteMenuByIds(c *gin.Context) {
 	}
 	err := mc.MenuRepository.BatchDeleteMenuByIds(req.MenuIds)
 	if err != nil {
-		response.Fail(c, nil, "删除菜单失败: "+err.Error())
+		response.ServerError(c, nil, "删除菜单失败: "+err.Error())
 		return
 	}
+	// 清理用户权限缓存(缓存中包含菜单树)
+	repository.NewUserRepository().ClearUserPermissionCache()
 
 	response.Success(c, nil, "删除菜单成功")
 }
@@ -193,7 +197,7 @@ func (mc MenuController) GetUserMenusByUserId(c *gin.Context) {
 
 	menus, err := mc.MenuRepository.GetUserMenusByUserId(uint(userId))
 	if err != nil {
-		response.Fail(c, nil, "获取用户的可访问菜单列表失败: "+err.Error())
+		response.ServerError(c, nil, "获取用户的可访问菜单列表失败: "+err.Error())
 		return
 	}
 	response.Success(c, gin.H{"menus": menus}, "获取用户的可访问菜单列表成功")
@@ -210,7 +214,7 @@ func (mc MenuController) GetUserMenuTreeByUserId(c *gin.Context) {
 
 	menuTree, err := mc.MenuRepository.GetUserMenuTreeByUserId(uint(userId))
 	if err != nil {
-		response.Fail(c, nil, "获取用户的可访问菜单树失败: "+err.Error())
+		response.ServerError(c, nil, "获取用户的可访问菜单树失败: "+err.Error())
 		return
 	}
 	response.Success(c, gin.H{"menuTree": menuTree}, "获取用户的可访问菜单树成功")
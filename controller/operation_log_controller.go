@@ -7,11 +7,13 @@ import (
 	"go-web-mini/repository"
 	"go-web-mini/response"
 	"go-web-mini/vo"
+	"strconv"
 )
 
 type IOperationLogController interface {
 	GetOperationLogs(c *gin.Context)             // 获取操作日志列表
 	BatchDeleteOperationLogByIds(c *gin.Context) //批量删除操作日志
+	GetUserAuditTrail(c *gin.Context)            // 获取对指定用户的操作记录
 }
 
 type OperationLogController struct {
@@ -41,17 +43,18 @@ func (oc OperationLogController) GetOperationLogs(c *gin.Context) {
 	// 获取
 	logs, total, err := oc.operationLogRepository.GetOperationLogs(&req)
 	if err != nil {
-		response.Fail(c, nil, "获取操作日志列表失败: "+err.Error())
+		response.ServerError(c, nil, "获取操作日志列表失败: "+err.Error())
 		return
 	}
-	response.Success(c, gin.H{"logs": logs, "total": total}, "获取操作日志列表成功")
+	page := response.NewPageData(c, total, req.PageNum, req.PageSize)
+	response.SuccessPage(c, "logs", logs, page, "获取操作日志列表成功")
 }
 
 // 批量删除操作日志
 func (oc OperationLogController) BatchDeleteOperationLogByIds(c *gin.Context) {
 	var req vo.DeleteOperationLogRequest
 	// 参数绑定
-	if err := c.ShouldBind(&req); err != nil {
+	if err := bindWriteRequest(c, &req); err != nil {
 		response.Fail(c, nil, err.Error())
 		return
 	}
@@ -65,9 +68,33 @@ func (oc OperationLogController) BatchDeleteOperationLogByIds(c *gin.Context) {
 	// 删除接口
 	err := oc.operationLogRepository.BatchDeleteOperationLogByIds(req.OperationLogIds)
 	if err != nil {
-		response.Fail(c, nil, "删除日志失败: "+err.Error())
+		response.ServerError(c, nil, "删除日志失败: "+err.Error())
 		return
 	}
 
 	response.Success(c, nil, "删除日志成功")
 }
+
+// 获取对指定用户的操作记录(创建、更新、删除等), 按时间倒序
+func (oc OperationLogController) GetUserAuditTrail(c *gin.Context) {
+	var req vo.UserAuditTrailRequest
+	// 绑定参数
+	if err := c.ShouldBind(&req); err != nil {
+		response.Fail(c, nil, err.Error())
+		return
+	}
+	// 获取path中的userId
+	userId, _ := strconv.Atoi(c.Param("userId"))
+	if userId <= 0 {
+		response.Fail(c, nil, "用户ID不正确")
+		return
+	}
+
+	logs, total, err := oc.operationLogRepository.GetOperationLogsByTarget("user", uint(userId), req.PageNum, req.PageSize)
+	if err != nil {
+		response.ServerError(c, nil, "获取用户操作记录失败: "+err.Error())
+		return
+	}
+	page := response.NewPageData(c, total, req.PageNum, req.PageSize)
+	response.SuccessPage(c, "logs", logs, page, "获取用户操作记录成功")
+}
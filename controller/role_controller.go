@@ -1,11 +1,14 @@
 package controller
 
 import (
+	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
 	"github.com/thoas/go-funk"
 	"go-web-mini/common"
+	"go-web-mini/config"
+	"go-web-mini/dto"
 	"go-web-mini/model"
 	"go-web-mini/repository"
 	"go-web-mini/response"
@@ -22,6 +25,13 @@ type IRoleController interface {
 	GetRoleApisById(c *gin.Context)      // 获取角色的权限接口
 	UpdateRoleApisById(c *gin.Context)   // 更新角色的权限接口
 	BatchDeleteRoleByIds(c *gin.Context) // 批量删除角色
+
+	GetRolePermissions(c *gin.Context) // 获取角色的全部有效权限(包括继承的权限)
+	BatchSetRoleStatus(c *gin.Context) // 批量修改角色状态
+
+	GetRolePolicyChangeLogs(c *gin.Context) // 获取角色权限接口变更记录
+	PreviewDisableRole(c *gin.Context)      // 预览禁用角色的影响
+	GetRoleUsers(c *gin.Context)            // 获取拥有该角色的用户列表
 }
 
 type RoleController struct {
@@ -52,17 +62,18 @@ func (rc RoleController) GetRoles(c *gin.Context) {
 	// 获取角色列表
 	roles, total, err := rc.RoleRepository.GetRoles(&req)
 	if err != nil {
-		response.Fail(c, nil, "获取角色列表失败: "+err.Error())
+		response.ServerError(c, nil, "获取角色列表失败: "+err.Error())
 		return
 	}
-	response.Success(c, gin.H{"roles": roles, "total": total}, "获取角色列表成功")
+	page := response.NewPageData(c, total, int(req.PageNum), int(req.PageSize))
+	response.SuccessPage(c, "roles", roles, page, "获取角色列表成功")
 }
 
 // 创建角色
 func (rc RoleController) CreateRole(c *gin.Context) {
 	var req vo.CreateRoleRequest
 	// 参数绑定
-	if err := c.ShouldBind(&req); err != nil {
+	if err := bindWriteRequest(c, &req); err != nil {
 		response.Fail(c, nil, err.Error())
 		return
 	}
@@ -77,32 +88,46 @@ func (rc RoleController) CreateRole(c *gin.Context) {
 	uc := repository.NewUserRepository()
 	sort, ctxUser, err := uc.GetCurrentUserMinRoleSort(c)
 	if err != nil {
-		response.Fail(c, nil, "获取当前用户最高角色等级失败: "+err.Error())
+		failCurrentUser(c, err)
 		return
 	}
 
 	// 用户不能创建比自己等级高或相同等级的角色
 	if sort >= req.Sort {
-		response.Fail(c, nil, "不能创建比自己等级高或相同等级的角色")
+		response.Forbidden(c, nil, "不能创建比自己等级高或相同等级的角色")
+		return
+	}
+
+	// 校验父角色
+	if err := rc.checkParentRole(0, req.ParentRoleId, sort); err != nil {
+		response.Fail(c, nil, err.Error())
+		return
+	}
+
+	// 校验角色排序是否重复
+	sortWarning, err := rc.checkRoleSortConflict(0, req.Sort)
+	if err != nil {
+		response.Fail(c, sortWarning, err.Error())
 		return
 	}
 
 	role := model.Role{
-		Name:    req.Name,
-		Keyword: req.Keyword,
-		Desc:    &req.Desc,
-		Status:  req.Status,
-		Sort:    req.Sort,
-		Creator: ctxUser.Username,
+		Name:         req.Name,
+		Keyword:      req.Keyword,
+		Desc:         &req.Desc,
+		Status:       req.Status,
+		Sort:         req.Sort,
+		Creator:      ctxUser.Username,
+		ParentRoleId: req.ParentRoleId,
 	}
 
 	// 创建角色
 	err = rc.RoleRepository.CreateRole(&role)
 	if err != nil {
-		response.Fail(c, nil, "创建角色失败: "+err.Error())
+		failSave(c, err, "创建角色失败")
 		return
 	}
-	response.Success(c, nil, "创建角色成功")
+	response.Success(c, sortWarning, "创建角色成功")
 
 }
 
@@ -110,7 +135,7 @@ func (rc RoleController) CreateRole(c *gin.Context) {
 func (rc RoleController) UpdateRoleById(c *gin.Context) {
 	var req vo.CreateRoleRequest
 	// 参数绑定
-	if err := c.ShouldBind(&req); err != nil {
+	if err := bindWriteRequest(c, &req); err != nil {
 		response.Fail(c, nil, err.Error())
 		return
 	}
@@ -131,7 +156,7 @@ func (rc RoleController) UpdateRoleById(c *gin.Context) {
 	ur := repository.NewUserRepository()
 	minSort, ctxUser, err := ur.GetCurrentUserMinRoleSort(c)
 	if err != nil {
-		response.Fail(c, nil, err.Error())
+		failCurrentUser(c, err)
 		return
 	}
 
@@ -139,7 +164,7 @@ func (rc RoleController) UpdateRoleById(c *gin.Context) {
 	// 根据path中的角色ID获取该角色信息
 	roles, err := rc.RoleRepository.GetRolesByIds([]uint{uint(roleId)})
 	if err != nil {
-		response.Fail(c, nil, err.Error())
+		response.ServerError(c, nil, err.Error())
 		return
 	}
 	if len(roles) == 0 {
@@ -147,29 +172,51 @@ func (rc RoleController) UpdateRoleById(c *gin.Context) {
 		return
 	}
 	if minSort >= roles[0].Sort {
-		response.Fail(c, nil, "不能更新比自己角色等级高或相等的角色")
+		response.Forbidden(c, nil, "不能更新比自己角色等级高或相等的角色")
 		return
 	}
 
 	// 不能把角色等级更新得比当前用户的等级高
 	if minSort >= req.Sort {
-		response.Fail(c, nil, "不能把角色等级更新得比当前用户的等级高或相同")
+		response.Forbidden(c, nil, "不能把角色等级更新得比当前用户的等级高或相同")
+		return
+	}
+
+	// 不能禁用自己的最高等级角色
+	if req.Status == 2 {
+		if err := checkSelfRoleRemoval(ctxUser, []uint{uint(roleId)}); err != nil {
+			response.Forbidden(c, nil, err.Error())
+			return
+		}
+	}
+
+	// 校验父角色
+	if err := rc.checkParentRole(uint(roleId), req.ParentRoleId, minSort); err != nil {
+		response.Fail(c, nil, err.Error())
+		return
+	}
+
+	// 校验角色排序是否重复
+	sortWarning, err := rc.checkRoleSortConflict(uint(roleId), req.Sort)
+	if err != nil {
+		response.Fail(c, sortWarning, err.Error())
 		return
 	}
 
 	role := model.Role{
-		Name:    req.Name,
-		Keyword: req.Keyword,
-		Desc:    &req.Desc,
-		Status:  req.Status,
-		Sort:    req.Sort,
-		Creator: ctxUser.Username,
+		Name:         req.Name,
+		Keyword:      req.Keyword,
+		Desc:         &req.Desc,
+		Status:       req.Status,
+		Sort:         req.Sort,
+		Creator:      ctxUser.Username,
+		ParentRoleId: req.ParentRoleId,
 	}
 
 	// 更新角色
 	err = rc.RoleRepository.UpdateRoleById(uint(roleId), &role)
 	if err != nil {
-		response.Fail(c, nil, "更新角色失败: "+err.Error())
+		failSave(c, err, "更新角色失败")
 		return
 	}
 
@@ -178,7 +225,7 @@ func (rc RoleController) UpdateRoleById(c *gin.Context) {
 		// 获取policy
 		rolePolicies := common.CasbinEnforcer.GetFilteredPolicy(0, roles[0].Keyword)
 		if len(rolePolicies) == 0 {
-			response.Success(c, nil, "更新角色成功")
+			response.Success(c, sortWarning, "更新角色成功")
 			return
 		}
 		rolePoliciesCopy := make([][]string, 0)
@@ -200,17 +247,17 @@ func (rc RoleController) UpdateRoleById(c *gin.Context) {
 		// 这里需要先新增再删除（先删除再增加会出错）
 		isAdded, _ := common.CasbinEnforcer.AddPolicies(rolePolicies)
 		if !isAdded {
-			response.Fail(c, nil, "更新角色成功，但角色关键字关联的权限接口更新失败")
+			response.ServerError(c, nil, "更新角色成功，但角色关键字关联的权限接口更新失败")
 			return
 		}
 		isRemoved, _ := common.CasbinEnforcer.RemovePolicies(rolePoliciesCopy)
 		if !isRemoved {
-			response.Fail(c, nil, "更新角色成功，但角色关键字关联的权限接口更新失败")
+			response.ServerError(c, nil, "更新角色成功，但角色关键字关联的权限接口更新失败")
 			return
 		}
 		err := common.CasbinEnforcer.LoadPolicy()
 		if err != nil {
-			response.Fail(c, nil, "更新角色成功，但角色关键字关联角色的权限接口策略加载失败")
+			response.ServerError(c, nil, "更新角色成功，但角色关键字关联角色的权限接口策略加载失败")
 			return
 		}
 
@@ -222,7 +269,7 @@ func (rc RoleController) UpdateRoleById(c *gin.Context) {
 	// 2.直接清理缓存，让活跃的用户自己重新缓存最新用户信息
 	ur.ClearUserInfoCache()
 
-	response.Success(c, nil, "更新角色成功")
+	response.Success(c, sortWarning, "更新角色成功")
 }
 
 // 获取角色的权限菜单
@@ -235,7 +282,7 @@ func (rc RoleController) GetRoleMenusById(c *gin.Context) {
 	}
 	menus, err := rc.RoleRepository.GetRoleMenusById(uint(roleId))
 	if err != nil {
-		response.Fail(c, nil, "获取角色的权限菜单失败: "+err.Error())
+		response.ServerError(c, nil, "获取角色的权限菜单失败: "+err.Error())
 		return
 	}
 	response.Success(c, gin.H{"menus": menus}, "获取角色的权限菜单成功")
@@ -245,7 +292,7 @@ func (rc RoleController) GetRoleMenusById(c *gin.Context) {
 func (rc RoleController) UpdateRoleMenusById(c *gin.Context) {
 	var req vo.UpdateRoleMenusRequest
 	// 参数绑定
-	if err := c.ShouldBind(&req); err != nil {
+	if err := bindWriteRequest(c, &req); err != nil {
 		response.Fail(c, nil, err.Error())
 		return
 	}
@@ -264,7 +311,7 @@ func (rc RoleController) UpdateRoleMenusById(c *gin.Context) {
 	// 根据path中的角色ID获取该角色信息
 	roles, err := rc.RoleRepository.GetRolesByIds([]uint{uint(roleId)})
 	if err != nil {
-		response.Fail(c, nil, err.Error())
+		response.ServerError(c, nil, err.Error())
 		return
 	}
 	if len(roles) == 0 {
@@ -276,14 +323,14 @@ func (rc RoleController) UpdateRoleMenusById(c *gin.Context) {
 	ur := repository.NewUserRepository()
 	minSort, ctxUser, err := ur.GetCurrentUserMinRoleSort(c)
 	if err != nil {
-		response.Fail(c, nil, err.Error())
+		failCurrentUser(c, err)
 		return
 	}
 
 	// (非管理员)不能更新比自己角色等级高或相等角色的权限菜单
 	if minSort != 1 {
 		if minSort >= roles[0].Sort {
-			response.Fail(c, nil, "不能更新比自己角色等级高或相等角色的权限菜单")
+			response.Forbidden(c, nil, "不能更新比自己角色等级高或相等角色的权限菜单")
 			return
 		}
 	}
@@ -292,7 +339,7 @@ func (rc RoleController) UpdateRoleMenusById(c *gin.Context) {
 	mr := repository.NewMenuRepository()
 	ctxUserMenus, err := mr.GetUserMenusByUserId(ctxUser.ID)
 	if err != nil {
-		response.Fail(c, nil, "获取当前用户的可访问菜单列表失败: "+err.Error())
+		response.ServerError(c, nil, "获取当前用户的可访问菜单列表失败: "+err.Error())
 		return
 	}
 
@@ -312,7 +359,7 @@ func (rc RoleController) UpdateRoleMenusById(c *gin.Context) {
 	if minSort != 1 {
 		for _, id := range menuIds {
 			if !funk.Contains(ctxUserMenusIds, id) {
-				response.Fail(c, nil, fmt.Sprintf("无权设置ID为%d的菜单", id))
+				response.Forbidden(c, nil, fmt.Sprintf("无权设置ID为%d的菜单", id))
 				return
 			}
 		}
@@ -330,7 +377,7 @@ func (rc RoleController) UpdateRoleMenusById(c *gin.Context) {
 		// 根据menuIds查询查询菜单
 		menus, err := mr.GetMenus()
 		if err != nil {
-			response.Fail(c, nil, "获取菜单列表失败: "+err.Error())
+			response.ServerError(c, nil, "获取菜单列表失败: "+err.Error())
 			return
 		}
 		for _, menuId := range menuIds {
@@ -346,9 +393,11 @@ func (rc RoleController) UpdateRoleMenusById(c *gin.Context) {
 
 	err = rc.RoleRepository.UpdateRoleMenus(roles[0])
 	if err != nil {
-		response.Fail(c, nil, "更新角色的权限菜单失败: "+err.Error())
+		response.ServerError(c, nil, "更新角色的权限菜单失败: "+err.Error())
 		return
 	}
+	// 清理用户权限缓存
+	ur.ClearUserPermissionCache()
 
 	response.Success(c, nil, "更新角色的权限菜单成功")
 
@@ -365,7 +414,7 @@ func (rc RoleController) GetRoleApisById(c *gin.Context) {
 	// 根据path中的角色ID获取该角色信息
 	roles, err := rc.RoleRepository.GetRolesByIds([]uint{uint(roleId)})
 	if err != nil {
-		response.Fail(c, nil, err.Error())
+		response.ServerError(c, nil, err.Error())
 		return
 	}
 	if len(roles) == 0 {
@@ -376,7 +425,7 @@ func (rc RoleController) GetRoleApisById(c *gin.Context) {
 	keyword := roles[0].Keyword
 	apis, err := rc.RoleRepository.GetRoleApisByRoleKeyword(keyword)
 	if err != nil {
-		response.Fail(c, nil, err.Error())
+		response.ServerError(c, nil, err.Error())
 		return
 	}
 	response.Success(c, gin.H{"apis": apis}, "获取角色的权限接口成功")
@@ -386,7 +435,7 @@ func (rc RoleController) GetRoleApisById(c *gin.Context) {
 func (rc RoleController) UpdateRoleApisById(c *gin.Context) {
 	var req vo.UpdateRoleApisRequest
 	// 参数绑定
-	if err := c.ShouldBind(&req); err != nil {
+	if err := bindWriteRequest(c, &req); err != nil {
 		response.Fail(c, nil, err.Error())
 		return
 	}
@@ -406,7 +455,7 @@ func (rc RoleController) UpdateRoleApisById(c *gin.Context) {
 	// 根据path中的角色ID获取该角色信息
 	roles, err := rc.RoleRepository.GetRolesByIds([]uint{uint(roleId)})
 	if err != nil {
-		response.Fail(c, nil, err.Error())
+		response.ServerError(c, nil, err.Error())
 		return
 	}
 	if len(roles) == 0 {
@@ -418,14 +467,14 @@ func (rc RoleController) UpdateRoleApisById(c *gin.Context) {
 	ur := repository.NewUserRepository()
 	minSort, ctxUser, err := ur.GetCurrentUserMinRoleSort(c)
 	if err != nil {
-		response.Fail(c, nil, err.Error())
+		failCurrentUser(c, err)
 		return
 	}
 
 	// (非管理员)不能更新比自己角色等级高或相等角色的权限接口
 	if minSort != 1 {
 		if minSort >= roles[0].Sort {
-			response.Fail(c, nil, "不能更新比自己角色等级高或相等角色的权限接口")
+			response.Forbidden(c, nil, "不能更新比自己角色等级高或相等角色的权限接口")
 			return
 		}
 	}
@@ -448,7 +497,7 @@ func (rc RoleController) UpdateRoleApisById(c *gin.Context) {
 	ar := repository.NewApiRepository()
 	apis, err := ar.GetApisById(apiIds)
 	if err != nil {
-		response.Fail(c, nil, "根据接口ID获取接口信息失败")
+		response.ServerError(c, nil, "根据接口ID获取接口信息失败")
 		return
 	}
 	// 生成前端想要设置的角色policies
@@ -463,18 +512,20 @@ func (rc RoleController) UpdateRoleApisById(c *gin.Context) {
 	if minSort != 1 {
 		for _, reqPolicy := range reqRolePolicies {
 			if !funk.Contains(ctxRolesPolicies, reqPolicy) {
-				response.Fail(c, nil, fmt.Sprintf("无权设置路径为%s,请求方式为%s的接口", reqPolicy[1], reqPolicy[2]))
+				response.Forbidden(c, nil, fmt.Sprintf("无权设置路径为%s,请求方式为%s的接口", reqPolicy[1], reqPolicy[2]))
 				return
 			}
 		}
 	}
 
 	// 更新角色的权限接口
-	err = rc.RoleRepository.UpdateRoleApis(roles[0].Keyword, reqRolePolicies)
+	err = rc.RoleRepository.UpdateRoleApis(roles[0], reqRolePolicies, ctxUser.Username)
 	if err != nil {
-		response.Fail(c, nil, err.Error())
+		response.ServerError(c, nil, err.Error())
 		return
 	}
+	// 清理用户权限缓存
+	ur.ClearUserPermissionCache()
 
 	response.Success(c, nil, "更新角色的权限接口成功")
 
@@ -484,7 +535,7 @@ func (rc RoleController) UpdateRoleApisById(c *gin.Context) {
 func (rc RoleController) BatchDeleteRoleByIds(c *gin.Context) {
 	var req vo.DeleteRoleRequest
 	// 参数绑定
-	if err := c.ShouldBind(&req); err != nil {
+	if err := bindWriteRequest(c, &req); err != nil {
 		response.Fail(c, nil, err.Error())
 		return
 	}
@@ -497,29 +548,24 @@ func (rc RoleController) BatchDeleteRoleByIds(c *gin.Context) {
 
 	// 获取当前用户最高等级角色
 	ur := repository.NewUserRepository()
-	minSort, _, err := ur.GetCurrentUserMinRoleSort(c)
+	minSort, ctxUser, err := ur.GetCurrentUserMinRoleSort(c)
 	if err != nil {
-		response.Fail(c, nil, err.Error())
+		failCurrentUser(c, err)
 		return
 	}
 
 	// 前端传来需要删除的角色ID
 	roleIds := req.RoleIds
-	// 获取角色信息
-	roles, err := rc.RoleRepository.GetRolesByIds(roleIds)
+
+	// 不能删除比自己角色等级高或相等的角色, 不能删除自己的最高等级角色
+	results, err := checkRoleBatchOperation(ctxUser, minSort, "role-delete", roleIds)
 	if err != nil {
-		response.Fail(c, nil, "获取角色信息失败: "+err.Error())
-		return
-	}
-	if len(roles) == 0 {
-		response.Fail(c, nil, "未获取到角色信息")
+		response.ServerError(c, nil, "获取角色信息失败: "+err.Error())
 		return
 	}
-
-	// 不能删除比自己角色等级高或相等的角色
-	for _, role := range roles {
-		if minSort >= role.Sort {
-			response.Fail(c, nil, "不能删除比自己角色等级高或相等的角色")
+	for _, result := range results {
+		if !result.Allowed {
+			failBatchCheck(c, result)
 			return
 		}
 	}
@@ -527,7 +573,7 @@ func (rc RoleController) BatchDeleteRoleByIds(c *gin.Context) {
 	// 删除角色
 	err = rc.RoleRepository.BatchDeleteRoleByIds(roleIds)
 	if err != nil {
-		response.Fail(c, nil, "删除角色失败")
+		response.ServerError(c, nil, "删除角色失败")
 		return
 	}
 
@@ -536,3 +582,349 @@ func (rc RoleController) BatchDeleteRoleByIds(c *gin.Context) {
 	response.Success(c, nil, "删除角色成功")
 
 }
+
+// 校验父角色
+// roleId 当前角色ID(新增角色时为0), minSort 当前用户角色排序最小值
+func (rc RoleController) checkParentRole(roleId uint, parentRoleId *uint, minSort uint) error {
+	if parentRoleId == nil {
+		return nil
+	}
+	parentRoles, err := rc.RoleRepository.GetRolesByIds([]uint{*parentRoleId})
+	if err != nil {
+		return err
+	}
+	if len(parentRoles) == 0 {
+		return errors.New("未获取到父角色信息")
+	}
+	// 不能继承比自己等级高或相同等级的角色
+	if minSort >= parentRoles[0].Sort {
+		return errors.New("不能继承比自己等级高或相同等级的角色")
+	}
+	// 父角色的继承链中不能包含当前角色
+	if roleId > 0 {
+		chainIds, err := rc.RoleRepository.GetRoleInheritChainIds(*parentRoleId)
+		if err != nil {
+			return err
+		}
+		if funk.Contains(chainIds, roleId) {
+			return errors.New("角色继承关系不能形成循环")
+		}
+	}
+	return nil
+}
+
+// 获取角色的全部有效权限(包括继承的权限)
+// 用于权限审计, 不需要给用户分配该角色即可查看角色的全部接口权限和菜单
+func (rc RoleController) GetRolePermissions(c *gin.Context) {
+	var req vo.RolePermissionsRequest
+	// 参数绑定
+	if err := c.ShouldBind(&req); err != nil {
+		response.Fail(c, nil, err.Error())
+		return
+	}
+	// 获取path中的roleId
+	roleId, _ := strconv.Atoi(c.Param("roleId"))
+	if roleId <= 0 {
+		response.Fail(c, nil, "角色ID不正确")
+		return
+	}
+	roles, err := rc.RoleRepository.GetRolesByIds([]uint{uint(roleId)})
+	if err != nil {
+		response.ServerError(c, nil, "获取角色信息失败: "+err.Error())
+		return
+	}
+	if len(roles) == 0 {
+		response.Fail(c, nil, "未获取到角色信息")
+		return
+	}
+
+	// 当前用户角色排序最小值（最高等级角色）
+	ur := repository.NewUserRepository()
+	minSort, _, err := ur.GetCurrentUserMinRoleSort(c)
+	if err != nil {
+		failCurrentUser(c, err)
+		return
+	}
+	// 不能查看比自己角色等级高或相等的角色的权限
+	if minSort >= roles[0].Sort {
+		response.Forbidden(c, nil, "不能查看比自己角色等级高或相等的角色的权限")
+		return
+	}
+
+	// 开启角色继承时加上继承的角色
+	effectiveRoles, err := rc.RoleRepository.GetEffectiveRoles(roles)
+	if err != nil {
+		response.ServerError(c, nil, "获取角色的继承关系失败: "+err.Error())
+		return
+	}
+
+	apis := make([]*model.Api, 0)
+	menus := make([]*model.Menu, 0)
+	apiIds := make(map[uint]bool)
+	menuIds := make(map[uint]bool)
+	for _, role := range effectiveRoles {
+		roleApis, err := rc.RoleRepository.GetRoleApisByRoleKeyword(role.Keyword)
+		if err != nil {
+			response.ServerError(c, nil, "获取角色的权限接口失败: "+err.Error())
+			return
+		}
+		for _, api := range roleApis {
+			if !apiIds[api.ID] {
+				apiIds[api.ID] = true
+				apis = append(apis, api)
+			}
+		}
+		roleMenus, err := rc.RoleRepository.GetRoleMenusById(role.ID)
+		if err != nil {
+			response.ServerError(c, nil, "获取角色的权限菜单失败: "+err.Error())
+			return
+		}
+		for _, menu := range roleMenus {
+			if !menuIds[menu.ID] {
+				menuIds[menu.ID] = true
+				menus = append(menus, menu)
+			}
+		}
+	}
+	data := gin.H{"roles": effectiveRoles, "apis": apis, "menus": menus}
+	if req.WithSummary {
+		if err := rc.RoleRepository.FillRoleSummaries(roles); err != nil {
+			response.ServerError(c, nil, "获取角色的权限概要失败: "+err.Error())
+			return
+		}
+		data["summary"] = gin.H{"menuCount": len(roles[0].Menus), "policyCount": roles[0].PolicyCount}
+	}
+	response.Success(c, data, "获取角色的全部权限成功")
+}
+
+// 校验角色排序是否与其他角色重复
+// 排序重复时返回提示信息和建议的排序, 配置为reject时同时返回错误
+func (rc RoleController) checkRoleSortConflict(roleId uint, sort uint) (gin.H, error) {
+	policy := config.Conf.Security.RoleSortConflict
+	if policy == "allow" {
+		return nil, nil
+	}
+	conflictRoles, err := rc.RoleRepository.GetRolesBySort(sort, roleId)
+	if err != nil {
+		return nil, err
+	}
+	if len(conflictRoles) == 0 {
+		return nil, nil
+	}
+	suggestedSort, err := rc.RoleRepository.GetNextFreeRoleSort(sort)
+	if err != nil {
+		return nil, err
+	}
+	warning := fmt.Sprintf("角色排序%d与角色[%s]重复, 建议使用排序%d", sort, conflictRoles[0].Name, suggestedSort)
+	data := gin.H{"sortWarning": warning, "suggestedSort": suggestedSort}
+	if policy == "reject" {
+		return data, errors.New(warning)
+	}
+	return data, nil
+}
+
+// 批量修改角色状态
+// 逐个校验角色等级, 返回每个角色的处理结果
+func (rc RoleController) BatchSetRoleStatus(c *gin.Context) {
+	var req vo.BatchSetRoleStatusRequest
+	// 参数绑定
+	if err := bindWriteRequest(c, &req); err != nil {
+		response.Fail(c, nil, err.Error())
+		return
+	}
+	// 参数校验
+	if err := common.Validate.Struct(&req); err != nil {
+		errStr := err.(validator.ValidationErrors)[0].Translate(common.Trans)
+		response.Fail(c, nil, errStr)
+		return
+	}
+
+	// 当前用户角色排序最小值（最高等级角色）
+	ur := repository.NewUserRepository()
+	minSort, ctxUser, err := ur.GetCurrentUserMinRoleSort(c)
+	if err != nil {
+		failCurrentUser(c, err)
+		return
+	}
+
+	// 不能修改比自己角色等级高或相等的角色, 禁用时不能禁用自己的最高等级角色
+	operation := "role-enable"
+	if req.Status == 2 {
+		operation = "role-disable"
+	}
+	checkResults, err := checkRoleBatchOperation(ctxUser, minSort, operation, req.RoleIds)
+	if err != nil {
+		response.ServerError(c, nil, "获取角色信息失败: "+err.Error())
+		return
+	}
+
+	results := make([]*dto.BatchResultDto, 0, len(checkResults))
+	allowedIds := make([]uint, 0, len(checkResults))
+	for _, checkResult := range checkResults {
+		if !checkResult.Allowed {
+			results = append(results, &dto.BatchResultDto{Id: checkResult.Id, Message: checkResult.FailReason()})
+			continue
+		}
+		allowedIds = append(allowedIds, checkResult.Id)
+		results = append(results, &dto.BatchResultDto{Id: checkResult.Id, Success: true})
+	}
+
+	// 只返回修改后的角色, 前端根据返回的角色更新本地数据, 不需要重新获取列表
+	evictedCount := 0
+	updatedRoles := make([]*model.Role, 0)
+	if len(allowedIds) > 0 {
+		updatedRoles, evictedCount, err = rc.RoleRepository.BatchSetRoleStatus(allowedIds, req.Status)
+		if err != nil {
+			response.ServerError(c, nil, "批量修改角色状态失败: "+err.Error())
+			return
+		}
+		// 开启角色继承时子角色的用户权限同样变化, 清理所有用户权限缓存
+		if config.Conf.Security.RoleInheritance {
+			ur.ClearUserPermissionCache()
+		}
+	}
+	response.Success(c, gin.H{"results": results, "roles": updatedRoles, "evictedUserCount": evictedCount}, "批量修改角色状态完成")
+}
+
+// 获取角色权限接口变更记录
+func (rc RoleController) GetRolePolicyChangeLogs(c *gin.Context) {
+	var req vo.RolePolicyChangeLogRequest
+	// 参数绑定
+	if err := c.ShouldBind(&req); err != nil {
+		response.Fail(c, nil, err.Error())
+		return
+	}
+	// 获取path中的roleId
+	roleId, _ := strconv.Atoi(c.Param("roleId"))
+	if roleId <= 0 {
+		response.Fail(c, nil, "角色ID不正确")
+		return
+	}
+	roles, err := rc.RoleRepository.GetRolesByIds([]uint{uint(roleId)})
+	if err != nil {
+		response.ServerError(c, nil, "获取角色信息失败: "+err.Error())
+		return
+	}
+	if len(roles) == 0 {
+		response.Fail(c, nil, "未获取到角色信息")
+		return
+	}
+
+	// 当前用户角色排序最小值（最高等级角色）
+	ur := repository.NewUserRepository()
+	minSort, _, err := ur.GetCurrentUserMinRoleSort(c)
+	if err != nil {
+		failCurrentUser(c, err)
+		return
+	}
+	// (非管理员)不能查看比自己角色等级高或相等的角色的权限变更记录
+	if minSort != 1 && minSort >= roles[0].Sort {
+		response.Forbidden(c, nil, "不能查看比自己角色等级高或相等的角色的权限变更记录")
+		return
+	}
+
+	logs, total, err := rc.RoleRepository.GetPolicyChangeLogs(uint(roleId), req.PageNum, req.PageSize)
+	if err != nil {
+		response.ServerError(c, nil, "获取角色权限接口变更记录失败: "+err.Error())
+		return
+	}
+	page := response.NewPageData(c, total, req.PageNum, req.PageSize)
+	response.SuccessPage(c, "logs", logs, page, "获取角色权限接口变更记录成功")
+}
+
+// 预览禁用角色的影响
+// 返回禁用该角色后将因没有正常状态的角色而无法登录的用户, 不修改数据
+func (rc RoleController) PreviewDisableRole(c *gin.Context) {
+	// 获取path中的roleId
+	roleId, _ := strconv.Atoi(c.Param("roleId"))
+	if roleId <= 0 {
+		response.Fail(c, nil, "角色ID不正确")
+		return
+	}
+	roles, err := rc.RoleRepository.GetRolesByIds([]uint{uint(roleId)})
+	if err != nil {
+		response.ServerError(c, nil, "获取角色信息失败: "+err.Error())
+		return
+	}
+	if len(roles) == 0 {
+		response.Fail(c, nil, "未获取到角色信息")
+		return
+	}
+
+	// 当前用户角色排序最小值（最高等级角色）
+	ur := repository.NewUserRepository()
+	minSort, _, err := ur.GetCurrentUserMinRoleSort(c)
+	if err != nil {
+		failCurrentUser(c, err)
+		return
+	}
+	// 不能预览比自己角色等级高或相等的角色
+	if minSort >= roles[0].Sort {
+		response.Forbidden(c, nil, "不能预览禁用比自己角色等级高或相等的角色")
+		return
+	}
+
+	users, err := rc.RoleRepository.PreviewDisableRole(uint(roleId))
+	if err != nil {
+		response.ServerError(c, nil, "预览禁用角色的影响失败: "+err.Error())
+		return
+	}
+	response.Success(c, gin.H{
+		"roleId":        roleId,
+		"affectedCount": len(users),
+		"users":         dto.ToUsersDto(users),
+	}, "预览禁用角色的影响成功")
+}
+
+// 获取拥有该角色的用户列表
+// 查询条件和分页与获取用户列表相同
+func (rc RoleController) GetRoleUsers(c *gin.Context) {
+	var req vo.UserListRequest
+	// 参数绑定
+	if err := c.ShouldBind(&req); err != nil {
+		response.Fail(c, nil, err.Error())
+		return
+	}
+	// 参数校验
+	if err := common.Validate.Struct(&req); err != nil {
+		errStr := err.(validator.ValidationErrors)[0].Translate(common.Trans)
+		response.Fail(c, nil, errStr)
+		return
+	}
+	// 获取path中的roleId
+	roleId, _ := strconv.Atoi(c.Param("roleId"))
+	if roleId <= 0 {
+		response.Fail(c, nil, "角色ID不正确")
+		return
+	}
+	roles, err := rc.RoleRepository.GetRolesByIds([]uint{uint(roleId)})
+	if err != nil {
+		response.ServerError(c, nil, "获取角色信息失败: "+err.Error())
+		return
+	}
+	if len(roles) == 0 {
+		response.Fail(c, nil, "未获取到角色信息")
+		return
+	}
+
+	// 当前用户角色排序最小值（最高等级角色）
+	ur := repository.NewUserRepository()
+	minSort, _, err := ur.GetCurrentUserMinRoleSort(c)
+	if err != nil {
+		failCurrentUser(c, err)
+		return
+	}
+	// (非管理员)不能查看比自己角色等级高或相等的角色的用户
+	if minSort != 1 && minSort >= roles[0].Sort {
+		response.Forbidden(c, nil, "不能查看比自己角色等级高或相等的角色的用户")
+		return
+	}
+
+	users, total, err := ur.GetUsersByRoleId(c.Request.Context(), uint(roleId), &req)
+	if err != nil {
+		response.ServerError(c, nil, "获取角色的用户列表失败: "+err.Error())
+		return
+	}
+	page := response.NewPageData(c, total, int(req.PageNum), int(req.PageSize))
+	response.SuccessPage(c, "users", dto.ToUsersDto(users), page, "获取角色的用户列表成功")
+}
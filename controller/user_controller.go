@@ -1,37 +1,94 @@
 package controller
 
 import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
 	"github.com/thoas/go-funk"
 	"go-web-mini/common"
 	"go-web-mini/config"
 	"go-web-mini/dto"
+	"go-web-mini/middleware"
 	"go-web-mini/model"
 	"go-web-mini/repository"
 	"go-web-mini/response"
+	"go-web-mini/storage"
 	"go-web-mini/util"
 	"go-web-mini/vo"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"path/filepath"
+	"sort"
 	"strconv"
+	"strings"
+	"sync"
+	"time"
 )
 
 type IUserController interface {
 	GetUserInfo(c *gin.Context)          // 获取当前登录用户信息
 	GetUsers(c *gin.Context)             // 获取用户列表
+	StreamUsers(c *gin.Context)          // 流式导出用户列表
+	ExportUsers(c *gin.Context)          // 导出用户列表(CSV)
 	ChangePwd(c *gin.Context)            // 更新用户登录密码
+	GetMySessions(c *gin.Context)        // 获取当前用户的登录会话
+	RevokeSession(c *gin.Context)        // 退出当前用户的指定登录会话
+	EnrollTwoFactor(c *gin.Context)      // 生成当前用户的两步验证密钥
+	VerifyTwoFactor(c *gin.Context)      // 校验验证码并开启两步验证
+	DisableTwoFactor(c *gin.Context)     // 关闭两步验证
+	UploadAvatar(c *gin.Context)         // 上传当前用户的头像
+	UpdateProfile(c *gin.Context)        // 更新当前用户的个人资料
 	CreateUser(c *gin.Context)           // 创建用户
 	UpdateUserById(c *gin.Context)       // 更新用户
+	ResetUserPassword(c *gin.Context)    // 重置其他用户的密码
+	UpdateUserStatus(c *gin.Context)     // 更新用户状态(启用/禁用)
+	BatchUpdateStatus(c *gin.Context)    // 批量更新用户状态(启用/禁用)
 	BatchDeleteUserByIds(c *gin.Context) // 批量删除用户
+	GetDeletedUsers(c *gin.Context)      // 获取已删除的用户列表(回收站)
+	RestoreUsers(c *gin.Context)         // 批量恢复已删除的用户
+	WarmUserInfoCache(c *gin.Context)    // 预热用户信息缓存
+
+	GetUsersWithExpiringRoles(c *gin.Context) // 获取角色即将过期的用户列表
+	UpdateUserRoleExpiresAt(c *gin.Context)   // 更新用户角色的过期时间
+	BatchAssignRole(c *gin.Context)           // 为多个用户分配同一个角色
+
+	MergeUsers(c *gin.Context) // 合并用户
+
+	CheckPasswordStrength(c *gin.Context) // 检测密码强度
+
+	ValidateImportUsers(c *gin.Context) // 校验导入用户数据
+	GetImportTemplate(c *gin.Context)   // 下载导入用户模板
+	ImportUsers(c *gin.Context)         // 导入用户(xlsx或CSV文件)
+
+	GetUserAvailableRoles(c *gin.Context) // 获取用户可添加的角色
+
+	EvictUserCache(c *gin.Context) // 删除指定用户的用户信息缓存
+
+	CheckBatchPermission(c *gin.Context) // 批量操作权限校验(只返回校验结果, 不做修改)
+
+	ExportUserData(c *gin.Context) // 导出用户的全部数据(数据主体访问请求)
+
+	GetInitialSetupStatus(c *gin.Context) // 获取是否需要首次安装初始化
+	InitialSetup(c *gin.Context)          // 首次安装初始化, 创建超级管理员
 }
 
 type UserController struct {
 	UserRepository repository.IUserRepository
+	Storage        storage.Storage
 }
 
 // 构造函数
 func NewUserController() IUserController {
 	userRepository := repository.NewUserRepository()
-	userController := UserController{UserRepository: userRepository}
+	userController := UserController{UserRepository: userRepository, Storage: storage.Store}
 	return userController
 }
 
@@ -39,12 +96,26 @@ func NewUserController() IUserController {
 func (uc UserController) GetUserInfo(c *gin.Context) {
 	user, err := uc.UserRepository.GetCurrentUser(c)
 	if err != nil {
-		response.Fail(c, nil, "获取当前用户信息失败: "+err.Error())
+		failCurrentUser(c, err)
 		return
 	}
 	userInfoDto := dto.ToUserInfoDto(user)
+	// 权限指纹, 变化时前端需要重新加载菜单和权限
+	fingerprint, err := uc.UserRepository.GetUserPermissionFingerprint(user)
+	if err != nil {
+		response.ServerError(c, nil, "获取当前用户权限指纹失败: "+err.Error())
+		return
+	}
+	// 角色的接口权限和菜单树, 前端用于渲染导航和按钮
+	permissions, err := uc.UserRepository.GetUserPermissions(user)
+	if err != nil {
+		response.ServerError(c, nil, "获取当前用户权限失败: "+err.Error())
+		return
+	}
 	response.Success(c, gin.H{
-		"userInfo": userInfoDto,
+		"userInfo":              userInfoDto,
+		"permissionFingerprint": fingerprint,
+		"permissions":           permissions,
 	}, "获取当前用户信息成功")
 }
 
@@ -59,17 +130,178 @@ func (uc UserController) GetUsers(c *gin.Context) {
 	// 参数校验
 	if err := common.Validate.Struct(&req); err != nil {
 		errStr := err.(validator.ValidationErrors)[0].Translate(common.Trans)
-		response.Fail(c, nil, errStr)
+		response.Fail(c, gin.H{"errors": common.TranslateAll(err)}, errStr)
 		return
 	}
 
+	// 开启后非超级管理员必须提供查询条件
+	if config.Conf.User.ListRequireFilter && !req.HasFilter() {
+		minSort, _, err := uc.UserRepository.GetCurrentUserMinRoleSort(c)
+		if err != nil {
+			failCurrentUser(c, err)
+			return
+		}
+		if minSort != 1 {
+			response.Fail(c, nil, "请至少提供一个查询条件")
+			return
+		}
+	}
+
 	// 获取
-	users, total, err := uc.UserRepository.GetUsers(&req)
+	users, total, err := uc.UserRepository.GetUsers(c.Request.Context(), &req)
+	if err != nil {
+		// 客户端已断开连接, 停止处理且不再返回响应
+		if util.IsContextDone(err) {
+			common.Log.Warnf("获取用户列表已中止, 请求已取消: %v", err)
+			c.Abort()
+			return
+		}
+		response.ServerError(c, nil, "获取用户列表失败: "+err.Error())
+		return
+	}
+	page := response.NewPageData(c, total, int(req.PageNum), int(req.PageSize))
+	// 集成方请求只返回允许的字段
+	response.SuccessPage(c, "users", dto.ToUsersDto(users), page, "获取用户列表成功")
+}
+
+// 流式导出用户列表
+// 使用与获取用户列表相同的查询条件但不分页, 每行一个用户JSON(NDJSON), 以分块传输编码逐批返回
+func (uc UserController) StreamUsers(c *gin.Context) {
+	var req vo.UserListRequest
+	// 参数绑定
+	if err := c.ShouldBind(&req); err != nil {
+		response.Fail(c, nil, err.Error())
+		return
+	}
+	// 参数校验
+	if err := common.Validate.Struct(&req); err != nil {
+		errStr := err.(validator.ValidationErrors)[0].Translate(common.Trans)
+		response.Fail(c, nil, errStr)
+		return
+	}
+
+	encoder := json.NewEncoder(c.Writer)
+	count := 0
+	err := uc.UserRepository.StreamUsers(c.Request.Context(), &req, func(user *model.User) error {
+		// 第一行写出前设置响应头, 之前出错仍可返回普通错误响应
+		if count == 0 {
+			c.Header("Content-Type", "application/x-ndjson; charset=utf-8")
+			c.Header("X-Content-Type-Options", "nosniff")
+			c.Status(http.StatusOK)
+		}
+		userDto := dto.ToUsersDto([]*model.User{user})[0]
+		if err := encoder.Encode(response.FilterUserData(c, userDto)); err != nil {
+			return err
+		}
+		count++
+		if count%100 == 0 {
+			c.Writer.Flush()
+		}
+		return nil
+	})
+	if err != nil {
+		if count == 0 && !util.IsContextDone(err) {
+			response.ServerError(c, nil, "导出用户列表失败: "+err.Error())
+			return
+		}
+		// 已开始输出或客户端已断开连接, 只能中止输出
+		common.Log.Warnf("流式导出用户列表已中止, 已输出%d条: %v", count, err)
+		c.Abort()
+		return
+	}
+	if count == 0 {
+		c.Header("Content-Type", "application/x-ndjson; charset=utf-8")
+		c.Status(http.StatusOK)
+	}
+	c.Writer.Flush()
+}
+
+// 导出用户列表(CSV)
+// 查询条件与获取用户列表相同, 忽略分页参数导出全部符合条件的用户, 不包含密码
+func (uc UserController) ExportUsers(c *gin.Context) {
+	var req vo.UserListRequest
+	// 参数绑定
+	if err := c.ShouldBind(&req); err != nil {
+		response.Fail(c, nil, err.Error())
+		return
+	}
+	// 参数校验
+	if err := common.Validate.Struct(&req); err != nil {
+		errStr := err.(validator.ValidationErrors)[0].Translate(common.Trans)
+		response.Fail(c, nil, errStr)
+		return
+	}
+
+	// pageNum为0时不分页
+	req.PageNum = 0
+	req.PageSize = 0
+	users, _, err := uc.UserRepository.GetUsers(c.Request.Context(), &req)
 	if err != nil {
-		response.Fail(c, nil, "获取用户列表失败: "+err.Error())
+		// 客户端已断开连接, 停止处理且不再返回响应
+		if util.IsContextDone(err) {
+			common.Log.Warnf("导出用户列表已中止, 请求已取消: %v", err)
+			c.Abort()
+			return
+		}
+		response.ServerError(c, nil, "导出用户列表失败: "+err.Error())
 		return
 	}
-	response.Success(c, gin.H{"users": dto.ToUsersDto(users), "total": total}, "获取用户列表成功")
+
+	filename := fmt.Sprintf("users_%s.csv", time.Now().Format("20060102150405"))
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Status(http.StatusOK)
+	// 写入UTF-8 BOM, 避免Excel打开中文乱码
+	_, _ = c.Writer.WriteString("\xEF\xBB\xBF")
+	// 集成方请求只导出API Key允许的字段
+	fields := common.GetApiKeyUserFields(c)
+	columns := make([]int, 0, len(exportUserColumns))
+	header := make([]string, 0, len(exportUserColumns))
+	for i, column := range exportUserColumns {
+		if dto.FieldAllowed(fields, column.field) {
+			columns = append(columns, i)
+			header = append(header, column.name)
+		}
+	}
+	w := csv.NewWriter(c.Writer)
+	_ = w.Write(header)
+	for _, user := range users {
+		nickname := ""
+		if user.Nickname != nil {
+			nickname = *user.Nickname
+		}
+		values := []string{
+			user.Username,
+			nickname,
+			user.Mobile,
+			strconv.Itoa(int(user.Status)),
+			user.CreatedAt.Format("2006-01-02 15:04:05"),
+		}
+		record := make([]string, 0, len(columns))
+		for _, i := range columns {
+			// 用户名、昵称等由用户填写, 转义后防止公式注入
+			record = append(record, util.EscapeCsvCell(values[i]))
+		}
+		_ = w.Write(record)
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		// 已开始输出, 只能中止输出
+		common.Log.Warnf("导出用户列表已中止: %v", err)
+		c.Abort()
+	}
+}
+
+// 导出用户列表的列, field为对应的json字段名(用于集成方字段白名单)
+var exportUserColumns = []struct {
+	name  string
+	field string
+}{
+	{"username", "username"},
+	{"nickname", "nickname"},
+	{"mobile", "mobile"},
+	{"status", "status"},
+	{"created_at", "createdAt"},
 }
 
 // 更新用户登录密码
@@ -77,14 +309,14 @@ func (uc UserController) ChangePwd(c *gin.Context) {
 	var req vo.ChangePwdRequest
 
 	// 参数绑定
-	if err := c.ShouldBind(&req); err != nil {
+	if err := bindWriteRequest(c, &req); err != nil {
 		response.Fail(c, nil, err.Error())
 		return
 	}
 	// 参数校验
 	if err := common.Validate.Struct(&req); err != nil {
 		errStr := err.(validator.ValidationErrors)[0].Translate(common.Trans)
-		response.Fail(c, nil, errStr)
+		response.Fail(c, gin.H{"errors": common.TranslateAll(err)}, errStr)
 		return
 	}
 
@@ -102,11 +334,16 @@ func (uc UserController) ChangePwd(c *gin.Context) {
 	}
 	req.OldPassword = string(decodeOldPassword)
 	req.NewPassword = string(decodeNewPassword)
+	// 校验新密码强度
+	if err := validatePassword(req.NewPassword); err != nil {
+		response.Fail(c, nil, err.Error())
+		return
+	}
 
 	// 获取当前用户
 	user, err := uc.UserRepository.GetCurrentUser(c)
 	if err != nil {
-		response.Fail(c, nil, err.Error())
+		failCurrentUser(c, err)
 		return
 	}
 	// 获取用户的真实正确密码
@@ -117,29 +354,339 @@ func (uc UserController) ChangePwd(c *gin.Context) {
 		response.Fail(c, nil, "原密码有误")
 		return
 	}
+	// 不能与最近使用过的密码相同
+	reused, err := uc.UserRepository.IsRecentPassword(user, req.NewPassword)
+	if err != nil {
+		response.ServerError(c, nil, "获取历史密码失败: "+err.Error())
+		return
+	}
+	if reused {
+		response.Fail(c, nil, fmt.Sprintf("新密码不能与最近%d次使用的密码相同", config.Conf.Security.PasswordHistoryCount))
+		return
+	}
 	// 更新密码
 	err = uc.UserRepository.ChangePwd(user.Username, util.GenPasswd(req.NewPassword))
 	if err != nil {
-		response.Fail(c, nil, "更新密码失败: "+err.Error())
+		response.ServerError(c, nil, "更新密码失败: "+err.Error())
 		return
 	}
+	// 已证明拥有该账号, 清除登录失败锁定
+	common.ResetUserLoginFailures(user.Username)
+	middleware.SetOperationLogTarget(c, "user", user.ID)
+	middleware.SetOperationLogAction(c, "user-change-password", nil)
 	response.Success(c, nil, "更新密码成功")
 }
 
+// 获取当前用户的登录会话(本节点记录的未过期会话), 当前请求使用的会话标记为current
+func (uc UserController) GetMySessions(c *gin.Context) {
+	user, err := uc.UserRepository.GetCurrentUser(c)
+	if err != nil {
+		failCurrentUser(c, err)
+		return
+	}
+	response.Success(c, gin.H{"sessions": userSessionDtos(c, user.ID)}, "获取登录会话成功")
+}
+
+// 用户的登录会话, 当前请求使用的会话标记为current
+func userSessionDtos(c *gin.Context, userId uint) []dto.UserSessionDto {
+	currentTokenId := middleware.CurrentTokenId(c)
+	sessions := common.GetUserSessions(userId)
+	list := make([]dto.UserSessionDto, 0, len(sessions))
+	for _, session := range sessions {
+		list = append(list, dto.UserSessionDto{
+			TokenId:    session.TokenId,
+			Ip:         session.Ip,
+			UserAgent:  session.UserAgent,
+			LoginAt:    session.LoginAt,
+			LastSeenAt: session.LastSeenAt,
+			Current:    session.TokenId == currentTokenId,
+		})
+	}
+	return list
+}
+
+// 退出当前用户的指定登录会话(如其他设备), 该会话的token加入黑名单
+func (uc UserController) RevokeSession(c *gin.Context) {
+	user, err := uc.UserRepository.GetCurrentUser(c)
+	if err != nil {
+		failCurrentUser(c, err)
+		return
+	}
+	tokenId := c.Param("tokenId")
+	if !common.RevokeUserSession(user.ID, tokenId) {
+		response.Fail(c, nil, "登录会话不存在或已过期")
+		return
+	}
+	middleware.SetOperationLogTarget(c, "user", user.ID)
+	middleware.SetOperationLogAction(c, "user-revoke-session", nil)
+	response.Success(c, nil, "退出登录会话成功")
+}
+
+// 生成当前用户的两步验证密钥, 返回密钥和otpauth地址(前端生成二维码)
+// 校验验证码后才开启两步验证, 未开启时重复调用会重新生成密钥
+func (uc UserController) EnrollTwoFactor(c *gin.Context) {
+	user, err := uc.UserRepository.GetCurrentUser(c)
+	if err != nil {
+		failCurrentUser(c, err)
+		return
+	}
+	if user.TwoFactorEnabled {
+		response.Fail(c, nil, "已开启两步验证, 请先关闭")
+		return
+	}
+	secret, err := util.GenerateTOTPSecret()
+	if err != nil {
+		response.ServerError(c, nil, "生成两步验证密钥失败: "+err.Error())
+		return
+	}
+	encrypted, err := util.RSAEncrypt([]byte(secret), config.Conf.System.RSAPublicBytes)
+	if err != nil {
+		response.ServerError(c, nil, "生成两步验证密钥失败: "+err.Error())
+		return
+	}
+	if err := uc.UserRepository.UpdateTwoFactor(user.ID, string(encrypted), false); err != nil {
+		response.ServerError(c, nil, "生成两步验证密钥失败: "+err.Error())
+		return
+	}
+	middleware.SetOperationLogTarget(c, "user", user.ID)
+	middleware.SetOperationLogAction(c, "user-2fa-enroll", nil)
+	response.Success(c, gin.H{
+		"secret": secret,
+		"url":    util.TOTPAuthURL(config.Conf.Security.TwoFactorIssuer, user.Username, secret),
+	}, "生成两步验证密钥成功")
+}
+
+// 校验验证码并开启两步验证
+func (uc UserController) VerifyTwoFactor(c *gin.Context) {
+	var req vo.TwoFactorCodeRequest
+	user, ok := uc.checkTwoFactorCode(c, &req)
+	if !ok {
+		return
+	}
+	if user.TwoFactorEnabled {
+		response.Fail(c, nil, "已开启两步验证")
+		return
+	}
+	if err := uc.UserRepository.UpdateTwoFactor(user.ID, user.TwoFactorSecret, true); err != nil {
+		response.ServerError(c, nil, "开启两步验证失败: "+err.Error())
+		return
+	}
+	middleware.SetOperationLogTarget(c, "user", user.ID)
+	middleware.SetOperationLogAction(c, "user-2fa-enable", nil)
+	response.Success(c, nil, "开启两步验证成功")
+}
+
+// 关闭两步验证, 需要校验验证码, 同时删除密钥
+func (uc UserController) DisableTwoFactor(c *gin.Context) {
+	var req vo.TwoFactorCodeRequest
+	user, ok := uc.checkTwoFactorCode(c, &req)
+	if !ok {
+		return
+	}
+	if !user.TwoFactorEnabled {
+		response.Fail(c, nil, "未开启两步验证")
+		return
+	}
+	if err := uc.UserRepository.UpdateTwoFactor(user.ID, "", false); err != nil {
+		response.ServerError(c, nil, "关闭两步验证失败: "+err.Error())
+		return
+	}
+	middleware.SetOperationLogTarget(c, "user", user.ID)
+	middleware.SetOperationLogAction(c, "user-2fa-disable", nil)
+	response.Success(c, nil, "关闭两步验证成功")
+}
+
+// 校验当前用户的两步验证验证码, 校验失败时已返回响应
+func (uc UserController) checkTwoFactorCode(c *gin.Context, req *vo.TwoFactorCodeRequest) (model.User, bool) {
+	if err := bindWriteRequest(c, req); err != nil {
+		response.Fail(c, nil, err.Error())
+		return model.User{}, false
+	}
+	if err := common.Validate.Struct(req); err != nil {
+		errStr := err.(validator.ValidationErrors)[0].Translate(common.Trans)
+		response.Fail(c, nil, errStr)
+		return model.User{}, false
+	}
+	user, err := uc.UserRepository.GetCurrentUser(c)
+	if err != nil {
+		failCurrentUser(c, err)
+		return model.User{}, false
+	}
+	if user.TwoFactorSecret == "" {
+		response.Fail(c, nil, "请先生成两步验证密钥")
+		return model.User{}, false
+	}
+	secret, err := util.RSADecrypt([]byte(user.TwoFactorSecret), config.Conf.System.RSAPrivateBytes)
+	if err != nil || !util.ValidateTOTP(string(secret), req.Code, time.Now()) {
+		response.Fail(c, nil, "验证码错误")
+		return model.User{}, false
+	}
+	return user, true
+}
+
+// 更新当前用户的个人资料(昵称、头像、手机号和简介), 不能修改用户名、角色、状态和密码
+// 不受角色等级限制, 修改的字段同样受user.field-permissions限制
+func (uc UserController) UpdateProfile(c *gin.Context) {
+	var req vo.UpdateProfileRequest
+	// 参数绑定
+	if err := bindWriteRequest(c, &req); err != nil {
+		response.Fail(c, nil, err.Error())
+		return
+	}
+	// 参数校验
+	if err := common.Validate.Struct(&req); err != nil {
+		errStr := err.(validator.ValidationErrors)[0].Translate(common.Trans)
+		response.Fail(c, gin.H{"errors": common.TranslateAll(err)}, errStr)
+		return
+	}
+
+	user, err := uc.UserRepository.GetCurrentUser(c)
+	if err != nil {
+		failCurrentUser(c, err)
+		return
+	}
+	changedFields := map[string]bool{
+		"mobile":       req.Mobile != user.Mobile,
+		"avatar":       req.Avatar != user.Avatar,
+		"nickname":     stringPtrChanged(user.Nickname, req.Nickname),
+		"introduction": stringPtrChanged(user.Introduction, req.Introduction),
+	}
+	if err := checkChangedFieldPermissions(user, changedFields); err != nil {
+		response.Forbidden(c, nil, err.Error())
+		return
+	}
+	// 头像只能为http(s)地址或上传的头像路径, 未修改时不校验
+	if changedFields["avatar"] {
+		if err := validateAvatar(req.Avatar); err != nil {
+			response.Fail(c, nil, err.Error())
+			return
+		}
+	}
+	// 手机号不能与其他用户重复
+	if err := checkUserConflict(uc.UserRepository, user.Username, req.Mobile, user.ID); err != nil {
+		response.Fail(c, nil, err.Error())
+		return
+	}
+
+	user.Mobile = req.Mobile
+	user.Avatar = req.Avatar
+	user.Nickname = &req.Nickname
+	user.Introduction = &req.Introduction
+	if err := uc.UserRepository.UpdateProfile(&user); err != nil {
+		response.Fail(c, nil, "更新个人资料失败: "+err.Error())
+		return
+	}
+	middleware.SetOperationLogTarget(c, "user", user.ID)
+	middleware.SetOperationLogAction(c, "user-update-profile", req)
+	response.Success(c, gin.H{"userInfo": dto.ToUserInfoDto(user)}, "更新个人资料成功")
+}
+
+// 允许上传的头像格式(按文件内容识别) -> 文件扩展名
+var avatarContentTypes = map[string]string{
+	"image/png":  ".png",
+	"image/jpeg": ".jpg",
+}
+
+// 上传当前用户的头像(表单字段file), 保存到文件存储的avatar目录, 返回头像地址
+// 按文件内容识别格式, 只支持png、jpg, 大小不能超过user.avatar.max-size
+func (uc UserController) UploadAvatar(c *gin.Context) {
+	conf := config.Conf.User.Avatar
+	if conf == nil || conf.MaxSize <= 0 || uc.Storage == nil {
+		response.Fail(c, nil, "未开启头像上传")
+		return
+	}
+	maxSize := conf.MaxSize * 1024
+	// 限制请求体大小, 超出时解析表单失败, 多出的1MB用于表单的其他内容
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxSize+1<<20)
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		response.Fail(c, nil, fmt.Sprintf("请上传头像, 头像大小不能超过%dKB", conf.MaxSize))
+		return
+	}
+	if fileHeader.Size > maxSize {
+		response.Fail(c, nil, fmt.Sprintf("头像大小不能超过%dKB", conf.MaxSize))
+		return
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		response.Fail(c, nil, "读取头像失败: "+err.Error())
+		return
+	}
+	defer file.Close()
+
+	// 按文件内容识别格式, 不信任文件名和请求头中的类型
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		response.Fail(c, nil, "读取头像失败: "+err.Error())
+		return
+	}
+	ext, ok := avatarContentTypes[http.DetectContentType(head[:n])]
+	if !ok {
+		response.Fail(c, nil, "头像只支持png、jpg格式")
+		return
+	}
+
+	user, err := uc.UserRepository.GetCurrentUser(c)
+	if err != nil {
+		failCurrentUser(c, err)
+		return
+	}
+	key := fmt.Sprintf("%s%d_%d%s", avatarKeyPrefix, user.ID, time.Now().UnixNano(), ext)
+	avatar, err := uc.Storage.Put(key, io.MultiReader(bytes.NewReader(head[:n]), file))
+	if err != nil {
+		response.ServerError(c, nil, "保存头像失败: "+err.Error())
+		return
+	}
+	if err := uc.UserRepository.UpdateAvatar(user.ID, avatar); err != nil {
+		uc.Storage.Delete(key)
+		response.ServerError(c, nil, "更新头像失败: "+err.Error())
+		return
+	}
+	// 删除之前上传的头像文件(头像为其他地址时不处理)
+	if oldKey, ok := uploadedAvatarKey(user); ok {
+		if err := uc.Storage.Delete(oldKey); err != nil {
+			common.Log.Warnf("删除用户%s之前的头像失败: %v", user.Username, err)
+		}
+	}
+	middleware.SetOperationLogTarget(c, "user", user.ID)
+	middleware.SetOperationLogAction(c, "user-upload-avatar", gin.H{"avatar": avatar})
+	response.Success(c, gin.H{"avatar": avatar}, "上传头像成功")
+}
+
+// 头像在文件存储中的key前缀
+const avatarKeyPrefix = storage.AvatarDir + "/"
+
+// 获取用户之前上传的头像在文件存储中的key, 头像不是该用户上传的文件时返回false
+func uploadedAvatarKey(user model.User) (string, bool) {
+	filename := path.Base(user.Avatar)
+	if !strings.HasPrefix(filename, fmt.Sprintf("%d_", user.ID)) {
+		return "", false
+	}
+	for _, ext := range avatarContentTypes {
+		if strings.HasSuffix(filename, ext) {
+			return avatarKeyPrefix + filename, true
+		}
+	}
+	return "", false
+}
+
 // 创建用户
 func (uc UserController) CreateUser(c *gin.Context) {
 	var req vo.CreateUserRequest
 	// 参数绑定
-	if err := c.ShouldBind(&req); err != nil {
+	if err := bindWriteRequest(c, &req); err != nil {
 		response.Fail(c, nil, err.Error())
 		return
 	}
 	// 参数校验
 	if err := common.Validate.Struct(&req); err != nil {
 		errStr := err.(validator.ValidationErrors)[0].Translate(common.Trans)
-		response.Fail(c, nil, errStr)
+		response.Fail(c, gin.H{"errors": common.TranslateAll(err)}, errStr)
 		return
 	}
+	// 用户名不区分大小写, 统一保存为小写
+	req.Username = util.NormalizeUsername(req.Username)
 
 	// 密码通过RSA解密
 	// 密码不为空就解密
@@ -150,8 +697,8 @@ func (uc UserController) CreateUser(c *gin.Context) {
 			return
 		}
 		req.Password = string(decodeData)
-		if len(req.Password) < 6 {
-			response.Fail(c, nil, "密码长度至少为6位")
+		if err := validatePassword(req.Password); err != nil {
+			response.Fail(c, nil, err.Error())
 			return
 		}
 	}
@@ -159,7 +706,7 @@ func (uc UserController) CreateUser(c *gin.Context) {
 	// 当前用户角色排序最小值（最高等级角色）以及当前用户
 	currentRoleSortMin, ctxUser, err := uc.UserRepository.GetCurrentUserMinRoleSort(c)
 	if err != nil {
-		response.Fail(c, nil, err.Error())
+		failCurrentUser(c, err)
 		return
 	}
 
@@ -169,29 +716,53 @@ func (uc UserController) CreateUser(c *gin.Context) {
 	rr := repository.NewRoleRepository()
 	roles, err := rr.GetRolesByIds(reqRoleIds)
 	if err != nil {
-		response.Fail(c, nil, "根据角色ID获取角色信息失败: "+err.Error())
+		response.ServerError(c, nil, "根据角色ID获取角色信息失败: "+err.Error())
 		return
 	}
 	if len(roles) == 0 {
 		response.Fail(c, nil, "未获取到角色信息")
 		return
 	}
+	// 校验无效的角色ID
+	if err := checkInvalidRoleIds(reqRoleIds, roles); err != nil {
+		response.Fail(c, nil, err.Error())
+		return
+	}
 	var reqRoleSorts []int
 	for _, role := range roles {
 		reqRoleSorts = append(reqRoleSorts, int(role.Sort))
 	}
 	// 前端传来用户角色排序最小值（最高等级角色）
-	reqRoleSortMin := uint(funk.MinInt(reqRoleSorts).(int))
+	reqRoleSortMin := uint(999)
+	if len(reqRoleSorts) > 0 {
+		reqRoleSortMin = uint(funk.MinInt(reqRoleSorts).(int))
+	}
 
 	// 当前用户的角色排序最小值 需要小于 前端传来的角色排序最小值（用户不能创建比自己等级高的或者相同等级的用户）
 	if currentRoleSortMin >= reqRoleSortMin {
-		response.Fail(c, nil, "用户不能创建比自己等级高的或者相同等级的用户")
+		response.Forbidden(c, nil, "用户不能创建比自己等级高的或者相同等级的用户")
 		return
 	}
 
-	// 密码为空就默认123456
-	if req.Password == "" {
-		req.Password = "123456"
+	// 用户名、手机号不能重复
+	if err := checkUserConflict(uc.UserRepository, req.Username, req.Mobile, 0); err != nil {
+		response.Fail(c, nil, err.Error())
+		return
+	}
+
+	// 密码为空就使用默认密码, 并要求用户下次登录时修改密码
+	passwordResetRequired := req.Password == ""
+	generatedPassword := ""
+	if passwordResetRequired {
+		password, generated, err := defaultPassword()
+		if err != nil {
+			response.ServerError(c, nil, "生成随机密码失败: "+err.Error())
+			return
+		}
+		req.Password = password
+		if generated {
+			generatedPassword = password
+		}
 	}
 	user := model.User{
 		Username:     req.Username,
@@ -203,6 +774,8 @@ func (uc UserController) CreateUser(c *gin.Context) {
 		Status:       req.Status,
 		Creator:      ctxUser.Username,
 		Roles:        roles,
+
+		PasswordResetRequired: passwordResetRequired,
 	}
 
 	err = uc.UserRepository.CreateUser(&user)
@@ -210,24 +783,34 @@ func (uc UserController) CreateUser(c *gin.Context) {
 		response.Fail(c, nil, "创建用户失败: "+err.Error())
 		return
 	}
+	// 记录操作日志的操作对象
+	middleware.SetOperationLogTarget(c, "user", user.ID)
+	middleware.SetOperationLogAction(c, "user-create", req)
+	// 随机生成的密码只在这里返回一次, 由管理员告知用户
+	if generatedPassword != "" {
+		response.Success(c, gin.H{"password": generatedPassword}, "创建用户成功")
+		return
+	}
 	response.Success(c, nil, "创建用户成功")
 
 }
 
-// 更新用户
+// 更新用户(不能更新自己, 个人资料在个人中心通过UpdateProfile更新)
 func (uc UserController) UpdateUserById(c *gin.Context) {
 	var req vo.CreateUserRequest
 	// 参数绑定
-	if err := c.ShouldBind(&req); err != nil {
+	if err := bindWriteRequest(c, &req); err != nil {
 		response.Fail(c, nil, err.Error())
 		return
 	}
 	// 参数校验
 	if err := common.Validate.Struct(&req); err != nil {
 		errStr := err.(validator.ValidationErrors)[0].Translate(common.Trans)
-		response.Fail(c, nil, errStr)
+		response.Fail(c, gin.H{"errors": common.TranslateAll(err)}, errStr)
 		return
 	}
+	// 用户名不区分大小写, 统一保存为小写
+	req.Username = util.NormalizeUsername(req.Username)
 
 	//获取path中的userId
 	userId, _ := strconv.Atoi(c.Param("userId"))
@@ -246,21 +829,31 @@ func (uc UserController) UpdateUserById(c *gin.Context) {
 	// 获取当前用户
 	ctxUser, err := uc.UserRepository.GetCurrentUser(c)
 	if err != nil {
-		response.Fail(c, nil, err.Error())
+		failCurrentUser(c, err)
+		return
+	}
+	// 不能在用户管理中更新自己, 个人资料和密码在个人中心更新
+	if userId == int(ctxUser.ID) {
+		response.Forbidden(c, nil, "不能更新自己, 请到个人中心更新个人资料")
+		return
+	}
+	// 校验当前用户是否有修改各字段的权限
+	if err := checkUserFieldPermissions(ctxUser, oldUser, &req); err != nil {
+		response.Forbidden(c, nil, err.Error())
 		return
 	}
 	// 获取当前用户的所有角色
 	currentRoles := ctxUser.Roles
 	// 获取当前用户角色的排序，和前端传来的角色排序做比较
 	var currentRoleSorts []int
-	// 当前用户角色ID集合
-	var currentRoleIds []uint
 	for _, role := range currentRoles {
 		currentRoleSorts = append(currentRoleSorts, int(role.Sort))
-		currentRoleIds = append(currentRoleIds, role.ID)
 	}
-	// 当前用户角色排序最小值（最高等级角色）
-	currentRoleSortMin := funk.MinInt(currentRoleSorts).(int)
+	// 当前用户角色排序最小值（最高等级角色）, 没有有效角色(如角色都已过期)的用户等级最低
+	currentRoleSortMin := 999
+	if len(currentRoleSorts) > 0 {
+		currentRoleSortMin = funk.MinInt(currentRoleSorts).(int)
+	}
 
 	// 获取前端传来的用户角色id
 	reqRoleIds := req.RoleIds
@@ -268,19 +861,27 @@ func (uc UserController) UpdateUserById(c *gin.Context) {
 	rr := repository.NewRoleRepository()
 	roles, err := rr.GetRolesByIds(reqRoleIds)
 	if err != nil {
-		response.Fail(c, nil, "根据角色ID获取角色信息失败: "+err.Error())
+		response.ServerError(c, nil, "根据角色ID获取角色信息失败: "+err.Error())
 		return
 	}
 	if len(roles) == 0 {
 		response.Fail(c, nil, "未获取到角色信息")
 		return
 	}
+	// 校验无效的角色ID
+	if err := checkInvalidRoleIds(reqRoleIds, roles); err != nil {
+		response.Fail(c, nil, err.Error())
+		return
+	}
 	var reqRoleSorts []int
 	for _, role := range roles {
 		reqRoleSorts = append(reqRoleSorts, int(role.Sort))
 	}
 	// 前端传来用户角色排序最小值（最高等级角色）
-	reqRoleSortMin := funk.MinInt(reqRoleSorts).(int)
+	reqRoleSortMin := 999
+	if len(reqRoleSorts) > 0 {
+		reqRoleSortMin = funk.MinInt(reqRoleSorts).(int)
+	}
 
 	user := model.User{
 		Model:        oldUser.Model,
@@ -293,80 +894,283 @@ func (uc UserController) UpdateUserById(c *gin.Context) {
 		Status:       req.Status,
 		Creator:      ctxUser.Username,
 		Roles:        roles,
+		Version:      req.Version,
 	}
-	// 判断是更新自己还是更新别人
-	if userId == int(ctxUser.ID) {
-		// 如果是更新自己
-		// 不能禁用自己
-		if req.Status == 2 {
-			response.Fail(c, nil, "不能禁用自己")
-			return
-		}
-		// 不能更改自己的角色
-		reqDiff, currentDiff := funk.Difference(req.RoleIds, currentRoleIds)
-		if len(reqDiff.([]uint)) > 0 || len(currentDiff.([]uint)) > 0 {
-			response.Fail(c, nil, "不能更改自己的角色")
+	// 用户不能更新比自己角色等级高的或者相同等级的用户
+	// 根据path中的userIdID获取用户角色排序最小值
+	minRoleSorts, err := uc.UserRepository.GetUserMinRoleSortsByIds([]uint{uint(userId)})
+	if err != nil {
+		response.ServerError(c, nil, "根据用户ID获取用户角色排序最小值失败: "+err.Error())
+		return
+	}
+	if len(minRoleSorts) == 0 {
+		response.Fail(c, nil, "未获取到用户信息")
+		return
+	}
+	if currentRoleSortMin >= minRoleSorts[0] {
+		response.Forbidden(c, nil, "用户不能更新比自己角色等级高的或者相同等级的用户")
+		return
+	}
+
+	// 用户不能把别的用户角色等级更新得比自己高或相等
+	if currentRoleSortMin >= reqRoleSortMin {
+		response.Forbidden(c, nil, "用户不能把别的用户角色等级更新得比自己高或相等")
+		return
+	}
+
+	// 密码赋值
+	if req.Password != "" {
+		// 密码通过RSA解密
+		decodeData, err := util.RSADecrypt([]byte(req.Password), config.Conf.System.RSAPrivateBytes)
+		if err != nil {
+			response.Fail(c, nil, err.Error())
 			return
 		}
-
-		// 不能更新自己的密码，只能在个人中心更新
-		if req.Password != "" {
-			response.Fail(c, nil, "请到个人中心更新自身密码")
+		req.Password = string(decodeData)
+		if err := validatePassword(req.Password); err != nil {
+			response.Fail(c, nil, err.Error())
 			return
 		}
+		user.Password = util.GenPasswd(req.Password)
+	}
+
+	// 用户名、手机号不能与其他用户重复
+	if err := checkUserConflict(uc.UserRepository, req.Username, req.Mobile, uint(userId)); err != nil {
+		response.Fail(c, nil, err.Error())
+		return
+	}
+
+	// 更新用户
+	err = uc.UserRepository.UpdateUser(&user)
+	if err != nil {
+		response.Fail(c, nil, "更新用户失败: "+err.Error())
+		return
+	}
+	// 管理员重置密码后清除该用户的登录失败锁定
+	if req.Password != "" {
+		common.ResetUserLoginFailures(oldUser.Username)
+	}
+	middleware.SetOperationLogTarget(c, "user", uint(userId))
+	middleware.SetOperationLogAction(c, "user-update", req)
+	response.Success(c, nil, "更新用户成功")
+
+}
 
-		// 密码赋值
-		user.Password = ctxUser.Password
+// 更新用户状态(启用/禁用), 不需要提交用户的其他字段
+// 不能禁用自己, 不能修改比自己角色等级高的或者相同等级的用户
+func (uc UserController) UpdateUserStatus(c *gin.Context) {
+	var req vo.UpdateUserStatusRequest
+	// 参数绑定
+	if err := bindWriteRequest(c, &req); err != nil {
+		response.Fail(c, nil, err.Error())
+		return
+	}
+	// 参数校验
+	if err := common.Validate.Struct(&req); err != nil {
+		errStr := err.(validator.ValidationErrors)[0].Translate(common.Trans)
+		response.Fail(c, nil, errStr)
+		return
+	}
+	// 获取path中的userId
+	userId, _ := strconv.Atoi(c.Param("userId"))
+	if userId <= 0 {
+		response.Fail(c, nil, "用户ID不正确")
+		return
+	}
 
+	// 当前用户角色排序最小值（最高等级角色）以及当前用户
+	currentRoleSortMin, ctxUser, err := uc.UserRepository.GetCurrentUserMinRoleSort(c)
+	if err != nil {
+		failCurrentUser(c, err)
+		return
+	}
+	if userId == int(ctxUser.ID) {
+		if req.Status == 2 {
+			response.Forbidden(c, nil, "不能禁用自己")
+			return
+		}
 	} else {
-		// 如果是更新别人
-		// 用户不能更新比自己角色等级高的或者相同等级的用户
-		// 根据path中的userIdID获取用户角色排序最小值
+		// 用户不能修改比自己角色等级高的或者相同等级的用户的状态
 		minRoleSorts, err := uc.UserRepository.GetUserMinRoleSortsByIds([]uint{uint(userId)})
-		if err != nil || len(minRoleSorts) == 0 {
-			response.Fail(c, nil, "根据用户ID获取用户角色排序最小值失败")
+		if err != nil {
+			response.ServerError(c, nil, "根据用户ID获取用户角色排序最小值失败: "+err.Error())
 			return
 		}
-		if currentRoleSortMin >= minRoleSorts[0] {
-			response.Fail(c, nil, "用户不能更新比自己角色等级高的或者相同等级的用户")
+		if len(minRoleSorts) == 0 {
+			response.Fail(c, nil, "未获取到用户信息")
 			return
 		}
-
-		// 用户不能把别的用户角色等级更新得比自己高或相等
-		if currentRoleSortMin >= reqRoleSortMin {
-			response.Fail(c, nil, "用户不能把别的用户角色等级更新得比自己高或相等")
+		if currentRoleSortMin >= uint(minRoleSorts[0]) {
+			response.Forbidden(c, nil, "用户不能修改比自己角色等级高的或者相同等级的用户的状态")
 			return
 		}
+	}
+	// 与更新用户相同校验修改状态字段的权限
+	if err := checkChangedFieldPermissions(ctxUser, map[string]bool{"status": true}); err != nil {
+		response.Forbidden(c, nil, err.Error())
+		return
+	}
 
-		// 密码赋值
-		if req.Password != "" {
-			// 密码通过RSA解密
-			decodeData, err := util.RSADecrypt([]byte(req.Password), config.Conf.System.RSAPrivateBytes)
-			if err != nil {
-				response.Fail(c, nil, err.Error())
-				return
-			}
-			req.Password = string(decodeData)
-			user.Password = util.GenPasswd(req.Password)
+	err = uc.UserRepository.UpdateStatus(uint(userId), req.Status)
+	if err != nil {
+		response.ServerError(c, nil, "更新用户状态失败: "+err.Error())
+		return
+	}
+	middleware.SetOperationLogTarget(c, "user", uint(userId))
+	middleware.SetOperationLogAction(c, "user-status", req)
+	response.Success(c, nil, "更新用户状态成功")
+}
+
+// 批量更新用户状态(启用/禁用), 逐个校验权限, 校验通过的用户在一个事务中更新
+// 返回每个用户的处理结果, 部分用户校验未通过时不影响其他用户
+func (uc UserController) BatchUpdateStatus(c *gin.Context) {
+	var req vo.BatchUpdateUserStatusRequest
+	// 参数绑定
+	if err := bindWriteRequest(c, &req); err != nil {
+		response.Fail(c, nil, err.Error())
+		return
+	}
+	// 参数校验
+	if err := common.Validate.Struct(&req); err != nil {
+		errStr := err.(validator.ValidationErrors)[0].Translate(common.Trans)
+		response.Fail(c, nil, errStr)
+		return
+	}
+
+	// 当前用户角色排序最小值（最高等级角色）以及当前用户
+	minSort, ctxUser, err := uc.UserRepository.GetCurrentUserMinRoleSort(c)
+	if err != nil {
+		failCurrentUser(c, err)
+		return
+	}
+	// 与更新用户相同校验修改状态字段的权限
+	if err := checkChangedFieldPermissions(ctxUser, map[string]bool{"status": true}); err != nil {
+		response.Forbidden(c, nil, err.Error())
+		return
+	}
+
+	operation := "user-enable"
+	if req.Status == 2 {
+		operation = "user-disable"
+	}
+	checkResults, err := checkUserBatchStatus(ctxUser, minSort, operation, req.UserIds)
+	if err != nil {
+		response.ServerError(c, nil, "根据用户ID获取用户角色排序最小值失败")
+		return
+	}
+	results := make([]*dto.BatchResultDto, 0, len(checkResults))
+	allowedIds := make([]uint, 0, len(checkResults))
+	for _, checkResult := range checkResults {
+		if !checkResult.Allowed {
+			results = append(results, &dto.BatchResultDto{Id: checkResult.Id, Message: checkResult.FailReason()})
+			continue
 		}
+		allowedIds = append(allowedIds, checkResult.Id)
+		results = append(results, &dto.BatchResultDto{Id: checkResult.Id, Success: true})
+	}
+	if len(allowedIds) == 0 {
+		response.Fail(c, gin.H{"results": results}, "没有可以修改状态的用户")
+		return
+	}
 
+	if err := uc.UserRepository.BatchUpdateStatus(allowedIds, req.Status); err != nil {
+		response.ServerError(c, nil, "批量更新用户状态失败: "+err.Error())
+		return
 	}
+	middleware.SetOperationLogTarget(c, "user", allowedIds...)
+	middleware.SetOperationLogAction(c, "user-status-batch", req)
+	response.Success(c, gin.H{"results": results}, "批量更新用户状态完成")
+}
 
-	// 更新用户
-	err = uc.UserRepository.UpdateUser(&user)
+// 重置其他用户的密码
+// 不需要原密码, 角色等级校验与更新用户相同, 重置后用户下次登录时需要修改密码
+func (uc UserController) ResetUserPassword(c *gin.Context) {
+	var req vo.ResetUserPasswordRequest
+	// 参数绑定
+	if err := bindWriteRequest(c, &req); err != nil {
+		response.Fail(c, nil, err.Error())
+		return
+	}
+	// 获取path中的userId
+	userId, _ := strconv.Atoi(c.Param("userId"))
+	if userId <= 0 {
+		response.Fail(c, nil, "用户ID不正确")
+		return
+	}
+
+	// 当前用户角色排序最小值（最高等级角色）以及当前用户
+	currentRoleSortMin, ctxUser, err := uc.UserRepository.GetCurrentUserMinRoleSort(c)
 	if err != nil {
-		response.Fail(c, nil, "更新用户失败: "+err.Error())
+		failCurrentUser(c, err)
+		return
+	}
+	if userId == int(ctxUser.ID) {
+		response.Fail(c, nil, "请到个人中心更新自身密码")
+		return
+	}
+	oldUser, err := uc.UserRepository.GetUserById(uint(userId))
+	if err != nil {
+		response.Fail(c, nil, "获取需要重置密码的用户信息失败: "+err.Error())
+		return
+	}
+	// 用户不能重置比自己角色等级高的或者相同等级的用户的密码
+	minRoleSorts, err := uc.UserRepository.GetUserMinRoleSortsByIds([]uint{uint(userId)})
+	if err != nil {
+		response.ServerError(c, nil, "根据用户ID获取用户角色排序最小值失败: "+err.Error())
+		return
+	}
+	if len(minRoleSorts) == 0 {
+		response.Fail(c, nil, "未获取到用户信息")
+		return
+	}
+	if currentRoleSortMin >= uint(minRoleSorts[0]) {
+		response.Forbidden(c, nil, "用户不能重置比自己角色等级高的或者相同等级的用户的密码")
 		return
 	}
-	response.Success(c, nil, "更新用户成功")
 
+	var password string
+	generated := false
+	if req.Password == "" {
+		password, generated, err = defaultPassword()
+		if err != nil {
+			response.ServerError(c, nil, "生成随机密码失败: "+err.Error())
+			return
+		}
+	} else {
+		// 密码通过RSA解密
+		decodeData, err := util.RSADecrypt([]byte(req.Password), config.Conf.System.RSAPrivateBytes)
+		if err != nil {
+			response.Fail(c, nil, err.Error())
+			return
+		}
+		password = string(decodeData)
+		if err := validatePassword(password); err != nil {
+			response.Fail(c, nil, err.Error())
+			return
+		}
+	}
+	err = uc.UserRepository.ResetPassword(uint(userId), util.GenPasswd(password))
+	if err != nil {
+		response.ServerError(c, nil, "重置密码失败: "+err.Error())
+		return
+	}
+	// 重置密码后清除该用户的登录失败锁定
+	common.ResetUserLoginFailures(oldUser.Username)
+	middleware.SetOperationLogTarget(c, "user", uint(userId))
+	middleware.SetOperationLogAction(c, "user-reset-password", nil)
+	// 随机生成的密码只在这里返回一次, 由管理员告知用户
+	if generated {
+		response.Success(c, gin.H{"password": password}, "重置密码成功")
+		return
+	}
+	response.Success(c, nil, "重置密码成功")
 }
 
 // 批量删除用户
 func (uc UserController) BatchDeleteUserByIds(c *gin.Context) {
 	var req vo.DeleteUserRequest
 	// 参数绑定
-	if err := c.ShouldBind(&req); err != nil {
+	if err := bindWriteRequest(c, &req); err != nil {
 		response.Fail(c, nil, err.Error())
 		return
 	}
@@ -379,31 +1183,23 @@ func (uc UserController) BatchDeleteUserByIds(c *gin.Context) {
 
 	// 前端传来的用户ID
 	reqUserIds := req.UserIds
-	// 根据用户ID获取用户角色排序最小值
-	roleMinSortList, err := uc.UserRepository.GetUserMinRoleSortsByIds(reqUserIds)
-	if err != nil || len(roleMinSortList) == 0 {
-		response.Fail(c, nil, "根据用户ID获取用户角色排序最小值失败")
-		return
-	}
 
 	// 当前用户角色排序最小值（最高等级角色）以及当前用户
 	minSort, ctxUser, err := uc.UserRepository.GetCurrentUserMinRoleSort(c)
 	if err != nil {
-		response.Fail(c, nil, err.Error())
+		failCurrentUser(c, err)
 		return
 	}
-	currentRoleSortMin := int(minSort)
 
-	// 不能删除自己
-	if funk.Contains(reqUserIds, ctxUser.ID) {
-		response.Fail(c, nil, "用户不能删除自己")
+	// 不能删除自己, 不能删除比自己角色排序低(等级高)的用户
+	results, err := checkUserBatchDelete(ctxUser, minSort, reqUserIds)
+	if err != nil {
+		response.ServerError(c, nil, "根据用户ID获取用户角色排序最小值失败")
 		return
 	}
-
-	// 不能删除比自己角色排序低(等级高)的用户
-	for _, sort := range roleMinSortList {
-		if currentRoleSortMin >= sort {
-			response.Fail(c, nil, "用户不能删除比自己角色等级高的用户")
+	for _, result := range results {
+		if !result.Allowed {
+			failBatchCheck(c, result)
 			return
 		}
 	}
@@ -414,6 +1210,1158 @@ func (uc UserController) BatchDeleteUserByIds(c *gin.Context) {
 		return
 	}
 
+	middleware.SetOperationLogTarget(c, "user", reqUserIds...)
+	middleware.SetOperationLogAction(c, "user-delete", req)
 	response.Success(c, nil, "删除用户成功")
 
 }
+
+// 获取已删除的用户列表(回收站)
+func (uc UserController) GetDeletedUsers(c *gin.Context) {
+	var req vo.UserListRequest
+	// 参数绑定
+	if err := c.ShouldBind(&req); err != nil {
+		response.Fail(c, nil, err.Error())
+		return
+	}
+	// 参数校验
+	if err := common.Validate.Struct(&req); err != nil {
+		errStr := err.(validator.ValidationErrors)[0].Translate(common.Trans)
+		response.Fail(c, gin.H{"errors": common.TranslateAll(err)}, errStr)
+		return
+	}
+
+	users, total, err := uc.UserRepository.GetDeletedUsers(&req)
+	if err != nil {
+		response.ServerError(c, nil, "获取已删除的用户列表失败: "+err.Error())
+		return
+	}
+	page := response.NewPageData(c, total, int(req.PageNum), int(req.PageSize))
+	response.SuccessPage(c, "users", dto.ToDeletedUsersDto(users), page, "获取已删除的用户列表成功")
+}
+
+// 批量恢复已删除的用户
+func (uc UserController) RestoreUsers(c *gin.Context) {
+	var req vo.RestoreUserRequest
+	// 参数绑定
+	if err := bindWriteRequest(c, &req); err != nil {
+		response.Fail(c, nil, err.Error())
+		return
+	}
+	// 参数校验
+	if err := common.Validate.Struct(&req); err != nil {
+		errStr := err.(validator.ValidationErrors)[0].Translate(common.Trans)
+		response.Fail(c, nil, errStr)
+		return
+	}
+
+	// 当前用户角色排序最小值（最高等级角色）
+	minSort, _, err := uc.UserRepository.GetCurrentUserMinRoleSort(c)
+	if err != nil {
+		failCurrentUser(c, err)
+		return
+	}
+
+	// 不能恢复比自己角色排序低(等级高)的用户
+	results, err := checkUserBatchRestore(minSort, req.UserIds)
+	if err != nil {
+		response.ServerError(c, nil, "根据用户ID获取已删除用户角色排序最小值失败")
+		return
+	}
+	for _, result := range results {
+		if !result.Allowed {
+			failBatchCheck(c, result)
+			return
+		}
+	}
+
+	err = uc.UserRepository.RestoreUsers(req.UserIds)
+	if err != nil {
+		response.Fail(c, nil, "恢复用户失败: "+err.Error())
+		return
+	}
+
+	middleware.SetOperationLogTarget(c, "user", req.UserIds...)
+	middleware.SetOperationLogAction(c, "user-restore", req)
+	response.Success(c, nil, "恢复用户成功")
+}
+
+// 预热用户信息缓存
+func (uc UserController) WarmUserInfoCache(c *gin.Context) {
+	var req vo.WarmUserCacheRequest
+	// 参数绑定
+	if err := bindWriteRequest(c, &req); err != nil {
+		response.Fail(c, nil, err.Error())
+		return
+	}
+	// 参数校验
+	if err := common.Validate.Struct(&req); err != nil {
+		errStr := err.(validator.ValidationErrors)[0].Translate(common.Trans)
+		response.Fail(c, nil, errStr)
+		return
+	}
+
+	count, err := uc.UserRepository.WarmUserInfoCache(req.UserIds)
+	if err != nil {
+		response.ServerError(c, gin.H{"count": count}, "预热用户信息缓存失败: "+err.Error())
+		return
+	}
+	response.Success(c, gin.H{"count": count}, "预热用户信息缓存成功")
+}
+
+// 获取角色即将过期的用户列表
+func (uc UserController) GetUsersWithExpiringRoles(c *gin.Context) {
+	var req vo.ExpiringUserRoleListRequest
+	// 参数绑定
+	if err := c.ShouldBind(&req); err != nil {
+		response.Fail(c, nil, err.Error())
+		return
+	}
+	// 参数校验
+	if err := common.Validate.Struct(&req); err != nil {
+		errStr := err.(validator.ValidationErrors)[0].Translate(common.Trans)
+		response.Fail(c, nil, errStr)
+		return
+	}
+
+	// 未指定时间范围则使用配置的默认值
+	days := req.Days
+	if days == 0 {
+		days = config.Conf.User.RoleExpiringDays
+	}
+	list, err := uc.UserRepository.GetUsersWithExpiringRoles(time.Hour * 24 * time.Duration(days))
+	if err != nil {
+		response.ServerError(c, nil, "获取角色即将过期的用户列表失败: "+err.Error())
+		return
+	}
+	// 不分页
+	page := response.NewPageData(c, int64(len(list)), 0, 0)
+	response.SuccessPage(c, "users", list, page, "获取角色即将过期的用户列表成功")
+}
+
+// 更新用户角色的过期时间
+func (uc UserController) UpdateUserRoleExpiresAt(c *gin.Context) {
+	var req vo.UpdateUserRoleExpiresRequest
+	// 参数绑定
+	if err := bindWriteRequest(c, &req); err != nil {
+		response.Fail(c, nil, err.Error())
+		return
+	}
+	// 参数校验
+	if err := common.Validate.Struct(&req); err != nil {
+		errStr := err.(validator.ValidationErrors)[0].Translate(common.Trans)
+		response.Fail(c, nil, errStr)
+		return
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		response.Fail(c, nil, "过期时间必须晚于当前时间")
+		return
+	}
+
+	// 获取path中的userId
+	userId, _ := strconv.Atoi(c.Param("userId"))
+	if userId <= 0 {
+		response.Fail(c, nil, "用户ID不正确")
+		return
+	}
+
+	// 当前用户角色排序最小值（最高等级角色）以及当前用户
+	minSort, ctxUser, err := uc.UserRepository.GetCurrentUserMinRoleSort(c)
+	if err != nil {
+		failCurrentUser(c, err)
+		return
+	}
+	// 不能更改自己的角色
+	if uint(userId) == ctxUser.ID {
+		response.Forbidden(c, nil, "不能更改自己的角色")
+		return
+	}
+	// 用户不能更新比自己角色等级高的或者相同等级的用户
+	minRoleSorts, err := uc.UserRepository.GetUserMinRoleSortsByIds([]uint{uint(userId)})
+	if err != nil {
+		response.ServerError(c, nil, "根据用户ID获取用户角色排序最小值失败: "+err.Error())
+		return
+	}
+	if len(minRoleSorts) == 0 {
+		response.Fail(c, nil, "未获取到用户信息")
+		return
+	}
+	if int(minSort) >= minRoleSorts[0] {
+		response.Forbidden(c, nil, "用户不能更新比自己角色等级高的或者相同等级的用户")
+		return
+	}
+	rr := repository.NewRoleRepository()
+	roles, err := rr.GetRolesByIds([]uint{req.RoleId})
+	if err != nil {
+		response.ServerError(c, nil, "根据角色ID获取角色信息失败: "+err.Error())
+		return
+	}
+	if len(roles) == 0 {
+		response.Fail(c, nil, "未获取到角色信息")
+		return
+	}
+	// 不能更新比自己角色等级高或相等的角色的过期时间(包括目标用户已过期的角色)
+	if minSort >= roles[0].Sort {
+		response.Forbidden(c, nil, "用户不能更新比自己角色等级高或相等的角色")
+		return
+	}
+
+	err = uc.UserRepository.UpdateUserRoleExpiresAt(uint(userId), req.RoleId, req.ExpiresAt)
+	if err != nil {
+		response.Fail(c, nil, "更新用户角色的过期时间失败: "+err.Error())
+		return
+	}
+	middleware.SetOperationLogTarget(c, "user", uint(userId))
+	middleware.SetOperationLogAction(c, "user-role-expires", req)
+	response.Success(c, nil, "更新用户角色的过期时间成功")
+}
+
+// 为多个用户分配同一个角色
+// 逐个校验用户的角色等级, 未通过校验的用户在响应中返回原因, 其余用户正常分配
+func (uc UserController) BatchAssignRole(c *gin.Context) {
+	var req vo.BatchAssignRoleRequest
+	// 参数绑定
+	if err := bindWriteRequest(c, &req); err != nil {
+		response.Fail(c, nil, err.Error())
+		return
+	}
+	// 参数校验
+	if err := common.Validate.Struct(&req); err != nil {
+		errStr := err.(validator.ValidationErrors)[0].Translate(common.Trans)
+		response.Fail(c, nil, errStr)
+		return
+	}
+
+	// 当前用户角色排序最小值（最高等级角色）以及当前用户
+	minSort, ctxUser, err := uc.UserRepository.GetCurrentUserMinRoleSort(c)
+	if err != nil {
+		failCurrentUser(c, err)
+		return
+	}
+	rr := repository.NewRoleRepository()
+	roles, err := rr.GetRolesByIds([]uint{req.RoleId})
+	if err != nil {
+		response.ServerError(c, nil, "根据角色ID获取角色信息失败: "+err.Error())
+		return
+	}
+	if len(roles) == 0 {
+		response.Fail(c, nil, "未获取到角色信息")
+		return
+	}
+	// 不能分配比自己角色等级高或相等的角色
+	if minSort >= roles[0].Sort {
+		response.Forbidden(c, nil, "用户不能分配比自己角色等级高或相等的角色")
+		return
+	}
+
+	results, err := checkUserBatchAssignRole(ctxUser, minSort, req.UserIds)
+	if err != nil {
+		response.ServerError(c, nil, "根据用户ID获取用户角色排序最小值失败")
+		return
+	}
+	allowedIds := make([]uint, 0, len(results))
+	failed := make([]*dto.BatchCheckResultDto, 0)
+	for _, result := range results {
+		if result.Allowed {
+			allowedIds = append(allowedIds, result.Id)
+		} else {
+			failed = append(failed, result)
+		}
+	}
+	if len(allowedIds) == 0 {
+		response.Fail(c, gin.H{"failed": failed}, "没有可以分配角色的用户")
+		return
+	}
+
+	added, err := uc.UserRepository.BatchAssignRole(req.RoleId, allowedIds)
+	if err != nil {
+		response.ServerError(c, gin.H{"failed": failed}, "分配角色失败: "+err.Error())
+		return
+	}
+	middleware.SetOperationLogTarget(c, "user", allowedIds...)
+	middleware.SetOperationLogAction(c, "user-assign-role", req)
+	response.Success(c, gin.H{
+		"assigned": allowedIds,
+		"added":    added,
+		"failed":   failed,
+	}, "分配角色成功")
+}
+
+// 合并用户
+func (uc UserController) MergeUsers(c *gin.Context) {
+	var req vo.MergeUserRequest
+	// 参数绑定
+	if err := bindWriteRequest(c, &req); err != nil {
+		response.Fail(c, nil, err.Error())
+		return
+	}
+	// 参数校验
+	if err := common.Validate.Struct(&req); err != nil {
+		errStr := err.(validator.ValidationErrors)[0].Translate(common.Trans)
+		response.Fail(c, nil, errStr)
+		return
+	}
+	if req.SourceId == req.TargetId {
+		response.Fail(c, nil, "不能将用户合并到自身")
+		return
+	}
+
+	// 当前用户角色排序最小值（最高等级角色）以及当前用户
+	minSort, ctxUser, err := uc.UserRepository.GetCurrentUserMinRoleSort(c)
+	if err != nil {
+		failCurrentUser(c, err)
+		return
+	}
+	// 不能合并自己
+	if req.SourceId == ctxUser.ID || req.TargetId == ctxUser.ID {
+		response.Forbidden(c, nil, "不能合并自己")
+		return
+	}
+
+	// 当前用户的角色等级需要比两个用户都高
+	roleMinSortList, err := uc.UserRepository.GetUserMinRoleSortsByIds([]uint{req.SourceId, req.TargetId})
+	if err != nil {
+		response.ServerError(c, nil, "根据用户ID获取用户角色排序最小值失败: "+err.Error())
+		return
+	}
+	if len(roleMinSortList) != 2 {
+		response.Fail(c, nil, "未获取到用户信息")
+		return
+	}
+	for _, roleSort := range roleMinSortList {
+		if int(minSort) >= roleSort {
+			response.Forbidden(c, nil, "用户不能合并比自己角色等级高的或者相同等级的用户")
+			return
+		}
+	}
+	// 合并后目标用户新增的角色不能比自己角色等级高或相等(已过期的角色不合并)
+	source, err := uc.UserRepository.GetUserById(req.SourceId)
+	if err != nil {
+		response.Fail(c, nil, "未获取到用户信息")
+		return
+	}
+	target, err := uc.UserRepository.GetUserById(req.TargetId)
+	if err != nil {
+		response.Fail(c, nil, "未获取到用户信息")
+		return
+	}
+	targetRoleIds := make([]uint, 0, len(target.Roles))
+	for _, role := range target.Roles {
+		targetRoleIds = append(targetRoleIds, role.ID)
+	}
+	for _, role := range source.Roles {
+		if !funk.Contains(targetRoleIds, role.ID) && role.Sort <= minSort {
+			response.Forbidden(c, nil, "用户不能分配比自己角色等级高或相等的角色")
+			return
+		}
+	}
+
+	result, err := uc.UserRepository.MergeUsers(req.SourceId, req.TargetId)
+	if err != nil {
+		response.Fail(c, nil, "合并用户失败: "+err.Error())
+		return
+	}
+	middleware.SetOperationLogTarget(c, "user", req.SourceId, req.TargetId)
+	middleware.SetOperationLogAction(c, "user-merge", req)
+	response.Success(c, gin.H{"result": result}, "合并用户成功")
+}
+
+// 检测密码强度
+// 只返回检测结果, 不保存任何数据, 前端可用于实时显示密码强度
+func (uc UserController) CheckPasswordStrength(c *gin.Context) {
+	var req vo.PasswordStrengthRequest
+	// 参数绑定
+	if err := c.ShouldBind(&req); err != nil {
+		response.Fail(c, nil, err.Error())
+		return
+	}
+	// 参数校验
+	if err := common.Validate.Struct(&req); err != nil {
+		errStr := err.(validator.ValidationErrors)[0].Translate(common.Trans)
+		response.Fail(c, nil, errStr)
+		return
+	}
+
+	// 密码通过RSA解密
+	decodeData, err := util.RSADecrypt([]byte(req.Password), config.Conf.System.RSAPrivateBytes)
+	if err != nil {
+		response.Fail(c, nil, err.Error())
+		return
+	}
+	password := string(decodeData)
+
+	// 与创建用户、修改密码时使用相同的规则
+	strength := util.CheckPasswordStrength(password, config.Conf.Security.PasswordMinLength)
+	valid, message := true, ""
+	if err := validatePassword(password); err != nil {
+		valid, message = false, err.Error()
+	}
+	response.Success(c, gin.H{
+		"strength":           strength,
+		"valid":              valid,
+		"message":            message,
+		"passwordMinLength":  config.Conf.Security.PasswordMinLength,
+		"passwordMinClasses": config.Conf.Security.PasswordMinClasses,
+	}, "检测密码强度成功")
+}
+
+// 随机生成的默认密码长度
+const randomPasswordLength = 12
+
+// 创建用户、重置密码时未提供密码使用的默认密码
+// 未配置默认密码时生成随机密码, generated为true, 调用方需要在响应中返回该密码(只返回这一次)
+func defaultPassword() (password string, generated bool, err error) {
+	if config.Conf.User.DefaultPassword != "" {
+		return config.Conf.User.DefaultPassword, false, nil
+	}
+	password, err = util.RandomPassword(randomPasswordLength)
+	return password, true, err
+}
+
+// 校验头像地址, 只能为空、http(s)地址或本地存储的头像路径
+func validateAvatar(avatar string) error {
+	if avatar == "" || storage.IsLocalAvatarPath(avatar) {
+		return nil
+	}
+	u, err := url.Parse(avatar)
+	if err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "" {
+		return nil
+	}
+	return errors.New("头像地址有误, 只能为http(s)地址或上传的头像路径")
+}
+
+// 按配置的密码规则校验密码强度(解密后的明文密码)
+func validatePassword(password string) error {
+	if err := common.Validate.Var(password, "password_strength"); err != nil {
+		return errors.New(err.(validator.ValidationErrors)[0].Translate(common.Trans))
+	}
+	return nil
+}
+
+// 校验导入用户数据
+// 只校验不导入, 逐行返回错误信息
+func (uc UserController) ValidateImportUsers(c *gin.Context) {
+	var req vo.ValidateImportUsersRequest
+	// 参数绑定
+	if err := bindWriteRequest(c, &req); err != nil {
+		response.Fail(c, nil, err.Error())
+		return
+	}
+	// 参数校验
+	if err := common.Validate.Struct(&req); err != nil {
+		errStr := err.(validator.ValidationErrors)[0].Translate(common.Trans)
+		response.Fail(c, nil, errStr)
+		return
+	}
+
+	// 行号从1开始
+	rowNums := make([]int, len(req.Users))
+	for i := range rowNums {
+		rowNums[i] = i + 1
+	}
+	rowErrors, err := uc.validateImportUserRows(req.Users, rowNums)
+	if err != nil {
+		response.ServerError(c, nil, "校验导入用户数据失败: "+err.Error())
+		return
+	}
+	response.Success(c, gin.H{"valid": len(rowErrors) == 0, "errors": rowErrors}, "校验导入用户数据完成")
+}
+
+// 逐行校验导入的用户数据
+// rowNums 每行数据的行号(与rows一一对应), 错误信息中的行号都使用该行号
+// 校验字段规则(包括手机号格式)、文件内用户名和手机号是否重复、与已有用户是否冲突
+func (uc UserController) validateImportUserRows(rows []*vo.CreateUserRequest, rowNums []int) ([]*dto.ImportRowErrorDto, error) {
+	rowErrors := make([]*dto.ImportRowErrorDto, 0)
+	usernameRows := make(map[string]int)
+	mobileRows := make(map[string]int)
+	for i, row := range rows {
+		rowNum := rowNums[i]
+		if row == nil {
+			rowErrors = append(rowErrors, &dto.ImportRowErrorDto{Row: rowNum, Message: "数据为空"})
+			continue
+		}
+		// 字段规则校验, 与创建用户使用相同的规则
+		if err := common.Validate.Struct(row); err != nil {
+			for _, fieldErr := range err.(validator.ValidationErrors) {
+				rowErrors = append(rowErrors, &dto.ImportRowErrorDto{
+					Row:     rowNum,
+					Field:   fieldErr.Field(),
+					Message: fieldErr.Translate(common.Trans),
+				})
+			}
+		}
+		// 文件内重复校验, 用户名不区分大小写
+		row.Username = util.NormalizeUsername(row.Username)
+		if row.Username != "" {
+			if firstRowNum, ok := usernameRows[row.Username]; ok {
+				rowErrors = append(rowErrors, &dto.ImportRowErrorDto{
+					Row:     rowNum,
+					Field:   "Username",
+					Message: fmt.Sprintf("用户名与第%d行重复", firstRowNum),
+				})
+			} else {
+				usernameRows[row.Username] = rowNum
+			}
+		}
+		if row.Mobile != "" {
+			if firstRowNum, ok := mobileRows[row.Mobile]; ok {
+				rowErrors = append(rowErrors, &dto.ImportRowErrorDto{
+					Row:     rowNum,
+					Field:   "Mobile",
+					Message: fmt.Sprintf("手机号与第%d行重复", firstRowNum),
+				})
+			} else {
+				mobileRows[row.Mobile] = rowNum
+			}
+		}
+	}
+
+	// 与已有用户冲突校验
+	usernames := make([]string, 0, len(usernameRows))
+	for username := range usernameRows {
+		usernames = append(usernames, username)
+	}
+	mobiles := make([]string, 0, len(mobileRows))
+	for mobile := range mobileRows {
+		mobiles = append(mobiles, mobile)
+	}
+	conflictUsers, err := uc.UserRepository.GetConflictUsers(usernames, mobiles)
+	if err != nil {
+		return nil, err
+	}
+	for _, user := range conflictUsers {
+		if rowNum, ok := usernameRows[util.NormalizeUsername(user.Username)]; ok {
+			rowErrors = append(rowErrors, &dto.ImportRowErrorDto{Row: rowNum, Field: "Username", Message: repository.MsgUsernameExists})
+		}
+		if rowNum, ok := mobileRows[user.Mobile]; ok {
+			rowErrors = append(rowErrors, &dto.ImportRowErrorDto{Row: rowNum, Field: "Mobile", Message: repository.MsgMobileUsed})
+		}
+	}
+	// 按行号排序
+	sort.SliceStable(rowErrors, func(i, j int) bool {
+		return rowErrors[i].Row < rowErrors[j].Row
+	})
+	return rowErrors, nil
+}
+
+// 导入用户(xlsx或CSV文件)
+// 第一行为表头(与导入模板相同), 校验失败的行返回行号和错误信息, 校验通过的行在一个事务中创建
+// 密码为明文, 为空时与创建用户相同使用默认密码, 随机生成的密码在导入结果中返回
+func (uc UserController) ImportUsers(c *gin.Context) {
+	conf := config.Conf.User.Import
+	if conf == nil || conf.MaxSize <= 0 || conf.MaxRows <= 0 || conf.MaxColumns <= 0 || conf.MaxUncompressedSize <= 0 {
+		response.Fail(c, nil, "未开启用户导入")
+		return
+	}
+	maxSize := conf.MaxSize * 1024
+	// 限制请求体大小, 超出时解析表单失败, 多出的1MB用于表单的其他内容
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxSize+1<<20)
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		response.Fail(c, nil, fmt.Sprintf("请上传导入文件, 文件大小不能超过%dKB", conf.MaxSize))
+		return
+	}
+	if fileHeader.Size > maxSize {
+		response.Fail(c, nil, fmt.Sprintf("导入文件大小不能超过%dKB", conf.MaxSize))
+		return
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		response.Fail(c, nil, "读取导入文件失败: "+err.Error())
+		return
+	}
+	defer file.Close()
+
+	// 行数包括表头
+	var records [][]string
+	switch strings.ToLower(filepath.Ext(fileHeader.Filename)) {
+	case ".xlsx":
+		records, err = util.ReadXlsxRows(file, fileHeader.Size, util.XlsxLimits{
+			MaxRows:      conf.MaxRows + 1,
+			MaxColumns:   conf.MaxColumns,
+			MaxEntrySize: conf.MaxUncompressedSize * 1024,
+		})
+	case ".csv":
+		records, err = util.ReadCsvRows(file, conf.MaxRows+1, conf.MaxColumns)
+	default:
+		response.Fail(c, nil, "只支持xlsx或csv格式的文件")
+		return
+	}
+	if err != nil {
+		response.Fail(c, nil, "解析导入文件失败: "+err.Error())
+		return
+	}
+	if len(records) < 2 {
+		response.Fail(c, nil, "导入文件中没有数据")
+		return
+	}
+
+	// 解析数据行, 行号与文件中的行号一致(表头为第1行), 跳过空行
+	headers := records[0]
+	rows := make([]*vo.CreateUserRequest, 0, len(records)-1)
+	rowNums := make([]int, 0, len(records)-1)
+	rowErrors := make([]*dto.ImportRowErrorDto, 0)
+	for i, record := range records[1:] {
+		if strings.TrimSpace(strings.Join(record, "")) == "" {
+			continue
+		}
+		rowNum := i + 2
+		var row vo.CreateUserRequest
+		for _, fieldErr := range util.ParseImportRow(headers, record, &row) {
+			rowErrors = append(rowErrors, &dto.ImportRowErrorDto{Row: rowNum, Field: fieldErr.Field, Message: fieldErr.Err.Error()})
+		}
+		if row.Password != "" {
+			if err := validatePassword(row.Password); err != nil {
+				rowErrors = append(rowErrors, &dto.ImportRowErrorDto{Row: rowNum, Field: "Password", Message: err.Error()})
+			}
+		}
+		rows = append(rows, &row)
+		rowNums = append(rowNums, rowNum)
+	}
+	if len(rows) == 0 {
+		response.Fail(c, nil, "导入文件中没有数据")
+		return
+	}
+
+	// 字段规则、重复、冲突校验与校验导入用户数据相同, 使用文件中的行号
+	validateErrors, err := uc.validateImportUserRows(rows, rowNums)
+	if err != nil {
+		response.ServerError(c, nil, "校验导入用户数据失败: "+err.Error())
+		return
+	}
+	rowErrors = append(rowErrors, validateErrors...)
+
+	// 当前用户角色排序最小值（最高等级角色）以及当前用户
+	currentRoleSortMin, ctxUser, err := uc.UserRepository.GetCurrentUserMinRoleSort(c)
+	if err != nil {
+		failCurrentUser(c, err)
+		return
+	}
+	roleIds := make([]uint, 0)
+	for _, row := range rows {
+		roleIds = append(roleIds, row.RoleIds...)
+	}
+	rr := repository.NewRoleRepository()
+	allRoles, err := rr.GetRolesByIds(funk.Uniq(roleIds).([]uint))
+	if err != nil {
+		response.ServerError(c, nil, "根据角色ID获取角色信息失败: "+err.Error())
+		return
+	}
+	roleMap := make(map[uint]*model.Role, len(allRoles))
+	for _, role := range allRoles {
+		roleMap[role.ID] = role
+	}
+
+	invalidRows := make(map[int]bool)
+	for _, rowError := range rowErrors {
+		invalidRows[rowError.Row] = true
+	}
+	users := make([]*model.User, 0, len(rows))
+	// 随机生成的密码(key为用户名), 只在导入结果中返回一次
+	generatedPasswords := make(map[string]string)
+	for i, row := range rows {
+		rowNum := rowNums[i]
+		if invalidRows[rowNum] {
+			continue
+		}
+		// 角色校验与创建用户相同
+		roles := make([]*model.Role, 0, len(row.RoleIds))
+		for _, roleId := range funk.Uniq(row.RoleIds).([]uint) {
+			if role, ok := roleMap[roleId]; ok {
+				roles = append(roles, role)
+			}
+		}
+		if len(roles) == 0 {
+			rowErrors = append(rowErrors, &dto.ImportRowErrorDto{Row: rowNum, Field: "RoleIds", Message: "未获取到角色信息"})
+			continue
+		}
+		if err := checkInvalidRoleIds(row.RoleIds, roles); err != nil {
+			rowErrors = append(rowErrors, &dto.ImportRowErrorDto{Row: rowNum, Field: "RoleIds", Message: err.Error()})
+			continue
+		}
+		reqRoleSortMin := roles[0].Sort
+		for _, role := range roles {
+			if role.Sort < reqRoleSortMin {
+				reqRoleSortMin = role.Sort
+			}
+		}
+		if currentRoleSortMin >= reqRoleSortMin {
+			rowErrors = append(rowErrors, &dto.ImportRowErrorDto{Row: rowNum, Field: "RoleIds", Message: "用户不能创建比自己等级高的或者相同等级的用户"})
+			continue
+		}
+
+		// 密码为空就使用默认密码, 并要求用户下次登录时修改密码
+		passwordResetRequired := row.Password == ""
+		if passwordResetRequired {
+			password, generated, err := defaultPassword()
+			if err != nil {
+				response.ServerError(c, nil, "生成随机密码失败: "+err.Error())
+				return
+			}
+			row.Password = password
+			if generated {
+				generatedPasswords[row.Username] = password
+			}
+		}
+		nickname := row.Nickname
+		introduction := row.Introduction
+		users = append(users, &model.User{
+			Username:     row.Username,
+			Password:     util.GenPasswd(row.Password),
+			Mobile:       row.Mobile,
+			Avatar:       row.Avatar,
+			Nickname:     &nickname,
+			Introduction: &introduction,
+			Status:       row.Status,
+			Creator:      ctxUser.Username,
+			Roles:        roles,
+
+			PasswordResetRequired: passwordResetRequired,
+		})
+	}
+	// 按行号排序
+	sort.SliceStable(rowErrors, func(i, j int) bool {
+		return rowErrors[i].Row < rowErrors[j].Row
+	})
+
+	if len(users) > 0 {
+		if err := uc.UserRepository.BatchCreateUsers(users); err != nil {
+			response.ServerError(c, gin.H{"errors": rowErrors}, "导入用户失败: "+err.Error())
+			return
+		}
+	}
+	response.Success(c, gin.H{"created": len(users), "errors": rowErrors, "passwords": generatedPasswords}, "导入用户完成")
+}
+
+// 导入用户模板示例数据
+var importUserExample = vo.CreateUserRequest{
+	Username:     "zhangsan",
+	Mobile:       "13800138000",
+	Nickname:     "张三",
+	Introduction: "示例数据, 导入前请删除",
+	Status:       1,
+	RoleIds:      []uint{3},
+}
+
+// 下载导入用户模板(CSV)
+// 表头由vo.CreateUserRequest的import tag生成, 与导入解析保持一致
+func (uc UserController) GetImportTemplate(c *gin.Context) {
+	var buf bytes.Buffer
+	// 写入UTF-8 BOM, 避免Excel打开中文乱码
+	buf.WriteString("\xEF\xBB\xBF")
+	w := csv.NewWriter(&buf)
+	_ = w.Write(util.ImportHeaders(importUserExample))
+	_ = w.Write(util.ImportValues(importUserExample))
+	w.Flush()
+	if err := w.Error(); err != nil {
+		response.ServerError(c, nil, "生成导入用户模板失败: "+err.Error())
+		return
+	}
+
+	c.Header("Content-Disposition", `attachment; filename="user_import_template.csv"`)
+	c.Data(http.StatusOK, "text/csv; charset=utf-8", buf.Bytes())
+}
+
+// 校验当前用户是否有修改用户各字段的权限
+// 只校验有改动且配置了权限的字段, 当前用户拥有配置的任一角色即可修改
+func checkUserFieldPermissions(ctxUser model.User, oldUser model.User, req *vo.CreateUserRequest) error {
+	if len(config.Conf.User.FieldPermissions) == 0 {
+		return nil
+	}
+
+	oldRoleIds := make([]uint, 0, len(oldUser.Roles))
+	for _, role := range oldUser.Roles {
+		oldRoleIds = append(oldRoleIds, role.ID)
+	}
+	reqDiff, oldDiff := funk.Difference(req.RoleIds, oldRoleIds)
+	changedFields := map[string]bool{
+		"username":     req.Username != oldUser.Username,
+		"password":     req.Password != "",
+		"mobile":       req.Mobile != oldUser.Mobile,
+		"avatar":       req.Avatar != oldUser.Avatar,
+		"nickname":     stringPtrChanged(oldUser.Nickname, req.Nickname),
+		"introduction": stringPtrChanged(oldUser.Introduction, req.Introduction),
+		"status":       req.Status != oldUser.Status,
+		"roles":        len(reqDiff.([]uint)) > 0 || len(oldDiff.([]uint)) > 0,
+	}
+	return checkChangedFieldPermissions(ctxUser, changedFields)
+}
+
+// 可为空的字符串字段是否有改动, 未设置(nil)时按空字符串比较
+func stringPtrChanged(old *string, value string) bool {
+	if old == nil {
+		return value != ""
+	}
+	return *old != value
+}
+
+// 校验当前用户是否有修改指定字段的权限, changedFields为有改动的字段(key为小写字段名)
+func checkChangedFieldPermissions(ctxUser model.User, changedFields map[string]bool) error {
+	fieldPermissions := config.Conf.User.FieldPermissions
+	if len(fieldPermissions) == 0 {
+		return nil
+	}
+
+	// 当前用户的角色关键字
+	var ctxKeywords []string
+	for _, role := range ctxUser.Roles {
+		if role.Status == 1 && !role.IsExpired() {
+			ctxKeywords = append(ctxKeywords, role.Keyword)
+		}
+	}
+	// 按字段名排序, 保证错误信息稳定
+	fields := make([]string, 0, len(fieldPermissions))
+	for field := range fieldPermissions {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	for _, field := range fields {
+		keywords := fieldPermissions[field]
+		if len(keywords) == 0 || !changedFields[strings.ToLower(field)] {
+			continue
+		}
+		if len(funk.IntersectString(keywords, ctxKeywords)) == 0 {
+			return fmt.Errorf("没有修改用户字段[%s]的权限", field)
+		}
+	}
+	return nil
+}
+
+// 获取用户可添加的角色
+// 返回用户未拥有的、当前用户可以分配的正常状态角色
+func (uc UserController) GetUserAvailableRoles(c *gin.Context) {
+	// 获取path中的userId
+	userId, _ := strconv.Atoi(c.Param("userId"))
+	if userId <= 0 {
+		response.Fail(c, nil, "用户ID不正确")
+		return
+	}
+
+	// 当前用户角色排序最小值（最高等级角色）以及当前用户
+	minSort, ctxUser, err := uc.UserRepository.GetCurrentUserMinRoleSort(c)
+	if err != nil {
+		failCurrentUser(c, err)
+		return
+	}
+	// 不能更改自己的角色
+	if uint(userId) == ctxUser.ID {
+		response.Forbidden(c, nil, "不能更改自己的角色")
+		return
+	}
+	// 用户不能更改比自己角色等级高的或者相同等级的用户的角色
+	minRoleSorts, err := uc.UserRepository.GetUserMinRoleSortsByIds([]uint{uint(userId)})
+	if err != nil {
+		response.ServerError(c, nil, "根据用户ID获取用户角色排序最小值失败: "+err.Error())
+		return
+	}
+	if len(minRoleSorts) == 0 {
+		response.Fail(c, nil, "未获取到用户信息")
+		return
+	}
+	if int(minSort) >= minRoleSorts[0] {
+		response.Forbidden(c, nil, "用户不能更改比自己角色等级高的或者相同等级的用户的角色")
+		return
+	}
+
+	roles, err := uc.UserRepository.GetUserAvailableRoles(uint(userId), minSort)
+	if err != nil {
+		response.ServerError(c, nil, "获取用户可添加的角色失败: "+err.Error())
+		return
+	}
+	response.Success(c, gin.H{"roles": roles}, "获取用户可添加的角色成功")
+}
+
+// 删除指定用户的用户信息缓存
+// 用于直接修改数据库等场景, 只删除指定用户的缓存, 不影响其他用户
+func (uc UserController) EvictUserCache(c *gin.Context) {
+	var req vo.EvictUserCacheRequest
+	// 参数绑定
+	if err := bindWriteRequest(c, &req); err != nil {
+		response.Fail(c, nil, err.Error())
+		return
+	}
+	// 参数校验
+	if err := common.Validate.Struct(&req); err != nil {
+		errStr := err.(validator.ValidationErrors)[0].Translate(common.Trans)
+		response.Fail(c, nil, errStr)
+		return
+	}
+
+	ctxUser, err := uc.UserRepository.GetCurrentUser(c)
+	if err != nil {
+		failCurrentUser(c, err)
+		return
+	}
+
+	evicted := uc.UserRepository.EvictUserInfoCache(req.Usernames)
+	common.Log.Infof("用户[%s]删除用户信息缓存, 请求删除: %v, 实际删除: %v", ctxUser.Username, req.Usernames, evicted)
+	response.Success(c, gin.H{"evicted": evicted}, "删除用户信息缓存成功")
+}
+
+// 校验用户名、手机号是否已被其他用户占用(包括已删除的用户)
+// excludeId 更新用户时排除的当前用户ID, 创建用户时为0
+func checkUserConflict(ur repository.IUserRepository, username string, mobile string, excludeId uint) error {
+	var mobiles []string
+	if mobile != "" {
+		mobiles = []string{mobile}
+	}
+	conflictUsers, err := ur.GetConflictUsers([]string{username}, mobiles)
+	if err != nil {
+		return err
+	}
+	for _, user := range conflictUsers {
+		if user.ID == excludeId {
+			continue
+		}
+		if util.NormalizeUsername(user.Username) == util.NormalizeUsername(username) {
+			return errors.New(repository.MsgUsernameExists)
+		}
+		if mobile != "" && user.Mobile == mobile {
+			return errors.New(repository.MsgMobileUsed)
+		}
+	}
+	return nil
+}
+
+// 校验前端传来的角色ID中是否有无效的角色ID
+// strict: 有任何无效的角色ID都拒绝; lenient: 忽略无效的角色ID(至少需要一个有效的角色ID)
+func checkInvalidRoleIds(reqRoleIds []uint, roles []*model.Role) error {
+	if config.Conf.User.RoleIdsPolicy == "lenient" {
+		return nil
+	}
+	validRoleIds := make([]uint, 0, len(roles))
+	for _, role := range roles {
+		validRoleIds = append(validRoleIds, role.ID)
+	}
+	invalidRoleIds, _ := funk.Difference(funk.Uniq(reqRoleIds), validRoleIds)
+	if len(invalidRoleIds.([]uint)) > 0 {
+		return fmt.Errorf("角色ID无效: %v", invalidRoleIds)
+	}
+	return nil
+}
+
+// 获取是否需要首次安装初始化
+func (uc UserController) GetInitialSetupStatus(c *gin.Context) {
+	required, err := uc.UserRepository.NeedInitialSetup()
+	if err != nil {
+		response.ServerError(c, nil, "获取初始化状态失败: "+err.Error())
+		return
+	}
+	response.Success(c, gin.H{"required": required}, "获取初始化状态成功")
+}
+
+// 首次安装初始化锁, 同一时间只允许一个初始化请求
+var initialSetupLock sync.Mutex
+
+// 首次安装初始化, 创建超级管理员
+// 只在没有任何用户时可用, 需要配置的setup-token或者本机访问
+func (uc UserController) InitialSetup(c *gin.Context) {
+	if !initialSetupAllowed(c) {
+		response.Response(c, http.StatusForbidden, http.StatusForbidden, nil, "不允许访问初始化接口")
+		return
+	}
+	required, err := uc.UserRepository.NeedInitialSetup()
+	if err != nil {
+		response.ServerError(c, nil, "获取初始化状态失败: "+err.Error())
+		return
+	}
+	if !required {
+		response.Response(c, http.StatusForbidden, http.StatusForbidden, nil, "系统已初始化")
+		return
+	}
+
+	var req vo.InitialSetupRequest
+	// 参数绑定
+	if err := bindWriteRequest(c, &req); err != nil {
+		response.Fail(c, nil, err.Error())
+		return
+	}
+	// 参数校验
+	if err := common.Validate.Struct(&req); err != nil {
+		errStr := err.(validator.ValidationErrors)[0].Translate(common.Trans)
+		response.Fail(c, nil, errStr)
+		return
+	}
+	// 密码通过RSA解密
+	decodeData, err := util.RSADecrypt([]byte(req.Password), config.Conf.System.RSAPrivateBytes)
+	if err != nil {
+		response.Fail(c, nil, err.Error())
+		return
+	}
+	req.Password = string(decodeData)
+	if err := validatePassword(req.Password); err != nil {
+		response.Fail(c, nil, err.Error())
+		return
+	}
+
+	user := model.User{
+		Username:     util.NormalizeUsername(req.Username),
+		Password:     util.GenPasswd(req.Password),
+		Mobile:       req.Mobile,
+		Nickname:     &req.Nickname,
+		Introduction: new(string),
+		Status:       1,
+		Creator:      "系统",
+	}
+	// 同一时间只允许一个初始化请求
+	initialSetupLock.Lock()
+	defer initialSetupLock.Unlock()
+	err = uc.UserRepository.InitialSetup(&user)
+	if err != nil {
+		response.Fail(c, nil, "初始化失败: "+err.Error())
+		return
+	}
+	common.Log.Infof("首次安装初始化完成, 创建超级管理员[%s], IP: %s", user.Username, common.ClientIP(c))
+	response.Success(c, nil, "初始化成功")
+}
+
+// 是否允许访问初始化接口
+// 配置了setup-token时校验请求头X-Setup-Token, 否则只允许本机直接访问
+// 本机访问根据连接的远端地址判断, 不使用可以被客户端伪造的X-Forwarded-For等请求头;
+// 带有转发请求头时说明经过了代理(代理在本机时远端地址总是本机), 同样拒绝
+func initialSetupAllowed(c *gin.Context) bool {
+	if token := config.Conf.System.SetupToken; token != "" {
+		return subtle.ConstantTimeCompare([]byte(c.GetHeader("X-Setup-Token")), []byte(token)) == 1
+	}
+	if c.GetHeader("X-Forwarded-For") != "" || c.GetHeader("X-Real-Ip") != "" {
+		return false
+	}
+	host, _, err := net.SplitHostPort(c.Request.RemoteAddr)
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// 批量操作权限校验
+// 返回执行批量操作时会进行的校验项(接口权限、对象是否存在、自我保护、角色等级)及每一项的结果, 不做任何修改
+func (uc UserController) CheckBatchPermission(c *gin.Context) {
+	var req vo.BatchPermissionCheckRequest
+	// 参数绑定
+	if err := c.ShouldBind(&req); err != nil {
+		response.Fail(c, nil, err.Error())
+		return
+	}
+	// 参数校验
+	if err := common.Validate.Struct(&req); err != nil {
+		errStr := err.(validator.ValidationErrors)[0].Translate(common.Trans)
+		response.Fail(c, nil, errStr)
+		return
+	}
+
+	// 当前用户角色排序最小值（最高等级角色）以及当前用户
+	minSort, ctxUser, err := uc.UserRepository.GetCurrentUserMinRoleSort(c)
+	if err != nil {
+		failCurrentUser(c, err)
+		return
+	}
+
+	permission, err := checkBatchOperationApi(ctxUser, req.Operation)
+	if err != nil {
+		response.ServerError(c, nil, "校验接口权限失败: "+err.Error())
+		return
+	}
+	results, err := checkBatchOperation(ctxUser, minSort, req.Operation, req.Ids)
+	if err != nil {
+		response.ServerError(c, nil, "批量操作权限校验失败: "+err.Error())
+		return
+	}
+	// 没有接口权限时所有项都不允许
+	allowedCount := 0
+	for _, result := range results {
+		result.Checks = append([]*dto.PermissionCheckDto{permission}, result.Checks...)
+		if !permission.Passed {
+			result.Allowed = false
+		}
+		if result.Allowed {
+			allowedCount++
+		}
+	}
+	response.Success(c, gin.H{
+		"operation":    req.Operation,
+		"permission":   permission,
+		"results":      results,
+		"allowedCount": allowedCount,
+	}, "批量操作权限校验成功")
+}
+
+// 导出用户的全部数据(数据主体访问请求)
+// 包括用户资料、角色、最后登录信息、登录记录(本节点记录的未过期登录会话)、作为操作人的操作日志、对该用户的操作日志和角色权限变更记录, 不包含密码
+// 只能导出自己的数据, 或者由达到敏感接口角色等级且等级比该用户高的管理员导出
+// download=true时作为文件下载
+func (uc UserController) ExportUserData(c *gin.Context) {
+	// 获取path中的userId
+	userId, _ := strconv.Atoi(c.Param("userId"))
+	if userId <= 0 {
+		response.Fail(c, nil, "用户ID不正确")
+		return
+	}
+
+	// 当前用户角色排序最小值（最高等级角色）以及当前用户
+	minSort, ctxUser, err := uc.UserRepository.GetCurrentUserMinRoleSort(c)
+	if err != nil {
+		failCurrentUser(c, err)
+		return
+	}
+	if uint(userId) != ctxUser.ID {
+		if minSort > config.Conf.Security.SensitiveMinRoleSort {
+			response.Forbidden(c, nil, "当前用户角色等级不足")
+			return
+		}
+		minRoleSorts, err := uc.UserRepository.GetUserMinRoleSortsByIds([]uint{uint(userId)})
+		if err != nil {
+			response.ServerError(c, nil, "根据用户ID获取用户角色排序最小值失败: "+err.Error())
+			return
+		}
+		if len(minRoleSorts) == 0 {
+			response.Fail(c, nil, "未获取到用户信息")
+			return
+		}
+		if int(minSort) >= minRoleSorts[0] {
+			response.Forbidden(c, nil, "用户不能导出比自己角色等级高的或者相同等级的用户的数据")
+			return
+		}
+	}
+
+	user, err := uc.UserRepository.GetUserById(uint(userId))
+	if err != nil {
+		response.Fail(c, nil, "获取用户信息失败: "+err.Error())
+		return
+	}
+	or := repository.NewOperationLogRepository()
+	operationLogs, err := or.GetOperationLogsByUsername(user.Username)
+	if err != nil {
+		response.ServerError(c, nil, "获取用户操作日志失败: "+err.Error())
+		return
+	}
+	auditTrail, _, err := or.GetOperationLogsByTarget("user", user.ID, 0, 0)
+	if err != nil {
+		response.ServerError(c, nil, "获取用户操作记录失败: "+err.Error())
+		return
+	}
+	policyChanges, err := repository.NewRoleRepository().GetPolicyChangeLogsByOperator(user.Username)
+	if err != nil {
+		response.ServerError(c, nil, "获取角色权限接口变更记录失败: "+err.Error())
+		return
+	}
+
+	data := dto.UserDataExportDto{
+		Profile:       dto.ToUsersDto([]*model.User{&user})[0],
+		CreatedAt:     user.CreatedAt,
+		UpdatedAt:     user.UpdatedAt,
+		Roles:         user.Roles,
+		LoginSessions: userSessionDtos(c, user.ID),
+		OperationLogs: operationLogs,
+		AuditTrail:    auditTrail,
+		PolicyChanges: policyChanges,
+		ExportedAt:    time.Now(),
+	}
+	middleware.SetOperationLogTarget(c, "user", user.ID)
+	if c.Query("download") == "true" {
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=user_data_%d.json", user.ID))
+		c.JSON(http.StatusOK, response.FilterUserData(c, data))
+		return
+	}
+	response.Success(c, gin.H{"userData": data}, "导出用户数据成功")
+}
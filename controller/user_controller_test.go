@@ -0,0 +1,194 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"go-web-mini/common"
+	"go-web-mini/config"
+	"go-web-mini/dto"
+	"go-web-mini/model"
+	"go-web-mini/repository"
+	"go-web-mini/testutil"
+	"go-web-mini/vo"
+	"gorm.io/gorm"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResetUserPasswordUnlocksAccount(t *testing.T) {
+	setupControllerTest(t)
+	config.Conf.Security.LoginLock = &config.LoginLockConfig{MaxFailures: 3, BaseSeconds: 600, DecayHours: 1, ResetOnPasswordChange: true}
+	admin := testutil.CreateUser(t, "admin", "passwd", testutil.CreateRole(t, "admin", 1))
+	bob := testutil.CreateUser(t, "bob", "passwd", testutil.CreateRole(t, "user", 3))
+
+	// 连续登录失败后被锁定
+	lockKey := common.LoginLockKey("bob", "192.0.2.1")
+	for i := 0; i < 3; i++ {
+		common.RecordLoginFailure(lockKey)
+	}
+	if common.GetLoginLockRemaining(lockKey) <= 0 {
+		t.Fatal("连续登录失败后未锁定")
+	}
+
+	c, w := newTestContext(t, admin, http.MethodPatch, "/api/user/password/reset", map[string]string{}, "userId", strconv.Itoa(int(bob.ID)))
+	NewUserController().ResetUserPassword(c)
+	var resp struct {
+		Code int `json:"code"`
+		Data struct {
+			Password string `json:"password"`
+		} `json:"data"`
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || resp.Code != http.StatusOK {
+		t.Fatalf("重置密码返回%s", w.Body.String())
+	}
+
+	// 重置后立即可以登录
+	if remaining := common.GetLoginLockRemaining(lockKey); remaining > 0 {
+		t.Errorf("重置密码后仍被锁定%s", remaining)
+	}
+	if _, err := repository.NewUserRepository().Login(&model.User{Username: "bob", Password: resp.Data.Password}); err != nil {
+		t.Errorf("使用重置后的密码登录失败: %v", err)
+	}
+}
+
+func TestExportUserDataIncludesLoginSessions(t *testing.T) {
+	setupControllerTest(t)
+	config.Conf.Jwt.Timeout = 1
+	bob := testutil.CreateUser(t, "bob", "passwd", testutil.CreateRole(t, "user", 3))
+	loginAt := time.Now().Add(-time.Minute)
+	common.AddUserSession(bob.ID, &common.TokenSession{TokenId: "bob-token", Ip: "192.0.2.1", UserAgent: "test", LoginAt: loginAt, LastSeenAt: loginAt})
+	t.Cleanup(func() { common.RevokeUserSession(bob.ID, "bob-token") })
+
+	c, w := newTestContext(t, bob, http.MethodGet, "/api/user/data/export", nil, "userId", strconv.Itoa(int(bob.ID)))
+	NewUserController().ExportUserData(c)
+	var resp struct {
+		Code int `json:"code"`
+		Data struct {
+			UserData dto.UserDataExportDto `json:"userData"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || resp.Code != http.StatusOK {
+		t.Fatalf("导出用户数据返回%s", w.Body.String())
+	}
+	sessions := resp.Data.UserData.LoginSessions
+	if len(sessions) != 1 || sessions[0].Ip != "192.0.2.1" || !sessions[0].LoginAt.Equal(loginAt) {
+		t.Errorf("导出的登录记录为%+v, 期望包含本次登录", sessions)
+	}
+	if strings.Contains(w.Body.String(), `"password"`) {
+		t.Error("导出的用户数据中包含密码")
+	}
+}
+
+func TestUpdateProfileUnsetNicknameNotChanged(t *testing.T) {
+	setupControllerTest(t)
+	config.Conf.User.FieldPermissions = map[string][]string{"nickname": {"admin"}}
+	bob := testutil.CreateUser(t, "bob", "passwd", testutil.CreateRole(t, "user", 3))
+	// 之前创建的用户没有设置昵称
+	common.DB.Model(&model.User{}).Where("id = ?", bob.ID).UpdateColumn("nickname", gorm.Expr("NULL"))
+
+	req := vo.UpdateProfileRequest{Mobile: bob.Mobile, Introduction: "hello"}
+	c, w := newTestContext(t, bob, http.MethodPatch, "/api/user/profile", req)
+	NewUserController().UpdateProfile(c)
+	if code, msg := decodeTestResponse(t, w); code != http.StatusOK {
+		t.Fatalf("昵称未修改时更新个人资料返回%d %s, 期望为200", code, msg)
+	}
+
+	// 修改昵称仍然需要配置的角色
+	req.Nickname = "Bob"
+	c, w = newTestContext(t, bob, http.MethodPatch, "/api/user/profile", req)
+	NewUserController().UpdateProfile(c)
+	if code, _ := decodeTestResponse(t, w); code != http.StatusForbidden {
+		t.Errorf("没有权限修改昵称时返回%d, 期望为403", code)
+	}
+}
+
+func TestUpdateProfileValidatesAvatar(t *testing.T) {
+	setupControllerTest(t)
+	config.Conf.Storage = &config.StorageConfig{Type: "local", Local: &config.LocalStorageConfig{Dir: t.TempDir(), UrlPrefix: "/upload"}}
+	bob := testutil.CreateUser(t, "bob", "passwd", testutil.CreateRole(t, "user", 3))
+
+	tests := map[string]int{
+		"https://cdn.example.com/bob.png": http.StatusOK,
+		"/upload/avatar/1_abc.png":        http.StatusOK,
+		"":                                http.StatusOK,
+		"javascript:alert(1)":             http.StatusBadRequest,
+		"/api/user/delete/batch":          http.StatusBadRequest,
+		"/upload/avatar/../config.yml":    http.StatusBadRequest,
+	}
+	for avatar, want := range tests {
+		req := vo.UpdateProfileRequest{Mobile: bob.Mobile, Avatar: avatar}
+		c, w := newTestContext(t, bob, http.MethodPatch, "/api/user/profile", req)
+		NewUserController().UpdateProfile(c)
+		if code, msg := decodeTestResponse(t, w); code != want {
+			t.Errorf("头像为%q时返回%d %s, 期望为%d", avatar, code, msg, want)
+		}
+	}
+}
+
+func TestUpdateUserRoleExpiresAtRejectsHigherRole(t *testing.T) {
+	setupControllerTest(t)
+	admin := testutil.CreateRole(t, "admin", 1)
+	manager := testutil.CreateRole(t, "manager", 2)
+	user := testutil.CreateRole(t, "user", 3)
+	alice := testutil.CreateUser(t, "alice", "passwd", manager)
+	bob := testutil.CreateUser(t, "bob", "passwd", admin, user)
+	// bob的admin角色已过期, 实际等级低于alice
+	common.DB.Model(&model.UserRole{}).Where("user_id = ? AND role_id = ?", bob.ID, admin.ID).Update("expires_at", time.Now().Add(-time.Hour))
+
+	// 不能将已过期的admin角色改为永不过期
+	req := vo.UpdateUserRoleExpiresRequest{RoleId: admin.ID}
+	c, w := newTestContext(t, alice, http.MethodPatch, "/api/user/role/expires", req, "userId", strconv.Itoa(int(bob.ID)))
+	NewUserController().UpdateUserRoleExpiresAt(c)
+	if code, msg := decodeTestResponse(t, w); code != http.StatusForbidden {
+		t.Errorf("更新比自己等级高的角色的过期时间返回%d %s, 期望为403", code, msg)
+	}
+	var userRole model.UserRole
+	common.DB.Where("user_id = ? AND role_id = ?", bob.ID, admin.ID).First(&userRole)
+	if userRole.ExpiresAt == nil {
+		t.Error("已过期的admin角色被改为永不过期")
+	}
+
+	// 可以更新比自己等级低的角色
+	req = vo.UpdateUserRoleExpiresRequest{RoleId: user.ID}
+	c, w = newTestContext(t, alice, http.MethodPatch, "/api/user/role/expires", req, "userId", strconv.Itoa(int(bob.ID)))
+	NewUserController().UpdateUserRoleExpiresAt(c)
+	if code, msg := decodeTestResponse(t, w); code != http.StatusOK {
+		t.Errorf("更新比自己等级低的角色的过期时间返回%d %s, 期望为200", code, msg)
+	}
+}
+
+func TestUserCacheRequestsLimitUsers(t *testing.T) {
+	setupControllerTest(t)
+	config.Conf.Cache.MaxRequestUsers = 2
+	admin := testutil.CreateUser(t, "admin", "passwd", testutil.CreateRole(t, "admin", 1))
+
+	c, w := newTestContext(t, admin, http.MethodPost, "/api/user/cache/warm", vo.WarmUserCacheRequest{UserIds: []uint{1, 2, 3}})
+	NewUserController().WarmUserInfoCache(c)
+	if code, msg := decodeTestResponse(t, w); code != http.StatusBadRequest {
+		t.Errorf("预热超过上限的用户返回%d %s, 期望为400", code, msg)
+	}
+	c, w = newTestContext(t, admin, http.MethodPost, "/api/user/cache/evict", vo.EvictUserCacheRequest{Usernames: []string{"a", "b", "c"}})
+	NewUserController().EvictUserCache(c)
+	if code, msg := decodeTestResponse(t, w); code != http.StatusBadRequest || msg != "Usernames最多只能包含2项" {
+		t.Errorf("删除超过上限的用户缓存返回%d %s, 期望为400", code, msg)
+	}
+}
+
+func TestExportUsersCancelledWritesNoResponse(t *testing.T) {
+	setupControllerTest(t)
+	admin := testutil.CreateUser(t, "admin", "passwd", testutil.CreateRole(t, "admin", 1))
+
+	c, w := newTestContext(t, admin, http.MethodGet, "/api/user/export", nil)
+	// 客户端已断开连接
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	cancel()
+	c.Request = c.Request.WithContext(ctx)
+	NewUserController().ExportUsers(c)
+	if !c.IsAborted() || w.Body.Len() != 0 {
+		t.Errorf("请求取消后导出用户列表返回%d %s, 期望中止且不返回响应", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,40 @@
+package dto
+
+// 返回给前端的批量操作中单项的结果
+type BatchResultDto struct {
+	Id      uint   `json:"id"`
+	Success bool   `json:"success"`
+	Message string `json:"message"`
+}
+
+// 返回给前端的批量操作权限校验项
+type PermissionCheckDto struct {
+	Check  string `json:"check"`  // 校验项(permission/exists/self/hierarchy)
+	Passed bool   `json:"passed"` // 是否通过
+	Reason string `json:"reason"` // 未通过的原因
+}
+
+// 返回给前端的批量操作中单项的权限校验结果
+type BatchCheckResultDto struct {
+	Id      uint                  `json:"id"`
+	Allowed bool                  `json:"allowed"`
+	Checks  []*PermissionCheckDto `json:"checks"`
+}
+
+// 第一个未通过的校验项, 全部通过时返回nil
+func (r BatchCheckResultDto) FailedCheck() *PermissionCheckDto {
+	for _, check := range r.Checks {
+		if !check.Passed {
+			return check
+		}
+	}
+	return nil
+}
+
+// 第一个未通过的校验项的原因, 全部通过时返回空
+func (r BatchCheckResultDto) FailReason() string {
+	if check := r.FailedCheck(); check != nil {
+		return check.Reason
+	}
+	return ""
+}
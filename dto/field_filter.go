@@ -0,0 +1,192 @@
+package dto
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+// 用户数据, 返回给集成方时按API Key允许的字段过滤
+type userData interface {
+	isUserData()
+}
+
+func (UserInfoDto) isUserData()         {}
+func (UsersDto) isUserData()            {}
+func (UserRoleExpiringDto) isUserData() {}
+
+var userDataType = reflect.TypeOf((*userData)(nil)).Elem()
+
+// 按字段白名单过滤结构体(或结构体切片)的json字段, fields为空时原样返回
+// 字段名为json字段名(不区分大小写), 用于限制集成方可以获取的字段
+func FilterFields(obj interface{}, fields []string) interface{} {
+	if len(fields) == 0 {
+		return obj
+	}
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return obj
+	}
+	allowed := allowedFields(fields)
+
+	// 切片
+	var list []map[string]interface{}
+	if err := json.Unmarshal(data, &list); err == nil {
+		for _, item := range list {
+			filterMap(item, allowed)
+		}
+		return list
+	}
+	// 单个结构体
+	var item map[string]interface{}
+	if err := json.Unmarshal(data, &item); err == nil {
+		filterMap(item, allowed)
+		return item
+	}
+	return obj
+}
+
+// 字段是否在白名单中(不区分大小写), fields为空时不限制
+func FieldAllowed(fields []string, field string) bool {
+	return len(fields) == 0 || allowedFields(fields)[strings.ToLower(field)]
+}
+
+// 过滤返回数据中的全部用户数据(UserInfoDto、UsersDto及嵌入了它们的结构体), fields为空时原样返回
+// 用户数据可以在任意层级的map、切片、结构体字段中, 其余数据不变
+func FilterUserData(obj interface{}, fields []string) interface{} {
+	if len(fields) == 0 || obj == nil {
+		return obj
+	}
+	return filterUserValue(reflect.ValueOf(obj), fields)
+}
+
+func filterUserValue(v reflect.Value, fields []string) interface{} {
+	if !v.IsValid() {
+		return nil
+	}
+	if !containsUserData(v.Type(), map[reflect.Type]bool{}) {
+		return v.Interface()
+	}
+	if v.Type().Implements(userDataType) {
+		return FilterFields(v.Interface(), fields)
+	}
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return v.Interface()
+		}
+		return filterUserValue(v.Elem(), fields)
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return v.Interface()
+		}
+		list := make([]interface{}, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			list[i] = filterUserValue(v.Index(i), fields)
+		}
+		return list
+	case reflect.Map:
+		if v.IsNil() || v.Type().Key().Kind() != reflect.String {
+			return v.Interface()
+		}
+		m := make(map[string]interface{}, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			m[iter.Key().String()] = filterUserValue(iter.Value(), fields)
+		}
+		return m
+	case reflect.Struct:
+		m := make(map[string]interface{}, v.NumField())
+		filterUserStruct(v, fields, m)
+		return m
+	}
+	return v.Interface()
+}
+
+// 将包含用户数据的结构体按json字段名转为map, 过滤其中的用户数据
+func filterUserStruct(v reflect.Value, fields []string, m map[string]interface{}) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		name, omitEmpty := jsonFieldName(field)
+		if name == "-" {
+			continue
+		}
+		fv := v.Field(i)
+		// 嵌入的结构体字段展开到上一层, 与encoding/json一致
+		if field.Anonymous && name == "" && fv.Kind() == reflect.Struct {
+			filterUserStruct(fv, fields, m)
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		if omitEmpty && fv.IsZero() {
+			continue
+		}
+		m[name] = filterUserValue(fv, fields)
+	}
+}
+
+// 解析json标签中的字段名和omitempty
+func jsonFieldName(field reflect.StructField) (string, bool) {
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return "-", false
+	}
+	parts := strings.Split(tag, ",")
+	omitEmpty := false
+	for _, opt := range parts[1:] {
+		if opt == "omitempty" {
+			omitEmpty = true
+		}
+	}
+	return parts[0], omitEmpty
+}
+
+// 类型中是否包含用户数据, visited用于避免递归类型死循环
+func containsUserData(t reflect.Type, visited map[reflect.Type]bool) bool {
+	if t.Implements(userDataType) {
+		return true
+	}
+	if visited[t] {
+		return false
+	}
+	visited[t] = true
+	switch t.Kind() {
+	case reflect.Interface:
+		// 接口类型(如gin.H的值)需要按实际值判断
+		return true
+	case reflect.Ptr, reflect.Slice, reflect.Array:
+		return containsUserData(t.Elem(), visited)
+	case reflect.Map:
+		return containsUserData(t.Elem(), visited)
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			if t.Field(i).PkgPath == "" && containsUserData(t.Field(i).Type, visited) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// 白名单字段名转为小写
+func allowedFields(fields []string) map[string]bool {
+	allowed := make(map[string]bool, len(fields))
+	for _, field := range fields {
+		allowed[strings.ToLower(field)] = true
+	}
+	return allowed
+}
+
+func filterMap(item map[string]interface{}, allowed map[string]bool) {
+	for key := range item {
+		if !allowed[strings.ToLower(key)] {
+			delete(item, key)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package dto
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// 序列化为json后比较, 与接口实际返回一致
+func toJson(t *testing.T, v interface{}) string {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("序列化失败: %v", err)
+	}
+	return string(data)
+}
+
+func TestFilterFieldsIgnoresCase(t *testing.T) {
+	got := toJson(t, FilterFields(UsersDto{ID: 1, Username: "alice", Mobile: "13800000001"}, []string{"id", "USERNAME"}))
+	if want := `{"ID":1,"username":"alice"}`; got != want {
+		t.Errorf("过滤结果为%s, 期望为%s", got, want)
+	}
+}
+
+func TestFilterUserDataNested(t *testing.T) {
+	fields := []string{"id", "username"}
+	data := map[string]interface{}{
+		"users": []UsersDto{{ID: 1, Username: "alice", Mobile: "13800000001"}},
+		"total": 1,
+		"userData": UserDataExportDto{
+			Profile:    UsersDto{ID: 2, Username: "bob", Mobile: "13800000002"},
+			ExportedAt: time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC),
+		},
+		"deleted": []DeletedUserDto{{UsersDto: UsersDto{ID: 3, Username: "carol", Mobile: "13800000003"}}},
+	}
+	got := toJson(t, FilterUserData(data, fields))
+	want := `{"deleted":[{"ID":3,"username":"carol"}],"total":1,` +
+		`"userData":{"auditTrail":null,"createdAt":"0001-01-01T00:00:00Z","exportedAt":"2021-01-02T03:04:05Z",` +
+		`"loginSessions":null,"operationLogs":null,"policyChanges":null,"profile":{"ID":2,"username":"bob"},"roles":null,"updatedAt":"0001-01-01T00:00:00Z"},` +
+		`"users":[{"ID":1,"username":"alice"}]}`
+	if got != want {
+		t.Errorf("过滤结果为\n%s\n期望为\n%s", got, want)
+	}
+}
+
+func TestFilterUserDataWithoutFields(t *testing.T) {
+	data := map[string]interface{}{"users": []UsersDto{{ID: 1, Username: "alice"}}}
+	if got := FilterUserData(data, nil); toJson(t, got) != toJson(t, data) {
+		t.Errorf("未限制字段时期望原样返回, 实际为%s", toJson(t, got))
+	}
+}
@@ -1,6 +1,9 @@
 package dto
 
-import "go-web-mini/model"
+import (
+	"go-web-mini/model"
+	"time"
+)
 
 // 返回给前端的当前用户信息
 type UserInfoDto struct {
@@ -11,17 +14,20 @@ type UserInfoDto struct {
 	Nickname     string        `json:"nickname"`
 	Introduction string        `json:"introduction"`
 	Roles        []*model.Role `json:"roles"`
+	// 是否需要修改密码
+	PasswordResetRequired bool `json:"passwordResetRequired"`
 }
 
 func ToUserInfoDto(user model.User) UserInfoDto {
 	return UserInfoDto{
-		ID:           user.ID,
-		Username:     user.Username,
-		Mobile:       user.Mobile,
-		Avatar:       user.Avatar,
-		Nickname:     *user.Nickname,
-		Introduction: *user.Introduction,
-		Roles:        user.Roles,
+		ID:                    user.ID,
+		Username:              user.Username,
+		Mobile:                user.Mobile,
+		Avatar:                user.Avatar,
+		Nickname:              *user.Nickname,
+		Introduction:          *user.Introduction,
+		Roles:                 user.Roles,
+		PasswordResetRequired: user.PasswordResetRequired,
 	}
 }
 
@@ -36,28 +42,128 @@ type UsersDto struct {
 	Status       uint   `json:"status"`
 	Creator      string `json:"creator"`
 	RoleIds      []uint `json:"roleIds"`
+	// 角色名称, 与RoleIds顺序一致
+	RoleNames []string `json:"roleNames"`
+	// 最后登录时间
+	LastLoginAt *time.Time `json:"lastLoginAt"`
+	// 禁用原因
+	DisableReason string `json:"disableReason"`
+	// 版本号, 更新用户时原样传回
+	Version uint `json:"version"`
 }
 
 func ToUsersDto(userList []*model.User) []UsersDto {
 	var users []UsersDto
 	for _, user := range userList {
 		userDto := UsersDto{
-			ID:           user.ID,
-			Username:     user.Username,
-			Mobile:       user.Mobile,
-			Avatar:       user.Avatar,
-			Nickname:     *user.Nickname,
-			Introduction: *user.Introduction,
-			Status:       user.Status,
-			Creator:      user.Creator,
+			ID:            user.ID,
+			Username:      user.Username,
+			Mobile:        user.Mobile,
+			Avatar:        user.Avatar,
+			Nickname:      *user.Nickname,
+			Introduction:  *user.Introduction,
+			Status:        user.Status,
+			Creator:       user.Creator,
+			LastLoginAt:   user.LastLoginAt,
+			DisableReason: user.DisableReason,
+			Version:       user.Version,
 		}
-		roleIds := make([]uint, 0)
+		// 角色需要在查询用户时预加载, 这里不查询数据库
+		roleIds := make([]uint, 0, len(user.Roles))
+		roleNames := make([]string, 0, len(user.Roles))
 		for _, role := range user.Roles {
 			roleIds = append(roleIds, role.ID)
+			roleNames = append(roleNames, role.Name)
 		}
 		userDto.RoleIds = roleIds
+		userDto.RoleNames = roleNames
 		users = append(users, userDto)
 	}
 
 	return users
 }
+
+// 返回给前端的当前用户登录会话
+type UserSessionDto struct {
+	TokenId    string    `json:"tokenId"`
+	Ip         string    `json:"ip"`
+	UserAgent  string    `json:"userAgent"`
+	LoginAt    time.Time `json:"loginAt"`
+	LastSeenAt time.Time `json:"lastSeenAt"`
+	// 是否为当前请求使用的会话
+	Current bool `json:"current"`
+}
+
+// 返回给前端的已删除用户列表(回收站)
+type DeletedUserDto struct {
+	UsersDto
+	DeletedAt time.Time `json:"deletedAt"`
+}
+
+func ToDeletedUsersDto(userList []*model.User) []DeletedUserDto {
+	usersDto := ToUsersDto(userList)
+	users := make([]DeletedUserDto, 0, len(usersDto))
+	for i, user := range userList {
+		users = append(users, DeletedUserDto{UsersDto: usersDto[i], DeletedAt: user.DeletedAt.Time})
+	}
+	return users
+}
+
+// 返回给前端的角色即将过期的用户
+type UserRoleExpiringDto struct {
+	UserId    uint      `json:"userId"`
+	Username  string    `json:"username"`
+	Nickname  string    `json:"nickname"`
+	RoleId    uint      `json:"roleId"`
+	RoleName  string    `json:"roleName"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// 返回给前端的用户合并结果
+type MergeUserResultDto struct {
+	SourceId           uint   `json:"sourceId"`
+	TargetId           uint   `json:"targetId"`
+	MovedOperationLogs int64  `json:"movedOperationLogs"`
+	MovedCreatedUsers  int64  `json:"movedCreatedUsers"`
+	AddedRoleIds       []uint `json:"addedRoleIds"`
+}
+
+// 返回给前端的导入数据行错误
+type ImportRowErrorDto struct {
+	Row     int    `json:"row"`
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// 返回给前端的用户全部数据(用于数据主体访问请求), 不包含密码
+type UserDataExportDto struct {
+	Profile   UsersDto      `json:"profile"`
+	CreatedAt time.Time     `json:"createdAt"`
+	UpdatedAt time.Time     `json:"updatedAt"`
+	Roles     []*model.Role `json:"roles"`
+	// 登录记录(本节点记录的未过期登录会话, 包括登录IP和浏览器标识)
+	LoginSessions []UserSessionDto `json:"loginSessions"`
+	// 用户作为操作人的操作日志
+	OperationLogs []model.OperationLog `json:"operationLogs"`
+	// 对该用户的操作日志
+	AuditTrail []model.OperationLog `json:"auditTrail"`
+	// 用户修改的角色权限接口记录
+	PolicyChanges []*model.PolicyChangeLog `json:"policyChanges"`
+	ExportedAt    time.Time                `json:"exportedAt"`
+}
+
+// 返回给前端的当前用户权限(全部未被禁用且未过期的角色的并集)
+type UserPermissionsDto struct {
+	// 角色关键字
+	Roles []string `json:"roles"`
+	// 可访问的接口
+	Apis []*UserApiPermissionDto `json:"apis"`
+	// 可访问的菜单树
+	MenuTree []*model.Menu `json:"menuTree"`
+}
+
+// 可访问的接口
+type UserApiPermissionDto struct {
+	Method string `json:"method"`
+	Path   string `json:"path"`
+}
@@ -40,6 +40,7 @@ require (
 	gopkg.in/yaml.v2 v2.4.0 // indirect
 	gorm.io/driver/mysql v1.0.4
 	gorm.io/driver/postgres v1.0.7 // indirect
+	gorm.io/driver/sqlite v1.1.4
 	gorm.io/driver/sqlserver v1.0.6 // indirect
 	gorm.io/gorm v1.20.12
 )
@@ -8,6 +8,7 @@ import (
 	"go-web-mini/middleware"
 	"go-web-mini/repository"
 	"go-web-mini/routes"
+	"go-web-mini/storage"
 	"net/http"
 	"os"
 	"os/signal"
@@ -35,6 +36,9 @@ func main() {
 	// 初始化mysql数据
 	common.InitData()
 
+	// 初始化文件存储
+	storage.InitStorage()
+
 	// 操作日志中间件处理日志时没有将日志发送到rabbitmq或者kafka中, 而是发送到了channel中
 	// 这里开启3个goroutine处理channel将日志记录到数据库
 	logRepository := repository.NewOperationLogRepository()
@@ -42,6 +46,36 @@ func main() {
 		go logRepository.SaveOperationLogChannel(middleware.OperationLogChan)
 	}
 
+	// 注册定时任务
+	// 定期预热活跃用户信息缓存, 避免缓存清理或服务重启后大量请求同时访问数据库
+	userRepository := repository.NewUserRepository()
+	common.AddScheduleJob("预热用户信息缓存", time.Minute*time.Duration(config.Conf.Cache.WarmInterval), func() {
+		count, err := userRepository.WarmUserInfoCache(nil)
+		if err != nil {
+			common.Log.Errorf("预热用户信息缓存失败: %v", err)
+			return
+		}
+		common.Log.Infof("预热用户信息缓存完成, 共缓存%d个用户", count)
+	})
+	// 多实例部署时定期轮询缓存失效记录, 删除其他节点修改过的用户的本地缓存
+	common.AddScheduleJob("同步用户信息缓存失效", time.Second*time.Duration(config.Conf.Cache.InvalidationPollInterval), func() {
+		if _, err := userRepository.PollUserCacheInvalidations(); err != nil {
+			common.Log.Errorf("同步用户信息缓存失效失败: %v", err)
+		}
+	})
+	// 定期禁用长期未登录的用户
+	if config.Conf.User.InactiveDisableDays > 0 {
+		common.AddScheduleJob("禁用长期未登录用户", time.Minute*time.Duration(config.Conf.User.InactiveCheckInterval), func() {
+			disabled, err := userRepository.DisableInactiveUsers(config.Conf.User.InactiveDisableDays, config.Conf.User.InactiveExemptUsernames)
+			if err != nil {
+				common.Log.Errorf("禁用长期未登录用户失败: %v", err)
+			}
+			if len(disabled) > 0 {
+				common.Log.Infof("禁用长期未登录用户完成, 共禁用%d个用户: %v", len(disabled), disabled)
+			}
+		})
+	}
+
 	// 注册所有路由
 	r := routes.InitRoutes()
 
@@ -65,7 +99,7 @@ func main() {
 
 	// Wait for interrupt signal to gracefully shutdown the server with
 	// a timeout of 5 seconds.
-	quit := make(chan os.Signal)
+	quit := make(chan os.Signal, 1)
 	// kill (no param) default send syscall.SIGTERM
 	// kill -2 is syscall.SIGINT
 	// kill -9 is syscall.SIGKILL but can't be catch, so don't need add it
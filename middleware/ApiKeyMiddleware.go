@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"github.com/gin-gonic/gin"
+	"go-web-mini/common"
+	"go-web-mini/config"
+	"go-web-mini/response"
+)
+
+// API Key中间件, 识别集成方请求头中的X-Api-Key
+// 只用于限制集成方可以获取的字段, 登录认证仍然使用jwt, 认证后在authorizator中校验API Key与登录用户是否匹配
+func ApiKeyMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader("X-Api-Key")
+		if key == "" {
+			c.Next()
+			return
+		}
+		for _, apiKey := range config.Conf.Security.ApiKeys {
+			if apiKey.Key != "" && subtle.ConstantTimeCompare([]byte(key), []byte(apiKey.Key)) == 1 {
+				c.Set(common.ApiKeyContextKey, apiKey)
+				c.Next()
+				return
+			}
+		}
+		response.Unauthorized(c, nil, "无效的API Key")
+		c.Abort()
+	}
+}
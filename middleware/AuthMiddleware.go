@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"errors"
 	"fmt"
 	jwt "github.com/appleboy/gin-jwt/v2"
 	"github.com/gin-gonic/gin"
@@ -11,6 +12,8 @@ import (
 	"go-web-mini/response"
 	"go-web-mini/util"
 	"go-web-mini/vo"
+	"math"
+	"net/http"
 	"time"
 )
 
@@ -32,11 +35,135 @@ func InitAuth() (*jwt.GinJWTMiddleware, error) {
 		TokenLookup:     "header: Authorization, query: token, cookie: jwt",    // 自动在这几个地方寻找请求中的token
 		TokenHeadName:   "Bearer",                                              // header名称
 		TimeFunc:        time.Now,
+		SendCookie:      config.Conf.Jwt.SendCookie,   // 是否写入cookie
+		SecureCookie:    config.Conf.Jwt.SecureCookie, // cookie只通过HTTPS发送
+		CookieHTTPOnly:  true,
+		CookieSameSite:  http.SameSiteLaxMode,
 	})
+	if config.Conf.Jwt.SendCookie && config.Conf.Jwt.SecureCookie && !config.Conf.System.RequireHttps {
+		common.Log.Warn("jwt已开启Secure cookie但未开启system.require-https, 通过HTTP访问时将无法使用cookie认证, 且token可能通过明文传输")
+	}
+	if config.Conf.Jwt.SendCookie && !config.Conf.Jwt.SecureCookie {
+		common.Log.Warn("jwt cookie未设置Secure, token可能通过HTTP明文传输, 正式环境请开启jwt.secure-cookie")
+	}
 	return authMiddleware, err
 }
 
-// 有效载荷处理
+// jwt认证中间件, 在gin-jwt中间件的基础上支持只读请求的过期宽限
+// 开启jwt.read-leeway后, GET、HEAD请求在token过期后的宽限时间内仍然通过认证, 并返回X-Token-Expired头提示前端刷新token
+// 修改数据的请求不受影响, 始终要求token未过期
+func JwtMiddleware(mw *jwt.GinJWTMiddleware) gin.HandlerFunc {
+	handler := mw.MiddlewareFunc()
+	return func(c *gin.Context) {
+		if err := checkTokenSession(mw, c); err != nil {
+			unauthorized(c, http.StatusUnauthorized, err.Error())
+			c.Abort()
+			return
+		}
+		renewToken(mw, c)
+		leeway := time.Second * time.Duration(config.Conf.Jwt.ReadLeeway)
+		if leeway > 0 && (c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead) {
+			if claims, ok := graceExpiredClaims(mw, c, leeway); ok {
+				c.Set("JWT_PAYLOAD", claims)
+				identity := mw.IdentityHandler(c)
+				if identity != nil {
+					c.Set(mw.IdentityKey, identity)
+				}
+				if !mw.Authorizator(identity, c) {
+					unauthorized(c, http.StatusForbidden, mw.HTTPStatusMessageFunc(jwt.ErrForbidden, c))
+					c.Abort()
+					return
+				}
+				c.Header("X-Token-Expired", "true")
+				c.Next()
+				return
+			}
+		}
+		handler(c)
+	}
+}
+
+// 获取已过期但仍在宽限时间内的token的claims
+// token未过期或不在宽限时间内时返回false, 由gin-jwt中间件按原逻辑处理
+func graceExpiredClaims(mw *jwt.GinJWTMiddleware, c *gin.Context, leeway time.Duration) (jwt.MapClaims, bool) {
+	// 校验签名, 只忽略过期错误
+	claims, err := mw.CheckIfTokenExpire(c)
+	if err != nil {
+		return nil, false
+	}
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return nil, false
+	}
+	now := mw.TimeFunc()
+	expireAt := time.Unix(int64(exp), 0)
+	if !expireAt.Before(now) || now.Sub(expireAt) > leeway {
+		return nil, false
+	}
+	return jwt.MapClaims(claims), true
+}
+
+// 校验token会话是否有效: 未登出, 且为用户最新登录的会话(开启jwt.single-session时)
+// token解析失败时不处理, 由gin-jwt中间件返回对应的错误
+func checkTokenSession(mw *jwt.GinJWTMiddleware, c *gin.Context) error {
+	claims, err := mw.CheckIfTokenExpire(c)
+	if err != nil {
+		return nil
+	}
+	userId, _ := claims[jwt.IdentityKey].(float64)
+	tokenId, _ := claims["jti"].(string)
+	if common.IsTokenIdRevoked(tokenId) {
+		return errors.New("token已退出登录, 请重新登录")
+	}
+	if !common.IsUserSessionActive(uint(userId), tokenId) {
+		return errors.New("账号已在其他地方登录, 请重新登录")
+	}
+	common.TouchUserSession(uint(userId), tokenId)
+	return nil
+}
+
+// 滑动续期, 开启jwt.renew-threshold后token剩余有效时间低于阈值时, 在响应头中返回新token(开启send-cookie时同时写入cookie)
+// 需要在checkTokenSession之后调用, 已登出的token不会续期, 已过期、超过最大刷新时间或用户校验失败时不续期
+func renewToken(mw *jwt.GinJWTMiddleware, c *gin.Context) {
+	threshold := config.Conf.Jwt.RenewThreshold
+	if threshold <= 0 {
+		return
+	}
+	claims, err := mw.CheckIfTokenExpire(c)
+	if err != nil {
+		return
+	}
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return
+	}
+	remaining := time.Unix(int64(exp), 0).Sub(mw.TimeFunc())
+	if remaining <= 0 || remaining > mw.Timeout*time.Duration(threshold)/100 {
+		return
+	}
+	// 与刷新token相同的校验
+	userId, _ := claims[jwt.IdentityKey].(float64)
+	userRepository := repository.NewUserRepository()
+	if _, err := userRepository.GetRefreshTokenUser(uint(userId)); err != nil {
+		return
+	}
+	token, expire, err := mw.RefreshToken(c)
+	if err != nil {
+		common.Log.Warnf("token续期失败: %v", err)
+		return
+	}
+	c.Header("X-Renewed-Token", token)
+	c.Header("X-Renewed-Token-Expires", expire.Format("2006-01-02 15:04:05"))
+}
+
+// 获取当前请求token的token ID(jti), 需要在jwt认证中间件之后调用
+func CurrentTokenId(c *gin.Context) string {
+	tokenId, _ := jwt.ExtractClaims(c)["jti"].(string)
+	return tokenId
+}
+
+// 有效载荷处理(仅登录时调用, 刷新token时沿用原token的载荷)
+// token ID(jti)在登录时生成
 func payloadFunc(data interface{}) jwt.MapClaims {
 	if v, ok := data.(map[string]interface{}); ok {
 		var user model.User
@@ -44,6 +171,7 @@ func payloadFunc(data interface{}) jwt.MapClaims {
 		util.JsonI2Struct(v["user"], &user)
 		return jwt.MapClaims{
 			jwt.IdentityKey: user.ID,
+			"jti":           v["jti"],
 			"user":          v["user"],
 		}
 	}
@@ -61,7 +189,12 @@ func identityHandler(c *gin.Context) interface{} {
 }
 
 // 校验token的正确性, 处理登录逻辑
+// 开启两步验证的用户密码校验通过后返回挑战, 再通过两步验证登录接口提交挑战和验证码完成登录
 func login(c *gin.Context) (interface{}, error) {
+	if c.GetBool(twoFactorLoginContextKey) {
+		return twoFactorLogin(c)
+	}
+
 	var req vo.RegisterAndLoginRequest
 	// 请求json绑定
 	if err := c.ShouldBind(&req); err != nil {
@@ -79,16 +212,81 @@ func login(c *gin.Context) (interface{}, error) {
 		Password: string(decodeData),
 	}
 
+	// 登录失败次数过多时锁定
+	lockKey := common.LoginLockKey(req.Username, common.ClientIP(c))
+	if remaining := common.GetLoginLockRemaining(lockKey); remaining > 0 {
+		common.LogWithContext(c).Warnw("用户登录失败", "username", req.Username, "ip", common.ClientIP(c), "reason", "登录失败次数过多, 已锁定")
+		return nil, fmt.Errorf("登录失败次数过多, 请%d秒后重试", int(math.Ceil(remaining.Seconds())))
+	}
+
 	// 密码校验
 	userRepository := repository.NewUserRepository()
 	user, err := userRepository.Login(u)
 	if err != nil {
+		common.LogWithContext(c).Warnw("用户登录失败", "username", req.Username, "ip", common.ClientIP(c), "reason", err.Error())
+		if lockDuration := common.RecordLoginFailure(lockKey); lockDuration > 0 {
+			return nil, fmt.Errorf("%s, 登录失败次数过多, 请%d秒后重试", err.Error(), int(lockDuration.Seconds()))
+		}
+		return nil, err
+	}
+	common.ResetLoginFailure(lockKey)
+	if user.TwoFactorEnabled {
+		c.Set(twoFactorChallengeContextKey, common.NewTwoFactorChallenge(user.ID))
+		return nil, errTwoFactorRequired
+	}
+	return loginSuccess(c, *user), nil
+}
+
+// 两步验证登录(登录第二步), 校验挑战和验证码
+// 验证码错误次数过多时挑战失效, 需要重新输入密码
+func twoFactorLogin(c *gin.Context) (interface{}, error) {
+	var req vo.TwoFactorLoginRequest
+	if err := c.ShouldBind(&req); err != nil {
+		return nil, err
+	}
+	userId, ok := common.GetTwoFactorChallengeUserId(req.Challenge)
+	if !ok {
+		return nil, errors.New("两步验证已过期, 请重新登录")
+	}
+	// 从数据库获取最新的用户信息, 校验规则与登录相同
+	userRepository := repository.NewUserRepository()
+	user, err := userRepository.GetRefreshTokenUser(userId)
+	if err != nil {
+		common.DeleteTwoFactorChallenge(req.Challenge)
 		return nil, err
 	}
+	secret, err := util.RSADecrypt([]byte(user.TwoFactorSecret), config.Conf.System.RSAPrivateBytes)
+	if err != nil || !util.ValidateTOTP(string(secret), req.Code, time.Now()) {
+		common.LogWithContext(c).Warnw("用户两步验证失败", "username", user.Username, "user_id", user.ID, "ip", common.ClientIP(c))
+		if remaining := common.RecordTwoFactorFailure(req.Challenge); remaining > 0 {
+			return nil, fmt.Errorf("验证码错误, 还可以尝试%d次", remaining)
+		}
+		return nil, errors.New("验证码错误次数过多, 请重新登录")
+	}
+	common.DeleteTwoFactorChallenge(req.Challenge)
+	return loginSuccess(c, *user), nil
+}
+
+// 登录成功处理, 返回payloadFunc使用的数据
+func loginSuccess(c *gin.Context, user model.User) map[string]interface{} {
+	// 登录响应中返回是否需要修改密码
+	c.Set(passwordResetRequiredContextKey, user.PasswordResetRequired)
+	// 每次登录生成新的token ID(jti), 记录登录会话(开启单会话登录时之前的token失效)
+	tokenId := common.NewTokenId()
+	now := time.Now()
+	common.AddUserSession(user.ID, &common.TokenSession{
+		TokenId:    tokenId,
+		Ip:         common.ClientIP(c),
+		UserAgent:  c.Request.UserAgent(),
+		LoginAt:    now,
+		LastSeenAt: now,
+	})
+	common.LogWithContext(c).Infow("用户登录成功", "username", user.Username, "user_id", user.ID, "ip", common.ClientIP(c))
 	// 将用户以json格式写入, payloadFunc/authorizator会使用到
 	return map[string]interface{}{
 		"user": util.Struct2Json(user),
-	}, nil
+		"jti":  tokenId,
+	}
 }
 
 // 用户登录校验成功处理
@@ -100,25 +298,53 @@ func authorizator(data interface{}, c *gin.Context) bool {
 		util.Json2Struct(userStr, &user)
 		// 将用户保存到context, api调用时取数据方便
 		c.Set("user", user)
-		return true
+		// 集成方账号必须使用绑定的API Key
+		return common.ApiKeyMatchesUser(c, user.Username)
 	}
 	return false
 }
 
 // 用户登录校验失败处理
+// 开启两步验证的用户密码校验通过时返回挑战, 不作为登录失败处理
 func unauthorized(c *gin.Context, code int, message string) {
+	if challenge, exists := c.Get(twoFactorChallengeContextKey); exists {
+		response.Success(c, gin.H{"twoFactorRequired": true, "challenge": challenge}, "需要两步验证")
+		return
+	}
 	common.Log.Debugf("JWT认证失败, 错误码: %d, 错误信息: %s", code, message)
 	response.Response(c, code, code, nil, fmt.Sprintf("JWT认证失败, 错误码: %d, 错误信息: %s", code, message))
 }
 
+// 登录时是否需要修改密码在gin context中的key
+const passwordResetRequiredContextKey = "passwordResetRequired"
+
+// 两步验证登录在gin context中的key
+const (
+	twoFactorLoginContextKey     = "twoFactorLogin"
+	twoFactorChallengeContextKey = "twoFactorChallenge"
+)
+
+// 需要两步验证时Authenticator返回的错误, 由unauthorized返回挑战
+var errTwoFactorRequired = errors.New("需要两步验证")
+
+// 两步验证登录(登录第二步), 需要在LoginHandler之前注册
+func TwoFactorLoginStep() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(twoFactorLoginContextKey, true)
+		c.Next()
+	}
+}
+
 // 登录成功后的响应
+// passwordResetRequired为true时前端需要跳转到修改密码页面
 func loginResponse(c *gin.Context, code int, token string, expires time.Time) {
-	response.Response(c, code, code,
-		gin.H{
-			"token":   token,
-			"expires": expires.Format("2006-01-02 15:04:05"),
-		},
-		"登录成功")
+	data := gin.H{
+		"token":                 token,
+		"expires":               expires.Format("2006-01-02 15:04:05"),
+		"passwordResetRequired": c.GetBool(passwordResetRequiredContextKey),
+	}
+	setServerTime(data)
+	response.Response(c, code, code, data, "登录成功")
 }
 
 // 登出后的响应
@@ -128,10 +354,75 @@ func logoutResponse(c *gin.Context, code int) {
 
 // 刷新token后的响应
 func refreshResponse(c *gin.Context, code int, token string, expires time.Time) {
-	response.Response(c, code, code,
-		gin.H{
-			"token":   token,
-			"expires": expires,
-		},
-		"刷新token成功")
+	data := gin.H{
+		"token":   token,
+		"expires": expires,
+	}
+	setServerTime(data)
+	response.Response(c, code, code, data, "刷新token成功")
+}
+
+// 开启后在响应中返回服务器时间(RFC3339, UTC), 方便前端校正时钟偏差
+func setServerTime(data gin.H) {
+	if config.Conf.Jwt.ServerTime {
+		data["serverTime"] = time.Now().UTC().Format(time.RFC3339)
+	}
+}
+
+// 刷新token前的校验, 需要在RefreshHandler之前注册
+// 用户不存在、被禁用或角色全部被禁用(已过期)时不能刷新token, 校验规则与登录相同
+func RefreshTokenCheck(mw *jwt.GinJWTMiddleware) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, err := mw.CheckIfTokenExpire(c)
+		if err != nil {
+			unauthorized(c, http.StatusUnauthorized, err.Error())
+			c.Abort()
+			return
+		}
+		userId, ok := claims[jwt.IdentityKey].(float64)
+		if !ok {
+			unauthorized(c, http.StatusUnauthorized, "token中没有用户信息")
+			c.Abort()
+			return
+		}
+		if err := checkTokenSession(mw, c); err != nil {
+			unauthorized(c, http.StatusUnauthorized, err.Error())
+			c.Abort()
+			return
+		}
+		userRepository := repository.NewUserRepository()
+		if _, err := userRepository.GetRefreshTokenUser(uint(userId)); err != nil {
+			unauthorized(c, http.StatusUnauthorized, err.Error())
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// 退出登录前将当前token加入黑名单, 需要在LogoutHandler之前注册
+// 刷新得到的token沿用原token的jti, 黑名单保存到原token可以刷新的最长时间, 同一次登录的token全部失效
+// 同时删除用户信息缓存, 重新登录时获取最新的用户信息
+// token无效或已过期时不处理, 同样返回退出成功
+func RevokeTokenCheck(mw *jwt.GinJWTMiddleware) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, err := mw.CheckIfTokenExpire(c)
+		if err != nil {
+			c.Next()
+			return
+		}
+		tokenId, _ := claims["jti"].(string)
+		userId, _ := claims[jwt.IdentityKey].(float64)
+		// 没有会话记录(如服务重启)时按原token的签发时间加入黑名单
+		if !common.RevokeUserSession(uint(userId), tokenId) {
+			if origIat, ok := claims["orig_iat"].(float64); ok {
+				common.RevokeTokenId(tokenId, time.Unix(int64(origIat), 0).Add(common.TokenIdMaxAge()))
+			}
+		}
+		if userId > 0 {
+			userRepository := repository.NewUserRepository()
+			userRepository.DeleteUserInfoCache(uint(userId))
+		}
+		c.Next()
+	}
 }
@@ -4,8 +4,10 @@ import (
 	"github.com/gin-gonic/gin"
 	"go-web-mini/common"
 	"go-web-mini/config"
+	"go-web-mini/model"
 	"go-web-mini/repository"
 	"go-web-mini/response"
+	"go-web-mini/util"
 	"strings"
 	"sync"
 )
@@ -18,23 +20,25 @@ func CasbinMiddleware() gin.HandlerFunc {
 		ur := repository.NewUserRepository()
 		user, err := ur.GetCurrentUser(c)
 		if err != nil {
-			response.Response(c, 401, 401, nil, "用户未登录")
+			response.Unauthorized(c, nil, "用户未登录")
 			c.Abort()
 			return
 		}
 		if user.Status != 1 {
-			response.Response(c, 401, 401, nil, "当前用户已被禁用")
+			msg := "当前用户已被禁用"
+			if user.DisableReason != "" {
+				msg += ": " + user.DisableReason
+			}
+			response.Unauthorized(c, nil, msg)
 			c.Abort()
 			return
 		}
-		// 获得用户的全部角色
-		roles := user.Roles
-		// 获得用户全部未被禁用的角色的Keyword
-		var subs []string
-		for _, role := range roles {
-			if role.Status == 1 {
-				subs = append(subs, role.Keyword)
-			}
+		// 获得角色的Keyword
+		subs, err := userRoleKeywords(user)
+		if err != nil {
+			response.Response(c, 500, 500, nil, "获取用户角色失败")
+			c.Abort()
+			return
 		}
 		// 获得请求路径URL
 		//obj := strings.Replace(c.Request.URL.Path, "/"+config.Conf.System.UrlPathPrefix, "", 1)
@@ -42,9 +46,22 @@ func CasbinMiddleware() gin.HandlerFunc {
 		// 获取请求方式
 		act := c.Request.Method
 
-		isPass := check(subs, obj, act)
+		isPass, matchedSub, matchedPolicy := check(subs, obj, act)
+		// 记录鉴权决策日志
+		if config.Conf.Casbin.DecisionLog {
+			common.Log.Info(util.Struct2Json(map[string]interface{}{
+				"requestId":     common.GetRequestId(c),
+				"username":      user.Username,
+				"subs":          subs,
+				"obj":           obj,
+				"act":           act,
+				"allow":         isPass,
+				"matchedSub":    matchedSub,
+				"matchedPolicy": matchedPolicy,
+			}))
+		}
 		if !isPass {
-			response.Response(c, 401, 401, nil, "没有权限")
+			response.Forbidden(c, nil, "没有权限")
 			c.Abort()
 			return
 		}
@@ -53,17 +70,50 @@ func CasbinMiddleware() gin.HandlerFunc {
 	}
 }
 
-func check(subs []string, obj string, act string) bool {
+// 获得用户全部未被禁用且未过期的角色(开启角色继承时加上继承的角色)的Keyword
+func userRoleKeywords(user model.User) ([]string, error) {
+	var activeRoles []*model.Role
+	for _, role := range user.Roles {
+		if role.Status == 1 && !role.IsExpired() {
+			activeRoles = append(activeRoles, role)
+		}
+	}
+	rr := repository.NewRoleRepository()
+	roles, err := rr.GetEffectiveRoles(activeRoles)
+	if err != nil {
+		return nil, err
+	}
+	var subs []string
+	for _, role := range roles {
+		subs = append(subs, role.Keyword)
+	}
+	return subs, nil
+}
+
+// 用户是否拥有接口的访问权限(与Casbin中间件的校验规则相同)
+// path 不带url前缀的接口路径, 如/user/delete/batch
+func HasApiPermission(user model.User, path string, method string) (bool, error) {
+	if user.Status != 1 {
+		return false, nil
+	}
+	subs, err := userRoleKeywords(user)
+	if err != nil {
+		return false, err
+	}
+	isPass, _, _ := check(subs, path, method)
+	return isPass, nil
+}
+
+// 校验权限, 通过时同时返回匹配的角色和策略
+func check(subs []string, obj string, act string) (bool, string, []string) {
 	// 同一时间只允许一个请求执行校验, 否则可能会校验失败
 	checkLock.Lock()
 	defer checkLock.Unlock()
-	isPass := false
 	for _, sub := range subs {
-		pass, _ := common.CasbinEnforcer.Enforce(sub, obj, act)
+		pass, explain, _ := common.CasbinEnforcer.EnforceEx(sub, obj, act)
 		if pass {
-			isPass = true
-			break
+			return true, sub, explain
 		}
 	}
-	return isPass
+	return false, "", nil
 }
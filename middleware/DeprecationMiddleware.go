@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"go-web-mini/common"
+	"go-web-mini/model"
+	"net/http"
+	"time"
+)
+
+// 接口废弃中间件, 在注册路由时标记该接口已废弃
+// sunset 计划下线日期(格式: 2006-01-02), successor 替代接口路径(为空则不返回Link头)
+// 响应中返回Deprecation、Sunset头, 并记录调用情况, 方便统计迁移进度
+func Deprecated(sunset string, successor string) gin.HandlerFunc {
+	sunsetTime, err := time.ParseInLocation("2006-01-02", sunset, time.Local)
+	if err != nil {
+		common.Log.Panicf("接口废弃中间件下线日期格式有误: %s", sunset)
+	}
+	sunsetHeader := sunsetTime.UTC().Format(http.TimeFormat)
+
+	return func(c *gin.Context) {
+		c.Header("Deprecation", "true")
+		c.Header("Sunset", sunsetHeader)
+		if successor != "" {
+			c.Header("Link", fmt.Sprintf("<%s>; rel=\"successor-version\"", successor))
+		}
+
+		// 获取当前登录用户
+		username := "未登录"
+		if ctxUser, exists := c.Get("user"); exists {
+			if user, ok := ctxUser.(model.User); ok {
+				username = user.Username
+			}
+		}
+		common.Log.Warnf("调用已废弃接口: %s %s, 计划下线日期: %s, 调用者: %s, IP: %s",
+			c.Request.Method, c.FullPath(), sunset, username, common.ClientIP(c))
+
+		c.Next()
+	}
+}
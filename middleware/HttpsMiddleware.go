@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"go-web-mini/common"
+	"go-web-mini/config"
+	"go-web-mini/response"
+	"strings"
+)
+
+// HTTPS中间件, 开启后拒绝通过HTTP访问的请求
+// 部署在终止TLS的代理之后时通过可信代理(system.trusted-proxies)传入的X-Forwarded-Proto判断
+func HttpsMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !config.Conf.System.RequireHttps || IsHttpsRequest(c) {
+			c.Next()
+			return
+		}
+		response.Forbidden(c, nil, "请使用HTTPS访问")
+		c.Abort()
+	}
+}
+
+// 是否为HTTPS请求, 只信任可信代理传入的X-Forwarded-Proto
+func IsHttpsRequest(c *gin.Context) bool {
+	if c.Request.TLS != nil {
+		return true
+	}
+	return common.FromTrustedProxy(c.Request) && strings.EqualFold(c.GetHeader("X-Forwarded-Proto"), "https")
+}
@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"go-web-mini/config"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestIsHttpsRequestTrustsOnlyProxies(t *testing.T) {
+	defer func(system *config.SystemConfig) { config.Conf.System = system }(config.Conf.System)
+	config.Conf.System = &config.SystemConfig{TrustedProxies: []string{"192.0.2.1"}}
+
+	tests := map[string]bool{
+		// 可信代理终止TLS后转发
+		"192.0.2.1:1234": true,
+		// 客户端直接伪造请求头
+		"203.0.113.5:1234": false,
+	}
+	for remoteAddr, want := range tests {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest("GET", "/api/user/info", nil)
+		c.Request.RemoteAddr = remoteAddr
+		c.Request.Header.Set("X-Forwarded-Proto", "https")
+		if got := IsHttpsRequest(c); got != want {
+			t.Errorf("来自%s且X-Forwarded-Proto为https的请求是否为HTTPS返回%v, 期望为%v", remoteAddr, got, want)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package middleware
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/juju/ratelimit"
+	"github.com/patrickmn/go-cache"
+	"go-web-mini/common"
+	"go-web-mini/config"
+	"go-web-mini/model"
+	"go-web-mini/response"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// 按IP或用户名限流的令牌桶, key为"路由名称:维度:IP或用户名"
+// 一段时间没有请求的令牌桶自动删除(此时桶已填满, 删除不影响限流)
+var (
+	keyedRateLimitCache = cache.New(time.Hour, 10*time.Minute)
+	keyedRateLimitMutex sync.Mutex
+)
+
+// 按IP或用户名限流中间件, 使用rate-limit.routes中route对应的配置, 未配置或容量为0时不限流
+// 按用户名限流时需要在jwt认证中间件之后注册, 未登录时按IP限流
+func KeyedRateLimitMiddleware(route string) gin.HandlerFunc {
+	conf, ok := config.Conf.RateLimit.Routes[route]
+	if !ok || conf == nil || conf.Capacity <= 0 || conf.FillInterval <= 0 {
+		return func(c *gin.Context) {
+			c.Next()
+		}
+	}
+	fillInterval := time.Millisecond * time.Duration(conf.FillInterval)
+	retryAfter := strconv.Itoa(int(math.Ceil(fillInterval.Seconds())))
+	common.Log.Infof("接口[%s]按%s限流, 填充间隔: %s, 桶容量: %d", route, conf.By, fillInterval, conf.Capacity)
+
+	return func(c *gin.Context) {
+		key := fmt.Sprintf("%s:ip:%s", route, common.ClientIP(c))
+		if conf.By == "username" {
+			if ctxUser, exists := c.Get("user"); exists {
+				if user, ok := ctxUser.(model.User); ok && user.Username != "" {
+					key = fmt.Sprintf("%s:username:%s", route, user.Username)
+				}
+			}
+		}
+		if keyedRateLimitBucket(key, fillInterval, conf.Capacity).TakeAvailable(1) < 1 {
+			c.Header("Retry-After", retryAfter)
+			response.Response(c, http.StatusTooManyRequests, http.StatusTooManyRequests, nil, "请求过于频繁, 请稍后重试")
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// 获取key对应的令牌桶, 不存在时创建, 每次获取都会延长过期时间
+func keyedRateLimitBucket(key string, fillInterval time.Duration, capacity int64) *ratelimit.Bucket {
+	keyedRateLimitMutex.Lock()
+	defer keyedRateLimitMutex.Unlock()
+	// 过期时间至少为填满令牌桶需要的时间
+	expiration := fillInterval * time.Duration(capacity)
+	if expiration < time.Minute {
+		expiration = time.Minute
+	}
+	if v, found := keyedRateLimitCache.Get(key); found {
+		bucket := v.(*ratelimit.Bucket)
+		keyedRateLimitCache.Set(key, bucket, expiration)
+		return bucket
+	}
+	bucket := ratelimit.NewBucket(fillInterval, capacity)
+	keyedRateLimitCache.Set(key, bucket, expiration)
+	return bucket
+}
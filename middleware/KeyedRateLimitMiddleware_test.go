@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"go-web-mini/common"
+	"go-web-mini/config"
+	"go.uber.org/zap"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestKeyedRateLimitIgnoresSpoofedForwardedFor(t *testing.T) {
+	defer func(system *config.SystemConfig, rateLimit *config.RateLimitConfig) {
+		config.Conf.System, config.Conf.RateLimit = system, rateLimit
+	}(config.Conf.System, config.Conf.RateLimit)
+	common.Log = zap.NewNop().Sugar()
+	config.Conf.System = &config.SystemConfig{}
+	config.Conf.RateLimit = &config.RateLimitConfig{Routes: map[string]*config.RouteRateLimitConfig{
+		"test-spoof": {By: "ip", FillInterval: 60000, Capacity: 1},
+	}}
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/login", KeyedRateLimitMiddleware("test-spoof"), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	// 同一个客户端每次请求伪造不同的X-Forwarded-For
+	codes := make([]int, 0, 2)
+	for _, forwardedFor := range []string{"198.51.100.1", "198.51.100.2"} {
+		req := httptest.NewRequest("POST", "/login", nil)
+		req.RemoteAddr = "203.0.113.5:1234"
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		codes = append(codes, w.Code)
+	}
+	if codes[0] != http.StatusOK || codes[1] != http.StatusTooManyRequests {
+		t.Errorf("伪造X-Forwarded-For的两次请求返回%v, 期望第二次被限流", codes)
+	}
+}
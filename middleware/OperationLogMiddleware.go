@@ -1,10 +1,14 @@
 package middleware
 
 import (
+	"encoding/json"
 	"github.com/gin-gonic/gin"
+	"go-web-mini/common"
 	"go-web-mini/config"
 	"go-web-mini/model"
 	"go-web-mini/repository"
+	"net/http"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -12,6 +16,79 @@ import (
 // 操作日志channel
 var OperationLogChan = make(chan *model.OperationLog, 30)
 
+// 操作日志的操作对象在gin context中的key
+const (
+	operationLogTargetTypeKey = "operationLogTargetType"
+	operationLogTargetIdsKey  = "operationLogTargetIds"
+	operationLogActionKey     = "operationLogAction"
+	operationLogPayloadKey    = "operationLogPayload"
+)
+
+// 请求参数摘要的最大长度
+const operationLogPayloadMaxLength = 1000
+
+// 设置操作日志的操作对象(如被创建、更新、删除的用户), 在接口处理成功后调用
+func SetOperationLogTarget(c *gin.Context, targetType string, ids ...uint) {
+	var b strings.Builder
+	b.WriteString(",")
+	for _, id := range ids {
+		b.WriteString(strconv.FormatUint(uint64(id), 10))
+		b.WriteString(",")
+	}
+	c.Set(operationLogTargetTypeKey, targetType)
+	c.Set(operationLogTargetIdsKey, b.String())
+}
+
+// 设置操作日志的操作类型和请求参数摘要, 在接口处理成功后调用
+// payload为请求参数结构体(可为nil), 字段名包含password的字段不记录
+func SetOperationLogAction(c *gin.Context, action string, payload interface{}) {
+	c.Set(operationLogActionKey, action)
+	c.Set(operationLogPayloadKey, operationLogPayloadSummary(payload))
+}
+
+// 生成请求参数摘要, 去掉密码字段并限制长度
+func operationLogPayloadSummary(payload interface{}) string {
+	if payload == nil {
+		return ""
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return ""
+	}
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return ""
+	}
+	data, err = json.Marshal(removePasswordFields(value))
+	if err != nil {
+		return ""
+	}
+	summary := []rune(string(data))
+	if len(summary) > operationLogPayloadMaxLength {
+		return string(summary[:operationLogPayloadMaxLength]) + "..."
+	}
+	return string(summary)
+}
+
+// 递归删除字段名包含password的字段(不区分大小写)
+func removePasswordFields(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			if strings.Contains(strings.ToLower(key), "password") {
+				delete(v, key)
+				continue
+			}
+			v[key] = removePasswordFields(item)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = removePasswordFields(item)
+		}
+	}
+	return value
+}
+
 func OperationLogMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// 开始时间
@@ -49,7 +126,7 @@ func OperationLogMiddleware() gin.HandlerFunc {
 
 		operationLog := model.OperationLog{
 			Username:   username,
-			Ip:         c.ClientIP(),
+			Ip:         common.ClientIP(c),
 			IpLocation: "",
 			Method:     method,
 			Path:       path,
@@ -57,8 +134,39 @@ func OperationLogMiddleware() gin.HandlerFunc {
 			Status:     c.Writer.Status(),
 			StartTime:  startTime,
 			TimeCost:   timeCost,
+			RequestId:  common.GetRequestId(c),
 			//UserAgent:  c.Request.UserAgent(),
 		}
+		// 操作对象
+		if targetType, exists := c.Get(operationLogTargetTypeKey); exists {
+			operationLog.TargetType = targetType.(string)
+			operationLog.TargetIds = c.GetString(operationLogTargetIdsKey)
+		}
+		// 操作类型和请求参数摘要
+		operationLog.Action = c.GetString(operationLogActionKey)
+		operationLog.Payload = c.GetString(operationLogPayloadKey)
+
+		// 修改数据的请求记录结构化日志, 失败的请求提高日志级别
+		if method != http.MethodGet && method != http.MethodHead && method != http.MethodOptions {
+			logFields := []interface{}{
+				"username", username,
+				"user_id", user.ID,
+				"method", method,
+				"path", path,
+				"action", operationLog.Action,
+				"status", operationLog.Status,
+				"time_cost_ms", timeCost,
+			}
+			logger := common.LogWithContext(c)
+			switch {
+			case operationLog.Status >= http.StatusInternalServerError:
+				logger.Errorw("修改数据请求失败", logFields...)
+			case operationLog.Status >= http.StatusBadRequest:
+				logger.Warnw("修改数据请求失败", logFields...)
+			default:
+				logger.Infow("修改数据请求完成", logFields...)
+			}
+		}
 
 		// 最好是将日志发送到rabbitmq或者kafka中
 		// 这里是发送到channel中，开启3个goroutine处理
@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/thoas/go-funk"
+	"go-web-mini/config"
+	"go-web-mini/model"
+	"go-web-mini/response"
+	"net/http"
+	"strings"
+)
+
+// 只读模式下仍然允许的非GET接口(登录相关、只读的检测接口)
+var readOnlyAllowedPaths = []string{
+	"/base/login",
+	"/base/login/2fa",
+	"/base/logout",
+	"/base/refreshToken",
+	"/base/password/strength",
+	"/user/batch/check",
+}
+
+// 只读模式中间件, 用于演示环境, 开启后拒绝所有修改数据的请求
+// 需要在jwt认证中间件之后注册, 配置的免检用户(如维护者的超级管理员账号)不受限制
+// 没有通过GET请求修改数据的接口, 所以只拦截非GET请求
+func ReadOnlyMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !config.Conf.System.ReadOnly {
+			c.Next()
+			return
+		}
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+			return
+		}
+		path := strings.TrimPrefix(c.FullPath(), "/"+config.Conf.System.UrlPathPrefix)
+		if funk.ContainsString(readOnlyAllowedPaths, path) {
+			c.Next()
+			return
+		}
+		if ctxUser, exists := c.Get("user"); exists {
+			if user, ok := ctxUser.(model.User); ok && funk.ContainsString(config.Conf.System.ReadOnlyExemptUsernames, user.Username) {
+				c.Next()
+				return
+			}
+		}
+		response.Forbidden(c, nil, "演示环境为只读模式, 不能修改数据")
+		c.Abort()
+	}
+}
@@ -0,0 +1,123 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"go-web-mini/common"
+	"go-web-mini/config"
+	"go-web-mini/model"
+	"go-web-mini/testutil"
+	"go-web-mini/util"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// 生成测试使用的RSA密钥对(PEM格式)
+func newTestRSAKeys(t *testing.T) (publicBytes []byte, privateBytes []byte) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("生成RSA密钥失败: %v", err)
+	}
+	publicDer, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatalf("编码RSA公钥失败: %v", err)
+	}
+	publicBytes = pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDer})
+	privateBytes = pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	return publicBytes, privateBytes
+}
+
+// 计算当前时间的TOTP验证码
+func testTOTPCode(t *testing.T, secret string) string {
+	t.Helper()
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	if err != nil {
+		t.Fatalf("解析两步验证密钥失败: %v", err)
+	}
+	msg := make([]byte, 8)
+	binary.BigEndian.PutUint64(msg, uint64(time.Now().Unix()/30))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg)
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	return fmt.Sprintf("%06d", (binary.BigEndian.Uint32(sum[offset:offset+4])&0x7fffffff)%1000000)
+}
+
+// 发送json请求, 返回响应中的code和data
+func postTestJson(t *testing.T, r http.Handler, path string, body gin.H) (int, map[string]interface{}) {
+	t.Helper()
+	data, _ := json.Marshal(body)
+	req := httptest.NewRequest("POST", path, bytes.NewReader(data))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	var resp struct {
+		Code int                    `json:"code"`
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("解析响应失败: %v, 响应: %s", err, w.Body.String())
+	}
+	return resp.Code, resp.Data
+}
+
+func TestReadOnlyAllowsTwoFactorLogin(t *testing.T) {
+	testutil.SetupDB(t)
+	publicBytes, privateBytes := newTestRSAKeys(t)
+	config.Conf.System = &config.SystemConfig{UrlPathPrefix: "api", ReadOnly: true, RSAPublicBytes: publicBytes, RSAPrivateBytes: privateBytes}
+	config.Conf.Jwt = &config.JwtConfig{Realm: "test", Key: "test", Timeout: 1, MaxRefresh: 1}
+
+	secret, err := util.GenerateTOTPSecret()
+	if err != nil {
+		t.Fatalf("生成两步验证密钥失败: %v", err)
+	}
+	encryptedSecret, err := util.RSAEncrypt([]byte(secret), publicBytes)
+	if err != nil {
+		t.Fatalf("加密两步验证密钥失败: %v", err)
+	}
+	user := testutil.CreateUser(t, "alice", "passwd", testutil.CreateRole(t, "user", 3))
+	err = common.DB.Model(&model.User{}).Where("id = ?", user.ID).
+		Updates(map[string]interface{}{"two_factor_enabled": true, "two_factor_secret": string(encryptedSecret)}).Error
+	if err != nil {
+		t.Fatalf("开启两步验证失败: %v", err)
+	}
+
+	authMiddleware, err := InitAuth()
+	if err != nil {
+		t.Fatalf("初始化jwt中间件失败: %v", err)
+	}
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	router := r.Group("/api/base")
+	router.Use(ReadOnlyMiddleware())
+	router.POST("/login", authMiddleware.LoginHandler)
+	router.POST("/login/2fa", TwoFactorLoginStep(), authMiddleware.LoginHandler)
+
+	password, err := util.RSAEncrypt([]byte("passwd"), publicBytes)
+	if err != nil {
+		t.Fatalf("加密密码失败: %v", err)
+	}
+	code, data := postTestJson(t, r, "/api/base/login", gin.H{"username": "alice", "password": string(password)})
+	challenge, _ := data["challenge"].(string)
+	if code != 200 || challenge == "" {
+		t.Fatalf("只读模式下登录返回%d, %v, 期望返回两步验证挑战", code, data)
+	}
+
+	code, data = postTestJson(t, r, "/api/base/login/2fa", gin.H{"challenge": challenge, "code": testTOTPCode(t, secret)})
+	if code != 200 || data["token"] == nil {
+		t.Errorf("只读模式下两步验证登录返回%d, %v, 期望登录成功", code, data)
+	}
+}
@@ -0,0 +1,21 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"go-web-mini/common"
+)
+
+// 请求ID中间件
+// 优先使用请求头X-Request-Id中的请求ID(格式有误时重新生成), 没有时生成新的请求ID
+// 请求ID保存到上下文中, 并通过响应头X-Request-Id返回, 方便根据请求ID排查日志
+func RequestIdMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestId := c.GetHeader(common.RequestIdHeader)
+		if !common.IsValidRequestId(requestId) {
+			requestId = common.NewRequestId()
+		}
+		common.SetRequestId(c, requestId)
+		c.Header(common.RequestIdHeader, requestId)
+		c.Next()
+	}
+}
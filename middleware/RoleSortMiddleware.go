@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"go-web-mini/repository"
+	"go-web-mini/response"
+)
+
+// 角色等级中间件, 要求当前用户最高等级角色的排序不大于maxSort(排序越小等级越高)
+// 作为Casbin细粒度鉴权的补充, 用于清理日志、预热缓存等敏感接口
+func RequireMinRoleSort(maxSort uint) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ur := repository.NewUserRepository()
+		minSort, _, err := ur.GetCurrentUserMinRoleSort(c)
+		if err != nil {
+			response.Unauthorized(c, nil, "用户未登录")
+			c.Abort()
+			return
+		}
+		if minSort > maxSort {
+			response.Forbidden(c, nil, "当前用户角色等级不足")
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
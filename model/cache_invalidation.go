@@ -0,0 +1,12 @@
+package model
+
+import "time"
+
+// 用户信息缓存失效记录
+// 多实例部署时修改用户的节点写入记录, 其他节点定时轮询并删除本地缓存
+type CacheInvalidation struct {
+	ID        uint      `gorm:"primarykey" json:"ID"`
+	UserId    uint      `gorm:"not null;comment:'用户ID(0表示清理全部缓存)'" json:"userId"`
+	Node      string    `gorm:"type:varchar(32);not null;comment:'写入记录的节点'" json:"node"`
+	CreatedAt time.Time `gorm:"index" json:"createdAt"`
+}
@@ -17,4 +17,12 @@ type OperationLog struct {
 	StartTime  time.Time `gorm:"type:datetime(3);comment:'发起时间'" json:"startTime"`
 	TimeCost   int64     `gorm:"type:int(6);comment:'请求耗时(ms)'" json:"timeCost"`
 	UserAgent  string    `gorm:"type:varchar(20);comment:'浏览器标识'" json:"userAgent"`
+	// 操作对象类型和ID(格式为",1,2,", 方便按ID查询)
+	TargetType string `gorm:"type:varchar(20);index;comment:'操作对象类型'" json:"targetType"`
+	TargetIds  string `gorm:"type:text;comment:'操作对象ID'" json:"targetIds"`
+	// 操作类型(如user-create)和请求参数摘要(不包含密码)
+	Action  string `gorm:"type:varchar(30);index;comment:'操作类型'" json:"action"`
+	Payload string `gorm:"type:text;comment:'请求参数摘要'" json:"payload"`
+	// 请求ID, 与响应头X-Request-Id一致, 方便根据请求ID查找操作日志
+	RequestId string `gorm:"type:varchar(64);index;comment:'请求ID'" json:"requestId"`
 }
@@ -0,0 +1,11 @@
+package model
+
+import "time"
+
+// 用户历史密码, 用于禁止重复使用最近的密码
+type PasswordHistory struct {
+	ID           uint      `gorm:"primarykey" json:"ID"`
+	UserId       uint      `gorm:"index;not null;comment:'用户ID'" json:"userId"`
+	PasswordHash string    `gorm:"size:255;not null;comment:'加密后的密码'" json:"-"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
@@ -0,0 +1,14 @@
+package model
+
+import "gorm.io/gorm"
+
+// 角色权限接口变更记录
+type PolicyChangeLog struct {
+	gorm.Model
+	RoleId      uint   `gorm:"index;comment:'角色ID'" json:"roleId"`
+	RoleKeyword string `gorm:"type:varchar(20);comment:'角色关键字'" json:"roleKeyword"`
+	Operator    string `gorm:"type:varchar(20);comment:'操作人'" json:"operator"`
+	// 新增和删除的接口权限(JSON格式, 每项为[path, method])
+	Added   string `gorm:"type:text;comment:'新增的接口权限'" json:"added"`
+	Removed string `gorm:"type:text;comment:'删除的接口权限'" json:"removed"`
+}
@@ -1,6 +1,9 @@
 package model
 
-import "gorm.io/gorm"
+import (
+	"gorm.io/gorm"
+	"time"
+)
 
 type Role struct {
 	gorm.Model
@@ -12,4 +15,15 @@ type Role struct {
 	Creator string  `gorm:"type:varchar(20);" json:"creator"`
 	Users   []*User `gorm:"many2many:user_roles" json:"users"`
 	Menus   []*Menu `gorm:"many2many:role_menus;" json:"menus"` // 角色菜单多对多关系
+	// 父角色ID, 开启角色继承时角色会继承父角色(及父角色的父角色)的权限
+	ParentRoleId *uint `gorm:"comment:'父角色ID'" json:"parentRoleId"`
+	// 角色的接口权限数量(包括继承的权限), 仅在需要权限概要时填充
+	PolicyCount *int `gorm:"-" json:"policyCount,omitempty"`
+	// 用户拥有该角色的过期时间, 仅在查询用户的角色时填充
+	ExpiresAt *time.Time `gorm:"-" json:"expiresAt,omitempty"`
+}
+
+// 角色是否已过期
+func (r Role) IsExpired() bool {
+	return r.ExpiresAt != nil && !r.ExpiresAt.After(time.Now())
 }
@@ -1,6 +1,9 @@
 package model
 
-import "gorm.io/gorm"
+import (
+	"gorm.io/gorm"
+	"time"
+)
 
 type User struct {
 	gorm.Model
@@ -13,4 +16,16 @@ type User struct {
 	Status       uint    `gorm:"type:tinyint(1);default:1;comment:'1正常, 2禁用'" json:"status"`
 	Creator      string  `gorm:"type:varchar(20);" json:"creator"`
 	Roles        []*Role `gorm:"many2many:user_roles" json:"roles"`
+	// 最后登录时间
+	LastLoginAt *time.Time `gorm:"comment:'最后登录时间'" json:"lastLoginAt"`
+	// 禁用原因(如长期未登录自动禁用), 启用时清空
+	DisableReason string `gorm:"type:varchar(100);comment:'禁用原因'" json:"disableReason"`
+	// 下次登录时是否需要修改密码(创建用户时使用默认密码), 修改密码后清除
+	PasswordResetRequired bool `gorm:"default:false;comment:'下次登录时需要修改密码'" json:"passwordResetRequired"`
+	// 版本号(乐观锁), 每次更新用户时加1
+	Version uint `gorm:"not null;default:0;comment:'版本号'" json:"version"`
+	// 两步验证(TOTP)密钥, RSA公钥加密后保存, 不返回给前端
+	TwoFactorSecret string `gorm:"type:varchar(512);comment:'两步验证密钥'" json:"-"`
+	// 是否已开启两步验证, 绑定密钥并校验验证码后开启
+	TwoFactorEnabled bool `gorm:"default:false;comment:'是否开启两步验证'" json:"twoFactorEnabled"`
 }
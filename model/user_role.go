@@ -0,0 +1,10 @@
+package model
+
+import "time"
+
+// 用户角色关联(自定义中间表, 支持角色过期时间)
+type UserRole struct {
+	UserId    uint       `gorm:"primaryKey" json:"userId"`
+	RoleId    uint       `gorm:"primaryKey" json:"roleId"`
+	ExpiresAt *time.Time `gorm:"comment:'角色过期时间(为空表示永不过期)'" json:"expiresAt"`
+}
@@ -108,7 +108,7 @@ func (a ApiRepository) GetApiTree() ([]*dto.ApiTreeDto, error) {
 // 创建接口
 func (a ApiRepository) CreateApi(api *model.Api) error {
 	err := common.DB.Create(api).Error
-	return err
+	return translateDuplicateKeyError(err, nil)
 }
 
 // 更新接口
@@ -121,7 +121,7 @@ func (a ApiRepository) UpdateApiById(apiId uint, api *model.Api) error {
 	}
 	err = common.DB.Model(api).Where("id = ?", apiId).Updates(api).Error
 	if err != nil {
-		return err
+		return translateDuplicateKeyError(err, nil)
 	}
 	// 更新了method和path就更新casbin中policy
 	if oldApi.Path != api.Path || oldApi.Method != api.Method {
@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"go-web-mini/common"
+	"go-web-mini/config"
+	"go-web-mini/model"
+	"sync"
+	"time"
+)
+
+// 清理全部用户信息缓存的失效记录用户ID
+const cacheInvalidationFlushAll uint = 0
+
+// 当前节点ID, 轮询时跳过本节点写入的记录
+var cacheNodeId = newCacheNodeId()
+
+// 轮询时重复读取上次轮询之前这段时间内写入的记录
+// 自增ID按分配顺序而不是提交顺序递增, 各节点的时钟也可能有偏差, 只读取ID或写入时间更大的记录会漏掉较晚提交的记录
+const cacheInvalidationPollOverlap = time.Minute
+
+var (
+	// 上次轮询的时间
+	lastCacheInvalidationPoll time.Time
+	// 重复读取窗口内已处理的记录, key为记录ID, value为记录写入时间
+	processedCacheInvalidations = make(map[uint]time.Time)
+	cacheInvalidationPollLock   sync.Mutex
+)
+
+func newCacheNodeId() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return time.Now().Format("20060102150405.000000")
+	}
+	return hex.EncodeToString(b)
+}
+
+// 是否开启基于数据库的缓存失效同步
+func cacheInvalidationEnabled() bool {
+	return config.Conf.Cache.InvalidationPollInterval > 0
+}
+
+// 写入用户信息缓存失效记录, 通知其他节点删除这些用户的本地缓存
+// 未开启时不处理, 写入失败只记录日志(缓存最多在过期前不一致)
+func publishUserCacheInvalidation(userIds ...uint) {
+	if !cacheInvalidationEnabled() || len(userIds) == 0 {
+		return
+	}
+	records := make([]model.CacheInvalidation, 0, len(userIds))
+	for _, userId := range userIds {
+		records = append(records, model.CacheInvalidation{UserId: userId, Node: cacheNodeId})
+	}
+	if err := common.DB.Create(&records).Error; err != nil {
+		common.Log.Errorf("写入用户信息缓存失效记录失败: %v", err)
+	}
+}
+
+// 轮询其他节点写入的缓存失效记录并删除本地缓存, 返回处理的记录数量
+// 按写入时间读取上次轮询前cacheInvalidationPollOverlap之后的记录, 已处理过的记录跳过
+// 首次轮询会处理保留时间内的全部记录, 启动后本地缓存较少, 多删除的缓存只是多一次数据库查询
+func (ur UserRepository) PollUserCacheInvalidations() (int, error) {
+	cacheInvalidationPollLock.Lock()
+	defer cacheInvalidationPollLock.Unlock()
+
+	now := time.Now()
+	db := common.DB.Order("id")
+	if !lastCacheInvalidationPoll.IsZero() {
+		db = db.Where("created_at >= ?", lastCacheInvalidationPoll.Add(-cacheInvalidationPollOverlap))
+	}
+	var records []model.CacheInvalidation
+	if err := db.Find(&records).Error; err != nil {
+		return 0, err
+	}
+	lastCacheInvalidationPoll = now
+	count := 0
+	for _, record := range records {
+		if _, processed := processedCacheInvalidations[record.ID]; processed {
+			continue
+		}
+		processedCacheInvalidations[record.ID] = record.CreatedAt
+		if record.Node == cacheNodeId {
+			continue
+		}
+		if record.UserId == cacheInvalidationFlushAll {
+			userInfoCache.Flush()
+		} else {
+			deleteUserInfoCache(record.UserId)
+		}
+		count++
+	}
+	// 下次轮询不会再读取到的记录不需要再记录
+	for id, createdAt := range processedCacheInvalidations {
+		if createdAt.Before(now.Add(-cacheInvalidationPollOverlap)) {
+			delete(processedCacheInvalidations, id)
+		}
+	}
+
+	// 清理超过保留时间的记录
+	retention := config.Conf.Cache.InvalidationRetention
+	if retention > 0 {
+		threshold := time.Now().Add(-time.Minute * time.Duration(retention))
+		err := common.DB.Where("created_at < ?", threshold).Delete(&model.CacheInvalidation{}).Error
+		if err != nil {
+			common.Log.Errorf("清理用户信息缓存失效记录失败: %v", err)
+		}
+	}
+	return count, nil
+}
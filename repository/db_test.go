@@ -0,0 +1,14 @@
+package repository
+
+import (
+	"go-web-mini/testutil"
+	"testing"
+)
+
+// 初始化测试数据库和默认配置, 测试前后清空用户信息缓存
+func setupTestDB(t *testing.T) {
+	t.Helper()
+	testutil.SetupDB(t)
+	userInfoCache.Flush()
+	t.Cleanup(userInfoCache.Flush)
+}
@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"errors"
+	"strings"
+)
+
+// 唯一索引冲突(数据已存在), 调用方可以用errors.Is判断后返回400
+var ErrDuplicateKey = errors.New("数据已存在")
+
+// 用户名、手机号已被其他用户占用的提示, 创建、更新、导入用户和数据库唯一索引冲突时使用相同的提示
+const (
+	MsgUsernameExists = "用户名已存在"
+	MsgMobileUsed     = "手机号已被使用"
+)
+
+// 唯一索引冲突的错误, 错误信息为友好的提示
+type duplicateKeyError struct {
+	msg string
+}
+
+func (e duplicateKeyError) Error() string {
+	return e.msg
+}
+
+func (e duplicateKeyError) Is(target error) bool {
+	return target == ErrDuplicateKey
+}
+
+// 将唯一索引冲突的数据库错误转换为友好的提示, 其他错误原样返回
+// messages的key为索引字段名(如username), 都不匹配时提示"数据已存在"
+// MySQL错误格式: Error 1062: Duplicate entry 'xxx' for key 'users.username'
+// SQLite错误格式: UNIQUE constraint failed: users.username
+func translateDuplicateKeyError(err error, messages map[string]string) error {
+	if err == nil {
+		return nil
+	}
+	msg := err.Error()
+	var key string
+	if strings.Contains(msg, "Error 1062") {
+		key = msg
+		if i := strings.LastIndex(msg, "for key "); i >= 0 {
+			key = strings.Trim(msg[i+len("for key "):], "'")
+		}
+	} else if i := strings.Index(msg, "UNIQUE constraint failed: "); i >= 0 {
+		key = msg[i+len("UNIQUE constraint failed: "):]
+	} else {
+		return err
+	}
+	for field, fieldMsg := range messages {
+		if strings.HasSuffix(key, field) {
+			return duplicateKeyError{msg: fieldMsg}
+		}
+	}
+	return duplicateKeyError{msg: ErrDuplicateKey.Error()}
+}
@@ -55,13 +55,13 @@ func GenMenuTree(parentId uint, menus []*model.Menu) []*model.Menu {
 // 创建菜单
 func (m MenuRepository) CreateMenu(menu *model.Menu) error {
 	err := common.DB.Create(menu).Error
-	return err
+	return translateDuplicateKeyError(err, nil)
 }
 
 // 更新菜单
 func (m MenuRepository) UpdateMenuById(menuId uint, menu *model.Menu) error {
 	err := common.DB.Model(menu).Where("id = ?", menuId).Updates(menu).Error
-	return err
+	return translateDuplicateKeyError(err, nil)
 }
 
 // 批量删除菜单
@@ -83,8 +83,16 @@ func (m MenuRepository) GetUserMenusByUserId(userId uint) ([]*model.Menu, error)
 	if err != nil {
 		return nil, err
 	}
-	// 获取角色
-	roles := user.Roles
+	// 过滤已过期的角色
+	err = fillUserRolesExpiresAt(&user)
+	if err != nil {
+		return nil, err
+	}
+	// 获取未被禁用的角色, 开启角色继承时加上继承的角色
+	roles, err := activeEffectiveRoles(user.Roles)
+	if err != nil {
+		return nil, err
+	}
 	// 所有角色的菜单集合
 	allRoleMenus := make([]*model.Menu, 0)
 	for _, role := range roles {
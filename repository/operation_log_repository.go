@@ -12,6 +12,9 @@ type IOperationLogRepository interface {
 	GetOperationLogs(req *vo.OperationLogListRequest) ([]model.OperationLog, int64, error)
 	BatchDeleteOperationLogByIds(ids []uint) error
 	SaveOperationLogChannel(olc <-chan *model.OperationLog) //处理OperationLogChan将日志记录到数据库
+
+	GetOperationLogsByTarget(targetType string, targetId uint, pageNum int, pageSize int) ([]model.OperationLog, int64, error) // 获取对指定对象的操作日志
+	GetOperationLogsByUsername(username string) ([]model.OperationLog, error)                                                  // 获取指定用户作为操作人的全部操作日志
 }
 
 type OperationLogRepository struct {
@@ -41,6 +44,10 @@ func (o OperationLogRepository) GetOperationLogs(req *vo.OperationLogListRequest
 	if status != 0 {
 		db = db.Where("status = ?", status)
 	}
+	action := strings.TrimSpace(req.Action)
+	if action != "" {
+		db = db.Where("action = ?", action)
+	}
 
 	// 分页
 	var total int64
@@ -81,3 +88,31 @@ func (o OperationLogRepository) SaveOperationLogChannel(olc <-chan *model.Operat
 		}
 	}
 }
+
+// 获取对指定对象的操作日志, 按时间倒序
+func (o OperationLogRepository) GetOperationLogsByTarget(targetType string, targetId uint, pageNum int, pageSize int) ([]model.OperationLog, int64, error) {
+	var list []model.OperationLog
+	db := common.DB.Model(&model.OperationLog{}).
+		Where("target_type = ? AND target_ids LIKE ?", targetType, fmt.Sprintf("%%,%d,%%", targetId)).
+		Order("start_time DESC")
+
+	// 分页
+	var total int64
+	err := db.Count(&total).Error
+	if err != nil {
+		return list, total, err
+	}
+	if pageNum > 0 && pageSize > 0 {
+		err = db.Offset((pageNum - 1) * pageSize).Limit(pageSize).Find(&list).Error
+	} else {
+		err = db.Find(&list).Error
+	}
+	return list, total, err
+}
+
+// 获取指定用户作为操作人的全部操作日志
+func (o OperationLogRepository) GetOperationLogsByUsername(username string) ([]model.OperationLog, error) {
+	var list []model.OperationLog
+	err := common.DB.Where("username = ?", username).Order("start_time DESC").Find(&list).Error
+	return list, err
+}
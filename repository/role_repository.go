@@ -3,22 +3,39 @@ package repository
 import (
 	"errors"
 	"fmt"
+	gormadapter "github.com/casbin/gorm-adapter/v3"
 	"go-web-mini/common"
+	"go-web-mini/config"
 	"go-web-mini/model"
+	"go-web-mini/util"
 	"go-web-mini/vo"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"strings"
 )
 
 type IRoleRepository interface {
-	GetRoles(req *vo.RoleListRequest) ([]model.Role, int64, error)       // 获取角色列表
-	GetRolesByIds(roleIds []uint) ([]*model.Role, error)                 // 根据角色ID获取角色
-	CreateRole(role *model.Role) error                                   // 创建角色
-	UpdateRoleById(roleId uint, role *model.Role) error                  // 更新角色
-	GetRoleMenusById(roleId uint) ([]*model.Menu, error)                 // 获取角色的权限菜单
-	UpdateRoleMenus(role *model.Role) error                              // 更新角色的权限菜单
-	GetRoleApisByRoleKeyword(roleKeyword string) ([]*model.Api, error)   // 根据角色关键字获取角色的权限接口
-	UpdateRoleApis(roleKeyword string, reqRolePolicies [][]string) error // 更新角色的权限接口（先全部删除再新增）
-	BatchDeleteRoleByIds(roleIds []uint) error                           // 删除角色
+	GetRoles(req *vo.RoleListRequest) ([]model.Role, int64, error)                      // 获取角色列表
+	GetRolesByIds(roleIds []uint) ([]*model.Role, error)                                // 根据角色ID获取角色
+	CreateRole(role *model.Role) error                                                  // 创建角色
+	UpdateRoleById(roleId uint, role *model.Role) error                                 // 更新角色
+	GetRoleMenusById(roleId uint) ([]*model.Menu, error)                                // 获取角色的权限菜单
+	UpdateRoleMenus(role *model.Role) error                                             // 更新角色的权限菜单
+	GetRoleApisByRoleKeyword(roleKeyword string) ([]*model.Api, error)                  // 根据角色关键字获取角色的权限接口
+	UpdateRoleApis(role *model.Role, reqRolePolicies [][]string, operator string) error // 更新角色的权限接口（先全部删除再新增）
+	BatchDeleteRoleByIds(roleIds []uint) error                                          // 删除角色
+
+	FillRoleSummaries(roles []*model.Role) error                     // 填充角色的权限概要(包括继承的权限)
+	GetEffectiveRoles(roles []*model.Role) ([]*model.Role, error)    // 获取角色及其继承的所有角色(未开启角色继承时原样返回)
+	GetRoleInheritChainIds(roleId uint) ([]uint, error)              // 获取角色的继承链(从该角色开始沿父角色向上)
+	GetRolesBySort(sort uint, excludeId uint) ([]*model.Role, error) // 获取指定排序的角色(排除指定ID的角色)
+	GetNextFreeRoleSort(sort uint) (uint, error)                     // 获取大于等于指定排序的第一个未使用的排序
+
+	BatchSetRoleStatus(roleIds []uint, status uint) ([]*model.Role, int, error) // 批量修改角色状态, 返回修改后的角色和删除缓存的用户数量
+	PreviewDisableRole(roleId uint) ([]*model.User, error)                      // 预览禁用角色的影响, 返回禁用后将无法登录的用户
+
+	GetPolicyChangeLogs(roleId uint, pageNum int, pageSize int) ([]*model.PolicyChangeLog, int64, error) // 获取角色权限接口变更记录
+	GetPolicyChangeLogsByOperator(operator string) ([]*model.PolicyChangeLog, error)                     // 获取指定用户操作的全部角色权限接口变更记录
 }
 
 type RoleRepository struct {
@@ -59,9 +76,86 @@ func (r RoleRepository) GetRoles(req *vo.RoleListRequest) ([]model.Role, int64,
 	} else {
 		err = db.Find(&list).Error
 	}
+	if err == nil && req.WithSummary {
+		roles := make([]*model.Role, 0, len(list))
+		for i := range list {
+			roles = append(roles, &list[i])
+		}
+		err = r.FillRoleSummaries(roles)
+	}
 	return list, total, err
 }
 
+// 填充角色的权限概要(菜单和接口权限数量)
+// 与Casbin中间件相同, 开启角色继承时包括继承的角色的权限
+// 继承的角色从一次查询出的所有角色中查找, 菜单一次性查询, 接口权限数量从casbin内存策略中统计, 查询次数与角色数量无关
+func (r RoleRepository) FillRoleSummaries(roles []*model.Role) error {
+	var rolesById map[uint]*model.Role
+	if config.Conf.Security.RoleInheritance && len(roles) > 0 {
+		var allRoles []*model.Role
+		if err := common.DB.Find(&allRoles).Error; err != nil {
+			return err
+		}
+		rolesById = make(map[uint]*model.Role, len(allRoles))
+		for _, role := range allRoles {
+			rolesById[role.ID] = role
+		}
+	}
+	effectiveRolesList := make([][]*model.Role, 0, len(roles))
+	roleIds := make([]uint, 0, len(roles))
+	for _, role := range roles {
+		effectiveRoles := []*model.Role{role}
+		if rolesById != nil {
+			effectiveRoles = inheritedRoles(role, rolesById)
+		}
+		effectiveRolesList = append(effectiveRolesList, effectiveRoles)
+		for _, effectiveRole := range effectiveRoles {
+			roleIds = append(roleIds, effectiveRole.ID)
+		}
+	}
+	if len(roleIds) == 0 {
+		return nil
+	}
+
+	var menuRoles []model.Role
+	err := common.DB.Where("id IN (?)", roleIds).Preload("Menus").Find(&menuRoles).Error
+	if err != nil {
+		return err
+	}
+	roleMenus := make(map[uint][]*model.Menu, len(menuRoles))
+	for _, role := range menuRoles {
+		roleMenus[role.ID] = role.Menus
+	}
+	// 角色关键字 -> 接口权限(路径和请求方式)
+	rolePolicies := make(map[string][]string)
+	for _, policy := range common.CasbinEnforcer.GetPolicy() {
+		if len(policy) > 0 {
+			rolePolicies[policy[0]] = append(rolePolicies[policy[0]], strings.Join(policy[1:], " "))
+		}
+	}
+
+	for i, role := range roles {
+		menus := make([]*model.Menu, 0)
+		menuIds := make(map[uint]bool)
+		policies := make(map[string]bool)
+		for _, effectiveRole := range effectiveRolesList[i] {
+			for _, menu := range roleMenus[effectiveRole.ID] {
+				if !menuIds[menu.ID] {
+					menuIds[menu.ID] = true
+					menus = append(menus, menu)
+				}
+			}
+			for _, policy := range rolePolicies[effectiveRole.Keyword] {
+				policies[policy] = true
+			}
+		}
+		count := len(policies)
+		role.Menus = menus
+		role.PolicyCount = &count
+	}
+	return nil
+}
+
 //根据角色ID获取角色
 func (r RoleRepository) GetRolesByIds(roleIds []uint) ([]*model.Role, error) {
 	var list []*model.Role
@@ -72,13 +166,25 @@ func (r RoleRepository) GetRolesByIds(roleIds []uint) ([]*model.Role, error) {
 // 创建角色
 func (r RoleRepository) CreateRole(role *model.Role) error {
 	err := common.DB.Create(role).Error
-	return err
+	return translateRoleUniqueError(err)
 }
 
 // 更新角色
 func (r RoleRepository) UpdateRoleById(roleId uint, role *model.Role) error {
-	err := common.DB.Model(&model.Role{}).Where("id = ?", roleId).Updates(role).Error
-	return err
+	err := common.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&model.Role{}).Where("id = ?", roleId).Updates(role).Error
+		if err != nil {
+			return err
+		}
+		// Updates不会更新空值, 父角色需要单独更新(为空表示取消继承)
+		return tx.Model(&model.Role{}).Where("id = ?", roleId).Update("parent_role_id", role.ParentRoleId).Error
+	})
+	return translateRoleUniqueError(err)
+}
+
+// 将角色名称、关键字唯一索引冲突的数据库错误转换为友好的提示
+func translateRoleUniqueError(err error) error {
+	return translateDuplicateKeyError(err, map[string]string{"name": "角色名称已存在", "keyword": "角色关键字已存在"})
 }
 
 // 获取角色的权限菜单
@@ -123,21 +229,49 @@ func (r RoleRepository) GetRoleApisByRoleKeyword(roleKeyword string) ([]*model.A
 }
 
 // 更新角色的权限接口（先全部删除再新增）
-func (r RoleRepository) UpdateRoleApis(roleKeyword string, reqRolePolicies [][]string) error {
-	// 先获取path中的角色ID对应角色已有的police(需要先删除的)
-	err := common.CasbinEnforcer.LoadPolicy()
-	if err != nil {
-		return errors.New("角色的权限接口策略加载失败")
-	}
-	rmPolicies := common.CasbinEnforcer.GetFilteredPolicy(0, roleKeyword)
-	if len(rmPolicies) > 0 {
-		isRemoved, _ := common.CasbinEnforcer.RemovePolicies(rmPolicies)
-		if !isRemoved {
-			return errors.New("更新角色的权限接口失败")
+// 在一个事务中直接更新casbin规则表并写入变更记录, 保证变更记录与实际权限一致, 提交后重新加载策略
+func (r RoleRepository) UpdateRoleApis(role *model.Role, reqRolePolicies [][]string, operator string) error {
+	err := common.DB.Transaction(func(tx *gorm.DB) error {
+		// 在事务中加锁读取角色已有的接口权限(需要先删除的), 变更记录与实际替换的权限一致
+		// 不使用casbin内存中的策略, 其他请求或其他节点可能已经修改了数据库中的策略
+		var currentRules []gormadapter.CasbinRule
+		err := tx.Table(casbinRuleTableName).Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("ptype = ? AND v0 = ?", "p", role.Keyword).Find(&currentRules).Error
+		if err != nil {
+			return err
 		}
-	}
-	isAdded, _ := common.CasbinEnforcer.AddPolicies(reqRolePolicies)
-	if !isAdded {
+		rmPolicies := make([][]string, 0, len(currentRules))
+		for _, rule := range currentRules {
+			rmPolicies = append(rmPolicies, []string{rule.V0, rule.V1, rule.V2})
+		}
+		added := policiesDifference(reqRolePolicies, rmPolicies)
+		removed := policiesDifference(rmPolicies, reqRolePolicies)
+
+		if err := tx.Table(casbinRuleTableName).Where("ptype = ? AND v0 = ?", "p", role.Keyword).Delete(&gormadapter.CasbinRule{}).Error; err != nil {
+			return err
+		}
+		rules := make([]gormadapter.CasbinRule, 0, len(reqRolePolicies))
+		for _, policy := range reqRolePolicies {
+			rules = append(rules, gormadapter.CasbinRule{Ptype: "p", V0: policy[0], V1: policy[1], V2: policy[2]})
+		}
+		if len(rules) > 0 {
+			if err := tx.Table(casbinRuleTableName).Create(&rules).Error; err != nil {
+				return err
+			}
+		}
+		// 权限没有变化时不记录
+		if !config.Conf.Casbin.PolicyAudit || (len(added) == 0 && len(removed) == 0) {
+			return nil
+		}
+		return tx.Create(&model.PolicyChangeLog{
+			RoleId:      role.ID,
+			RoleKeyword: role.Keyword,
+			Operator:    operator,
+			Added:       util.Struct2Json(added),
+			Removed:     util.Struct2Json(removed),
+		}).Error
+	})
+	if err != nil {
 		return errors.New("更新角色的权限接口失败")
 	}
 	err = common.CasbinEnforcer.LoadPolicy()
@@ -148,6 +282,50 @@ func (r RoleRepository) UpdateRoleApis(roleKeyword string, reqRolePolicies [][]s
 	}
 }
 
+// casbin规则表名(与gorm-adapter默认表名一致)
+const casbinRuleTableName = "casbin_rule"
+
+// 在a中但不在b中的接口权限, 只返回[path, method]
+func policiesDifference(a [][]string, b [][]string) [][]string {
+	exists := make(map[string]bool, len(b))
+	for _, policy := range b {
+		exists[policy[1]+" "+policy[2]] = true
+	}
+	diff := make([][]string, 0)
+	for _, policy := range a {
+		if !exists[policy[1]+" "+policy[2]] {
+			diff = append(diff, []string{policy[1], policy[2]})
+		}
+	}
+	return diff
+}
+
+// 获取指定用户操作的全部角色权限接口变更记录
+func (r RoleRepository) GetPolicyChangeLogsByOperator(operator string) ([]*model.PolicyChangeLog, error) {
+	var list []*model.PolicyChangeLog
+	err := common.DB.Where("operator = ?", operator).Order("created_at DESC").Find(&list).Error
+	return list, err
+}
+
+// 获取角色权限接口变更记录
+func (r RoleRepository) GetPolicyChangeLogs(roleId uint, pageNum int, pageSize int) ([]*model.PolicyChangeLog, int64, error) {
+	var list []*model.PolicyChangeLog
+	db := common.DB.Model(&model.PolicyChangeLog{}).Where("role_id = ?", roleId).Order("created_at DESC")
+
+	// 分页
+	var total int64
+	err := db.Count(&total).Error
+	if err != nil {
+		return list, total, err
+	}
+	if pageNum > 0 && pageSize > 0 {
+		err = db.Offset((pageNum - 1) * pageSize).Limit(pageSize).Find(&list).Error
+	} else {
+		err = db.Find(&list).Error
+	}
+	return list, total, err
+}
+
 // 删除角色
 func (r RoleRepository) BatchDeleteRoleByIds(roleIds []uint) error {
 	var roles []*model.Role
@@ -158,6 +336,12 @@ func (r RoleRepository) BatchDeleteRoleByIds(roleIds []uint) error {
 	err = common.DB.Select("Users", "Menus").Unscoped().Delete(&roles).Error
 	// 删除成功就删除casbin policy
 	if err == nil {
+		// 继承被删除角色的角色取消继承
+		err = common.DB.Model(&model.Role{}).Where("parent_role_id IN (?)", roleIds).Update("parent_role_id", nil).Error
+		if err != nil {
+			return err
+		}
+
 		for _, role := range roles {
 			roleKeyword := role.Keyword
 			rmPolicies := common.CasbinEnforcer.GetFilteredPolicy(0, roleKeyword)
@@ -172,3 +356,175 @@ func (r RoleRepository) BatchDeleteRoleByIds(roleIds []uint) error {
 	}
 	return err
 }
+
+// 获取角色及其继承的所有角色(未开启角色继承时原样返回)
+// 沿父角色链向上查找, 禁用的父角色及其之上的角色不继承, 已访问过的角色不再处理以防止继承成环
+func (r RoleRepository) GetEffectiveRoles(roles []*model.Role) ([]*model.Role, error) {
+	if !config.Conf.Security.RoleInheritance {
+		return roles, nil
+	}
+	visited := make(map[uint]bool, len(roles))
+	effectiveRoles := make([]*model.Role, 0, len(roles))
+	for _, role := range roles {
+		if !visited[role.ID] {
+			visited[role.ID] = true
+			effectiveRoles = append(effectiveRoles, role)
+		}
+	}
+	for _, role := range roles {
+		parentRoleId := role.ParentRoleId
+		for parentRoleId != nil && !visited[*parentRoleId] {
+			visited[*parentRoleId] = true
+			var parentRole model.Role
+			err := common.DB.Where("id = ?", *parentRoleId).Limit(1).Find(&parentRole).Error
+			if err != nil {
+				return nil, err
+			}
+			if parentRole.ID == 0 || parentRole.Status != 1 {
+				break
+			}
+			effectiveRoles = append(effectiveRoles, &parentRole)
+			parentRoleId = parentRole.ParentRoleId
+		}
+	}
+	return effectiveRoles, nil
+}
+
+// 从所有角色中查找角色及其继承的角色, 规则与GetEffectiveRoles相同
+func inheritedRoles(role *model.Role, rolesById map[uint]*model.Role) []*model.Role {
+	visited := map[uint]bool{role.ID: true}
+	effectiveRoles := []*model.Role{role}
+	parentRoleId := role.ParentRoleId
+	for parentRoleId != nil && !visited[*parentRoleId] {
+		visited[*parentRoleId] = true
+		parentRole, ok := rolesById[*parentRoleId]
+		if !ok || parentRole.Status != 1 {
+			break
+		}
+		effectiveRoles = append(effectiveRoles, parentRole)
+		parentRoleId = parentRole.ParentRoleId
+	}
+	return effectiveRoles
+}
+
+// 获取未被禁用且未过期的角色, 开启角色继承时加上继承的角色
+func activeEffectiveRoles(roles []*model.Role) ([]*model.Role, error) {
+	activeRoles := make([]*model.Role, 0, len(roles))
+	for _, role := range roles {
+		if role.Status == 1 && !role.IsExpired() {
+			activeRoles = append(activeRoles, role)
+		}
+	}
+	return RoleRepository{}.GetEffectiveRoles(activeRoles)
+}
+
+// 获取角色的继承链(从该角色开始沿父角色向上), 遇到环时停止
+func (r RoleRepository) GetRoleInheritChainIds(roleId uint) ([]uint, error) {
+	chainIds := make([]uint, 0)
+	visited := make(map[uint]bool)
+	currentId := &roleId
+	for currentId != nil && !visited[*currentId] {
+		visited[*currentId] = true
+		var role model.Role
+		err := common.DB.Where("id = ?", *currentId).Limit(1).Find(&role).Error
+		if err != nil {
+			return nil, err
+		}
+		if role.ID == 0 {
+			break
+		}
+		chainIds = append(chainIds, role.ID)
+		currentId = role.ParentRoleId
+	}
+	return chainIds, nil
+}
+
+// 获取指定排序的角色(排除指定ID的角色)
+func (r RoleRepository) GetRolesBySort(sort uint, excludeId uint) ([]*model.Role, error) {
+	var roles []*model.Role
+	err := common.DB.Where("sort = ? AND id <> ?", sort, excludeId).Find(&roles).Error
+	return roles, err
+}
+
+// 获取大于等于指定排序的第一个未使用的排序, 没有可用排序时返回0
+func (r RoleRepository) GetNextFreeRoleSort(sort uint) (uint, error) {
+	var usedSorts []uint
+	err := common.DB.Model(&model.Role{}).Where("sort >= ?", sort).Pluck("sort", &usedSorts).Error
+	if err != nil {
+		return 0, err
+	}
+	used := make(map[uint]bool, len(usedSorts))
+	for _, s := range usedSorts {
+		used[s] = true
+	}
+	for s := sort; s <= 999; s++ {
+		if !used[s] {
+			return s, nil
+		}
+	}
+	return 0, nil
+}
+
+// 批量修改角色状态, 返回修改后的角色和删除缓存的用户数量
+// 在事务中修改, 成功后删除拥有这些角色的用户的用户信息缓存, 使登录和权限校验立即生效
+// 修改后的角色在同一事务中重新查询, 方便前端只更新这些角色
+func (r RoleRepository) BatchSetRoleStatus(roleIds []uint, status uint) ([]*model.Role, int, error) {
+	var userIds []uint
+	var roles []*model.Role
+	err := common.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&model.Role{}).Where("id IN (?)", roleIds).Update("status", status).Error
+		if err != nil {
+			return err
+		}
+		if err := tx.Where("id IN (?)", roleIds).Find(&roles).Error; err != nil {
+			return err
+		}
+		return tx.Table("user_roles").
+			Joins("JOIN users ON users.id = user_roles.user_id AND users.deleted_at IS NULL").
+			Where("user_roles.role_id IN (?)", roleIds).
+			Distinct().
+			Pluck("users.id", &userIds).Error
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	invalidateUserInfoCache(userIds...)
+	return roles, len(userIds), nil
+}
+
+// 预览禁用角色的影响(不修改数据), 返回禁用该角色后将无法登录的用户
+// 使用与登录相同的校验, 当前已无法登录的用户不计入
+func (r RoleRepository) PreviewDisableRole(roleId uint) ([]*model.User, error) {
+	var users []*model.User
+	err := common.DB.
+		Where("id IN (?)", common.DB.Table("user_roles").Select("user_id").Where("role_id = ?", roleId)).
+		Preload("Roles").
+		Find(&users).Error
+	if err != nil {
+		return nil, err
+	}
+	if err := fillUserRolesExpiresAt(users...); err != nil {
+		return nil, err
+	}
+
+	affectedUsers := make([]*model.User, 0)
+	for _, user := range users {
+		if checkUserLoginStatus(user) != nil {
+			continue
+		}
+		// 复制用户的角色并将该角色设为禁用, 避免修改原数据
+		disabledUser := *user
+		disabledUser.Roles = make([]*model.Role, 0, len(user.Roles))
+		for _, role := range user.Roles {
+			roleCopy := *role
+			if roleCopy.ID == roleId {
+				roleCopy.Status = 2
+			}
+			disabledUser.Roles = append(disabledUser.Roles, &roleCopy)
+		}
+		if checkUserLoginStatus(&disabledUser) != nil {
+			affectedUsers = append(affectedUsers, user)
+		}
+	}
+	return affectedUsers, nil
+}
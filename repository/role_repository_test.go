@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	gormadapter "github.com/casbin/gorm-adapter/v3"
+	"go-web-mini/common"
+	"go-web-mini/config"
+	"go-web-mini/model"
+	"gorm.io/gorm"
+	"testing"
+)
+
+func TestCreateRoleDuplicate(t *testing.T) {
+	setupTestDB(t)
+	rr := RoleRepository{}
+	createTestRole(t, "user", 3)
+
+	err := rr.CreateRole(&model.Role{Name: "user", Keyword: "member", Status: 1, Sort: 4})
+	if !errors.Is(err, ErrDuplicateKey) {
+		t.Fatalf("创建重复的角色返回%v, 期望为唯一索引冲突", err)
+	}
+	if err.Error() != "角色名称已存在" {
+		t.Errorf("错误信息为%s, 期望为角色名称已存在", err.Error())
+	}
+}
+
+func TestFillRoleSummariesIncludesInheritedRoles(t *testing.T) {
+	setupTestDB(t)
+	config.Conf.Casbin.ModelPath = "../rbac_model.conf"
+	config.Conf.Security.RoleInheritance = true
+	common.InitCasbinEnforcer()
+	rr := RoleRepository{}
+	parent := createTestRole(t, "manager", 2)
+	child := createTestRole(t, "user", 3)
+	common.DB.Model(child).Update("parent_role_id", parent.ID)
+	common.DB.First(child, child.ID)
+	parentMenu := &model.Menu{Name: "user", Title: "用户管理", Path: "/user"}
+	childMenu := &model.Menu{Name: "profile", Title: "个人中心", Path: "/profile"}
+	common.DB.Create(parentMenu)
+	common.DB.Create(childMenu)
+	common.DB.Model(parent).Association("Menus").Append([]*model.Menu{parentMenu, childMenu})
+	common.DB.Model(child).Association("Menus").Append([]*model.Menu{childMenu})
+	common.CasbinEnforcer.AddPolicies([][]string{
+		{"manager", "/api/user/list", "GET"},
+		{"manager", "/api/user/info", "POST"},
+		{"user", "/api/user/info", "POST"},
+	})
+
+	roles := []*model.Role{child}
+	if err := rr.FillRoleSummaries(roles); err != nil {
+		t.Fatalf("填充角色的权限概要失败: %v", err)
+	}
+	if len(child.Menus) != 2 || child.PolicyCount == nil || *child.PolicyCount != 2 {
+		t.Errorf("权限概要为%d个菜单、%v个接口, 期望包括继承的2个菜单和2个接口", len(child.Menus), child.PolicyCount)
+	}
+}
+
+// 统计填充角色的权限概要执行的查询次数
+func countFillRoleSummariesQueries(t *testing.T, roles []*model.Role) int {
+	count := 0
+	name := fmt.Sprintf("test:count_queries_%s", t.Name())
+	if err := common.DB.Callback().Query().After("gorm:query").Register(name, func(*gorm.DB) { count++ }); err != nil {
+		t.Fatalf("注册查询回调失败: %v", err)
+	}
+	defer common.DB.Callback().Query().Remove(name)
+
+	if err := (RoleRepository{}).FillRoleSummaries(roles); err != nil {
+		t.Fatalf("填充角色的权限概要失败: %v", err)
+	}
+	return count
+}
+
+func TestFillRoleSummariesQueryCountIndependentOfRoles(t *testing.T) {
+	setupTestDB(t)
+	config.Conf.Casbin.ModelPath = "../rbac_model.conf"
+	config.Conf.Security.RoleInheritance = true
+	common.InitCasbinEnforcer()
+	// 角色依次继承上一个角色
+	roles := make([]*model.Role, 0, 5)
+	for i := 0; i < 5; i++ {
+		role := createTestRole(t, fmt.Sprintf("role%d", i), uint(i+1))
+		if i > 0 {
+			common.DB.Model(role).Update("parent_role_id", roles[i-1].ID)
+			common.DB.First(role, role.ID)
+		}
+		roles = append(roles, role)
+	}
+
+	single := countFillRoleSummariesQueries(t, roles[:1])
+	many := countFillRoleSummariesQueries(t, roles)
+	if many != single {
+		t.Errorf("填充5个角色的权限概要执行了%d次查询, 1个角色时为%d次, 查询次数不应随角色数量和继承层级增加", many, single)
+	}
+}
+
+func TestUpdateRoleApisLogsDatabaseChanges(t *testing.T) {
+	setupTestDB(t)
+	config.Conf.Casbin.ModelPath = "../rbac_model.conf"
+	config.Conf.Casbin.PolicyAudit = true
+	common.InitCasbinEnforcer()
+	rr := RoleRepository{}
+	role := createTestRole(t, "user", 3)
+	common.CasbinEnforcer.AddPolicy("user", "/api/user/info", "POST")
+	// 其他节点新增的权限, 本节点内存中的策略还没有加载
+	common.DB.Table(casbinRuleTableName).Create(&gormadapter.CasbinRule{Ptype: "p", V0: "user", V1: "/api/user/list", V2: "GET"})
+
+	err := rr.UpdateRoleApis(role, [][]string{{"user", "/api/user/info", "POST"}}, "admin")
+	if err != nil {
+		t.Fatalf("更新角色的权限接口失败: %v", err)
+	}
+	var logs []model.PolicyChangeLog
+	common.DB.Where("role_id = ?", role.ID).Find(&logs)
+	if len(logs) != 1 || logs[0].Added != "[]" || logs[0].Removed != `[["/api/user/list","GET"]]` {
+		t.Errorf("权限变更记录为%+v, 期望记录删除了数据库中的/api/user/list", logs)
+	}
+}
@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"github.com/patrickmn/go-cache"
+	"go-web-mini/model"
+	"time"
+)
+
+// 当前用户信息缓存，避免频繁获取数据库
+// 只用于获取当前登录用户(GetCurrentUser), 登录、修改密码等需要最新密码和状态的场景直接查询数据库
+// key为用户ID(不会变化), 用户名修改后不会留下旧用户名的缓存, 新用户使用旧用户名也不会读到旧数据
+var userInfoCache = cache.New(24*time.Hour, 48*time.Hour)
+
+// 用户信息缓存的key, 所有读写都通过下面的方法, 保证key一致
+func userInfoCacheKey(userId uint) string {
+	return fmt.Sprintf("user:%d", userId)
+}
+
+// 获取用户信息缓存
+func getUserInfoCache(userId uint) (model.User, bool) {
+	cacheUser, found := userInfoCache.Get(userInfoCacheKey(userId))
+	if !found {
+		return model.User{}, false
+	}
+	user, ok := cacheUser.(model.User)
+	return user, ok
+}
+
+// 设置用户信息缓存
+func setUserInfoCache(user model.User) {
+	userInfoCache.Set(userInfoCacheKey(user.ID), user, cache.DefaultExpiration)
+}
+
+// 删除本节点的用户信息缓存(包括用户权限缓存)
+func deleteUserInfoCache(userIds ...uint) {
+	for _, userId := range userIds {
+		userInfoCache.Delete(userInfoCacheKey(userId))
+	}
+	deleteUserPermissionCache(userIds...)
+}
+
+// 删除本节点指定用户的用户信息缓存(如退出登录), 用户数据未修改不需要通知其他节点
+func (ur UserRepository) DeleteUserInfoCache(id uint) {
+	deleteUserInfoCache(id)
+}
+
+// 用户数据修改后删除用户信息缓存, 并通知其他节点删除
+func invalidateUserInfoCache(userIds ...uint) {
+	deleteUserInfoCache(userIds...)
+	publishUserCacheInvalidation(userIds...)
+}
+
+// 检查用户信息缓存是否可用, 写入并读取一个检查用的key后删除
+func (ur UserRepository) PingUserInfoCache() error {
+	key := "health:ping"
+	value := time.Now().UnixNano()
+	userInfoCache.Set(key, value, time.Minute)
+	defer userInfoCache.Delete(key)
+	cached, found := userInfoCache.Get(key)
+	if !found || cached.(int64) != value {
+		return errors.New("用户信息缓存读写失败")
+	}
+	return nil
+}
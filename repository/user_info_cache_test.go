@@ -0,0 +1,224 @@
+package repository
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"go-web-mini/common"
+	"go-web-mini/config"
+	"go-web-mini/model"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestChangePwdWithCacheBackendFailure(t *testing.T) {
+	setupTestDB(t)
+	ur := UserRepository{}
+	alice := createTestLoginUser(t, "alice", "passwd", createTestRole(t, "user", 3))
+	setUserInfoCache(loadTestUser(t, alice.ID))
+
+	// 开启多节点缓存失效同步, 删除失效记录表模拟共享的缓存失效通知不可用
+	config.Conf.Cache.InvalidationPollInterval = 1
+	if err := common.DB.Migrator().DropTable(&model.CacheInvalidation{}); err != nil {
+		t.Fatalf("删除缓存失效记录表失败: %v", err)
+	}
+
+	if err := ur.ChangePwd("alice", "new-hash"); err != nil {
+		t.Fatalf("通知其他节点失败时修改密码返回%v, 期望成功", err)
+	}
+	if got := loadTestUser(t, alice.ID); got.Password != "new-hash" {
+		t.Errorf("数据库中的密码为%s, 期望为new-hash", got.Password)
+	}
+	// 本节点的缓存已删除, 不会留下旧密码
+	if user, found := getUserInfoCache(alice.ID); found {
+		t.Errorf("修改密码后缓存中仍有用户信息, 密码为%s", user.Password)
+	}
+}
+
+func TestChangePwdNotifiesOtherNodes(t *testing.T) {
+	setupTestDB(t)
+	ur := UserRepository{}
+	alice := createTestLoginUser(t, "alice", "passwd", createTestRole(t, "user", 3))
+	config.Conf.Cache.InvalidationPollInterval = 1
+
+	if err := ur.ChangePwd("alice", "new-hash"); err != nil {
+		t.Fatalf("修改密码失败: %v", err)
+	}
+	var count int64
+	common.DB.Model(&model.CacheInvalidation{}).Where("user_id = ?", alice.ID).Count(&count)
+	if count != 1 {
+		t.Errorf("缓存失效记录数量为%d, 期望为1", count)
+	}
+}
+
+func TestUpdateUserUsernameLeavesNoStaleCache(t *testing.T) {
+	setupTestDB(t)
+	ur := UserRepository{}
+	role := createTestRole(t, "user", 3)
+	alice := createTestLoginUser(t, "alice", "passwd", role)
+	bob := createTestLoginUser(t, "bob", "passwd", role)
+	common.DB.Model(bob).UpdateColumn("creator", "alice")
+	common.DB.Create(&model.OperationLog{Username: "alice", Path: "/api/user/list"})
+	setUserInfoCache(loadTestUser(t, alice.ID))
+
+	user := loadTestUser(t, alice.ID)
+	user.Username = "alicia"
+	user.Roles = []*model.Role{role}
+	if err := ur.UpdateUser(&user); err != nil {
+		t.Fatalf("修改用户名失败: %v", err)
+	}
+
+	if cached, found := getUserInfoCache(alice.ID); found {
+		t.Errorf("修改用户名后缓存中仍有用户信息, 用户名为%s", cached.Username)
+	}
+	if got, err := ur.GetUserByUsername("alice"); err == nil {
+		t.Errorf("旧用户名仍然可以获取到用户%d", got.ID)
+	}
+	if got, err := ur.GetUserByUsername("alicia"); err != nil || got.ID != alice.ID {
+		t.Errorf("使用新用户名获取用户返回%v, %v", got.ID, err)
+	}
+	// 以用户名关联的数据同时更新
+	if got := loadTestUser(t, bob.ID); got.Creator != "alicia" {
+		t.Errorf("创建人为%s, 期望为alicia", got.Creator)
+	}
+	var logCount int64
+	common.DB.Model(&model.OperationLog{}).Where("username = ?", "alicia").Count(&logCount)
+	if logCount != 1 {
+		t.Errorf("新用户名的操作日志数量为%d, 期望为1", logCount)
+	}
+}
+
+// 创建当前用户为user的请求上下文
+func newTestUserContext(user model.User) *gin.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Set("user", model.User{Model: user.Model, Username: user.Username})
+	return c
+}
+
+func TestUpdateUserLeavesNoStaleCache(t *testing.T) {
+	setupTestDB(t)
+	ur := UserRepository{}
+	admin := createTestRole(t, "admin", 1)
+	guest := createTestRole(t, "guest", 5)
+	bob := createTestLoginUser(t, "bob", "passwd", admin)
+
+	// bob登录后缓存了用户信息
+	if cached, err := ur.GetCurrentUser(newTestUserContext(*bob)); err != nil || cached.Status != 1 {
+		t.Fatalf("获取当前用户返回%v, %v", cached.Status, err)
+	}
+
+	// 管理员禁用bob并降级角色
+	user := loadTestUser(t, bob.ID)
+	user.Status = 2
+	user.Roles = []*model.Role{guest}
+	if err := ur.UpdateUser(&user); err != nil {
+		t.Fatalf("更新用户失败: %v", err)
+	}
+
+	current, err := ur.GetCurrentUser(newTestUserContext(*bob))
+	if err != nil {
+		t.Fatalf("获取当前用户失败: %v", err)
+	}
+	if current.Status != 2 {
+		t.Errorf("更新后当前用户状态为%d, 期望为2", current.Status)
+	}
+	if len(current.Roles) != 1 || current.Roles[0].ID != guest.ID {
+		t.Errorf("更新后当前用户的角色为%v, 期望只有guest", current.Roles)
+	}
+}
+
+func TestNewUserWithOldUsernameGetsOwnInfo(t *testing.T) {
+	setupTestDB(t)
+	ur := UserRepository{}
+	admin := createTestRole(t, "admin", 1)
+	guest := createTestRole(t, "guest", 5)
+	alice := createTestLoginUser(t, "alice", "passwd", admin)
+	if _, err := ur.GetCurrentUser(newTestUserContext(*alice)); err != nil {
+		t.Fatalf("获取当前用户失败: %v", err)
+	}
+
+	// alice改名为alicia后, 新用户使用alice这个用户名
+	user := loadTestUser(t, alice.ID)
+	user.Username = "alicia"
+	user.Roles = []*model.Role{admin}
+	if err := ur.UpdateUser(&user); err != nil {
+		t.Fatalf("修改用户名失败: %v", err)
+	}
+	newAlice := createTestLoginUser(t, "alice", "passwd", guest)
+
+	current, err := ur.GetCurrentUser(newTestUserContext(*newAlice))
+	if err != nil {
+		t.Fatalf("获取当前用户失败: %v", err)
+	}
+	if current.ID != newAlice.ID || len(current.Roles) != 1 || current.Roles[0].ID != guest.ID {
+		t.Errorf("新用户获取到的当前用户为%d, 角色为%v, 期望为新用户自己的信息", current.ID, current.Roles)
+	}
+	// 改名后的用户仍然获取自己的信息
+	renamed, err := ur.GetCurrentUser(newTestUserContext(*alice))
+	if err != nil {
+		t.Fatalf("获取当前用户失败: %v", err)
+	}
+	if renamed.ID != alice.ID || renamed.Username != "alicia" {
+		t.Errorf("改名后的用户获取到的当前用户为%d(%s), 期望为%d(alicia)", renamed.ID, renamed.Username, alice.ID)
+	}
+	if got, err := ur.GetUserByUsername("alice"); err != nil || got.ID != newAlice.ID {
+		t.Errorf("使用用户名alice获取用户返回%v, %v, 期望为新用户", got.ID, err)
+	}
+}
+
+func TestPollUserCacheInvalidationsReadsLateCommits(t *testing.T) {
+	setupTestDB(t)
+	ur := UserRepository{}
+	role := createTestRole(t, "user", 3)
+	alice := createTestLoginUser(t, "alice", "passwd", role)
+	bob := createTestLoginUser(t, "bob", "passwd", role)
+	resetPoll := func() { lastCacheInvalidationPoll, processedCacheInvalidations = time.Time{}, make(map[uint]time.Time) }
+	resetPoll()
+	t.Cleanup(resetPoll)
+
+	// 其他节点写入的记录ID为5
+	common.DB.Create(&model.CacheInvalidation{ID: 5, UserId: alice.ID, Node: "other"})
+	setUserInfoCache(loadTestUser(t, alice.ID))
+	if count, err := ur.PollUserCacheInvalidations(); err != nil || count != 1 {
+		t.Fatalf("首次轮询返回%d, %v, 期望处理1条记录", count, err)
+	}
+	if _, found := getUserInfoCache(alice.ID); found {
+		t.Error("轮询后alice的缓存未删除")
+	}
+
+	// ID较小的记录在上次轮询之后才提交
+	setUserInfoCache(loadTestUser(t, alice.ID))
+	setUserInfoCache(loadTestUser(t, bob.ID))
+	common.DB.Create(&model.CacheInvalidation{ID: 3, UserId: bob.ID, Node: "other", CreatedAt: time.Now().Add(-10 * time.Second)})
+	if count, err := ur.PollUserCacheInvalidations(); err != nil || count != 1 {
+		t.Fatalf("再次轮询返回%d, %v, 期望只处理较晚提交的1条记录", count, err)
+	}
+	if _, found := getUserInfoCache(bob.ID); found {
+		t.Error("较晚提交的记录未处理, bob的缓存未删除")
+	}
+	// 已处理的记录不重复处理
+	if _, found := getUserInfoCache(alice.ID); !found {
+		t.Error("已处理的记录被重复处理, alice的缓存被删除")
+	}
+}
+
+func TestWarmUserInfoCacheConcurrently(t *testing.T) {
+	setupTestDB(t)
+	ur := UserRepository{}
+	config.Conf.Cache.WarmConcurrency = 3
+	ids := make([]uint, 0, 120)
+	for i := 0; i < 120; i++ {
+		user := createTestUser(t, fmt.Sprintf("user%d", i), fmt.Sprintf("139%08d", i))
+		ids = append(ids, user.ID)
+	}
+
+	count, err := ur.WarmUserInfoCache(nil)
+	if err != nil || count != len(ids) {
+		t.Fatalf("预热缓存返回%d, %v, 期望为%d", count, err, len(ids))
+	}
+	for _, id := range ids {
+		if _, found := getUserInfoCache(id); !found {
+			t.Errorf("用户%d的信息未预热到缓存", id)
+		}
+	}
+}
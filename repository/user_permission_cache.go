@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"fmt"
+	"github.com/patrickmn/go-cache"
+	"go-web-mini/common"
+	"go-web-mini/dto"
+	"go-web-mini/model"
+	"sort"
+	"strings"
+)
+
+// 用户权限缓存与用户信息缓存使用同一个缓存, 删除用户信息缓存时同时删除
+// 用户的角色变化时通过用户信息缓存失效处理, 角色的菜单、接口或菜单变化时清理所有用户权限缓存
+const userPermissionCacheKeyPrefix = "permission:"
+
+func userPermissionCacheKey(userId uint) string {
+	return fmt.Sprintf("%s%d", userPermissionCacheKeyPrefix, userId)
+}
+
+// 删除本节点指定用户的权限缓存
+func deleteUserPermissionCache(userIds ...uint) {
+	for _, userId := range userIds {
+		userInfoCache.Delete(userPermissionCacheKey(userId))
+	}
+}
+
+// 清理所有用户权限缓存(角色的菜单、接口或菜单修改后), 并通知其他节点清理
+// 其他节点没有单独清理权限缓存的记录, 按清理所有用户信息缓存处理
+func (ur UserRepository) ClearUserPermissionCache() {
+	for key := range userInfoCache.Items() {
+		if strings.HasPrefix(key, userPermissionCacheKeyPrefix) {
+			userInfoCache.Delete(key)
+		}
+	}
+	publishUserCacheInvalidation(cacheInvalidationFlushAll)
+}
+
+// 获取用户的权限(角色、可访问的接口和菜单树), 需要缓存
+// 只包含未被禁用且未过期的角色(开启角色继承时加上继承的角色)的权限
+func (ur UserRepository) GetUserPermissions(user model.User) (*dto.UserPermissionsDto, error) {
+	if cached, found := userInfoCache.Get(userPermissionCacheKey(user.ID)); found {
+		if permissions, ok := cached.(*dto.UserPermissionsDto); ok {
+			return permissions, nil
+		}
+	}
+
+	roles, err := activeEffectiveRoles(user.Roles)
+	if err != nil {
+		return nil, err
+	}
+	permissions := &dto.UserPermissionsDto{
+		Roles: make([]string, 0, len(roles)),
+		Apis:  make([]*dto.UserApiPermissionDto, 0),
+	}
+	apiSet := make(map[string]bool)
+	for _, role := range roles {
+		permissions.Roles = append(permissions.Roles, role.Keyword)
+		// casbin策略格式: [角色关键字, 路径, 请求方式]
+		for _, policy := range common.CasbinEnforcer.GetFilteredPolicy(0, role.Keyword) {
+			if len(policy) < 3 || apiSet[policy[2]+" "+policy[1]] {
+				continue
+			}
+			apiSet[policy[2]+" "+policy[1]] = true
+			permissions.Apis = append(permissions.Apis, &dto.UserApiPermissionDto{Method: policy[2], Path: policy[1]})
+		}
+	}
+	sort.Slice(permissions.Apis, func(i, j int) bool {
+		if permissions.Apis[i].Path != permissions.Apis[j].Path {
+			return permissions.Apis[i].Path < permissions.Apis[j].Path
+		}
+		return permissions.Apis[i].Method < permissions.Apis[j].Method
+	})
+	permissions.MenuTree, err = MenuRepository{}.GetUserMenuTreeByUserId(user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	userInfoCache.Set(userPermissionCacheKey(user.ID), permissions, cache.DefaultExpiration)
+	return permissions, nil
+}
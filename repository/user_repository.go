@@ -1,43 +1,95 @@
 package repository
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
-	"github.com/patrickmn/go-cache"
 	"github.com/thoas/go-funk"
 	"go-web-mini/common"
+	"go-web-mini/config"
+	"go-web-mini/dto"
 	"go-web-mini/model"
 	"go-web-mini/util"
 	"go-web-mini/vo"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 )
 
+// 未登录(上下文中没有当前用户)
+var ErrUserNotLoggedIn = errors.New("用户未登录")
+
 type IUserRepository interface {
-	Login(user *model.User) (*model.User, error)       // 登录
-	ChangePwd(username string, newPasswd string) error // 更新密码
+	Login(user *model.User) (*model.User, error)                   // 登录
+	GetRefreshTokenUser(userId uint) (*model.User, error)          // 获取刷新token的用户(与登录相同的校验)
+	ChangePwd(username string, newPasswd string) error             // 更新密码
+	IsRecentPassword(user model.User, passwd string) (bool, error) // 是否为最近使用过的密码
+	ResetPassword(id uint, hashPasswd string) error                // 重置用户密码(下次登录时需要修改密码)
+	UpdateStatus(id uint, status uint) error                       // 更新用户状态(启用/禁用)
+	BatchUpdateStatus(ids []uint, status uint) error               // 批量更新用户状态(一个事务)
+	UpdateTwoFactor(id uint, secret string, enabled bool) error    // 更新用户的两步验证密钥和开启状态
+	UpdateAvatar(id uint, avatar string) error                     // 更新用户头像
+	UpdateProfile(user *model.User) error                          // 更新个人资料(昵称、头像、手机号和简介)
+
+	CreateUser(user *model.User) error                                                                        // 创建用户
+	BatchCreateUsers(users []*model.User) error                                                               // 批量创建用户(一个事务)
+	GetUserById(id uint) (model.User, error)                                                                  // 获取单个用户(不过滤用户状态)
+	GetActiveUserById(id uint) (model.User, error)                                                            // 获取单个正常状态的用户
+	GetUserByUsername(username string) (model.User, error)                                                    // 根据用户名获取单个用户(查询数据库)
+	GetUsers(ctx context.Context, req *vo.UserListRequest) ([]*model.User, int64, error)                      // 获取用户列表
+	StreamUsers(ctx context.Context, req *vo.UserListRequest, fn func(user *model.User) error) error          // 流式获取用户列表(不分页)
+	CountUsers(ctx context.Context, req *vo.UserListRequest) (int64, error)                                   // 获取符合查询条件的用户数量
+	GetUsersByRoleId(ctx context.Context, roleId uint, req *vo.UserListRequest) ([]*model.User, int64, error) // 获取拥有指定角色的用户列表
+	UpdateUser(user *model.User) error                                                                        // 更新用户
+	BatchDeleteUserByIds(ids []uint) error                                                                    // 批量删除
+	GetDeletedUsers(req *vo.UserListRequest) ([]*model.User, int64, error)                                    // 获取已删除的用户列表(回收站)
+	RestoreUsers(ids []uint) error                                                                            // 批量恢复已删除的用户
+	UpsertUsers(users []model.User) error                                                                     // 按用户名批量新增或更新用户(外部同步)
+
+	GetCurrentUser(c *gin.Context) (model.User, error)                      // 获取当前登录用户信息
+	GetCurrentUserMinRoleSort(c *gin.Context) (uint, model.User, error)     // 获取当前用户角色排序最小值（最高等级角色）以及当前用户信息
+	GetUserMinRoleSortsByIds(ids []uint) ([]int, error)                     // 根据用户ID获取用户角色排序最小值
+	GetUserMinRoleSortMapByIds(ids []uint) (map[uint]uint, error)           // 根据用户ID获取每个用户的角色排序最小值(key为用户ID)
+	GetDeletedUserMinRoleSortMapByIds(ids []uint) (map[uint]uint, error)    // 根据用户ID获取每个已删除用户的角色排序最小值(key为用户ID)
+	GetUsersByUsernames(names []string) ([]model.User, error)               // 根据用户名批量获取用户
+	GetUserAvailableRoles(userId uint, minSort uint) ([]*model.Role, error) // 获取用户未拥有的且排序大于minSort的正常状态角色
+	GetUserPermissionFingerprint(user model.User) (string, error)           // 获取用户权限指纹(角色、接口权限、菜单的hash)
+	GetUserPermissions(user model.User) (*dto.UserPermissionsDto, error)    // 获取用户的权限(角色、可访问的接口和菜单树)
+
+	GetUsersWithExpiringRoles(within time.Duration) ([]*dto.UserRoleExpiringDto, error) // 获取角色在指定时间内即将过期的用户
+	UpdateUserRoleExpiresAt(userId uint, roleId uint, expiresAt *time.Time) error       // 更新用户角色的过期时间
+	BatchAssignRole(roleId uint, userIds []uint) (int64, error)                         // 为多个用户分配同一个角色
 
-	CreateUser(user *model.User) error                              // 创建用户
-	GetUserById(id uint) (model.User, error)                        // 获取单个用户
-	GetUsers(req *vo.UserListRequest) ([]*model.User, int64, error) // 获取用户列表
-	UpdateUser(user *model.User) error                              // 更新用户
-	BatchDeleteUserByIds(ids []uint) error                          // 批量删除
+	MergeUsers(sourceId uint, targetId uint) (*dto.MergeUserResultDto, error)    // 合并用户(将源用户合并到目标用户)
+	GetConflictUsers(usernames []string, mobiles []string) ([]model.User, error) // 获取用户名或手机号已被占用的用户(包括已删除的用户)
 
-	GetCurrentUser(c *gin.Context) (model.User, error)                  // 获取当前登录用户信息
-	GetCurrentUserMinRoleSort(c *gin.Context) (uint, model.User, error) // 获取当前用户角色排序最小值（最高等级角色）以及当前用户信息
-	GetUserMinRoleSortsByIds(ids []uint) ([]int, error)                 // 根据用户ID获取用户角色排序最小值
+	SetUserInfoCache(user model.User)               // 设置用户信息缓存
+	UpdateUserInfoCacheByRoleId(roleId uint) error  // 根据角色ID更新拥有该角色的用户信息缓存
+	ClearUserInfoCache()                            // 清理所有用户信息缓存
+	ClearUserPermissionCache()                      // 清理所有用户权限缓存
+	DeleteUserInfoCache(id uint)                    // 删除本节点指定用户的用户信息缓存
+	PingUserInfoCache() error                       // 检查用户信息缓存是否可用
+	PollUserCacheInvalidations() (int, error)       // 轮询其他节点的缓存失效记录并删除本地缓存
+	WarmUserInfoCache(ids []uint) (int, error)      // 预热用户信息缓存(ids为空时预热所有正常状态的用户)
+	EvictUserInfoCache(usernames []string) []string // 删除指定用户的用户信息缓存, 返回缓存中存在并被删除的用户名
 
-	SetUserInfoCache(username string, user model.User) // 设置用户信息缓存
-	UpdateUserInfoCacheByRoleId(roleId uint) error     // 根据角色ID更新拥有该角色的用户信息缓存
-	ClearUserInfoCache()                               // 清理所有用户信息缓存
+	DisableInactiveUsers(inactiveDays uint, exemptUsernames []string) ([]string, error) // 禁用长期未登录的用户
+
+	NeedInitialSetup() (bool, error)     // 是否需要首次安装初始化(没有任何用户)
+	InitialSetup(user *model.User) error // 首次安装初始化, 创建超级管理员
 }
 
 type UserRepository struct {
 }
 
-// 当前用户信息缓存，避免频繁获取数据库
-var userInfoCache = cache.New(24*time.Hour, 48*time.Hour)
+// 当前请求中已获取的当前用户信息在gin context中的key
+const currentUserContextKey = "currentUser"
 
 // UserRepository构造函数
 func NewUserRepository() IUserRepository {
@@ -46,72 +98,115 @@ func NewUserRepository() IUserRepository {
 
 // 登录
 func (ur UserRepository) Login(user *model.User) (*model.User, error) {
-	// 根据用户名获取用户(正常状态:用户状态正常)
-	var firstUser model.User
-	err := common.DB.
-		Where("username = ?", user.Username).
-		Preload("Roles").
-		First(&firstUser).Error
+	// 根据用户名从数据库获取用户(已过滤过期的角色), 不使用缓存, 避免缓存中的密码或状态已过期
+	firstUser, err := ur.GetUserByUsername(user.Username)
+	if err != nil {
+		return nil, err
+	}
+
+	// 判断用户及其角色的状态
+	if err := checkUserLoginStatus(&firstUser); err != nil {
+		return nil, err
+	}
+
+	// 校验密码
+	err = util.ComparePasswd(firstUser.Password, user.Password)
 	if err != nil {
-		return nil, errors.New("用户不存在")
+		return &firstUser, errors.New("密码错误")
 	}
 
+	// 记录最后登录时间, 不更新updated_at
+	now := time.Now()
+	err = common.DB.Model(&firstUser).UpdateColumn("last_login_at", now).Error
+	if err != nil {
+		return nil, err
+	}
+	firstUser.LastLoginAt = &now
+	setUserInfoCache(firstUser)
+	return &firstUser, nil
+}
+
+// 判断用户是否可以登录(登录和刷新token共用)
+func checkUserLoginStatus(user *model.User) error {
 	// 判断用户的状态
-	userStatus := firstUser.Status
+	userStatus := user.Status
 	if userStatus != 1 {
-		return nil, errors.New("用户被禁用")
+		if user.DisableReason != "" {
+			return errors.New("用户被禁用: " + user.DisableReason)
+		}
+		return errors.New("用户被禁用")
 	}
 
-	// 判断用户拥有的所有角色的状态,全部角色都被禁用则不能登录
-	roles := firstUser.Roles
+	// 判断用户拥有的所有角色的状态,全部角色都被禁用或已过期则不能登录
+	roles := user.Roles
 	isValidate := false
 	for _, role := range roles {
-		// 有一个正常状态的角色就可以登录
-		if role.Status == 1 {
+		// 有一个正常状态且未过期的角色就可以登录
+		if role.Status == 1 && !role.IsExpired() {
 			isValidate = true
 			break
 		}
 	}
 
 	if !isValidate {
-		return nil, errors.New("用户角色被禁用")
+		return errors.New("用户角色被禁用或已过期")
 	}
+	return nil
+}
 
-	// 校验密码
-	err = util.ComparePasswd(firstUser.Password, user.Password)
+// 获取刷新token的用户
+// 从数据库获取最新的用户信息, 使用与登录相同的校验, 校验通过后更新用户信息缓存
+func (ur UserRepository) GetRefreshTokenUser(userId uint) (*model.User, error) {
+	user, err := ur.GetActiveUserById(userId)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// 用户不存在或被禁用, 获取被禁用的用户以返回禁用原因
+		user, err = ur.GetUserById(userId)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("用户不存在")
+		}
+	}
 	if err != nil {
-		return &firstUser, errors.New("密码错误")
+		return nil, err
 	}
-	return &firstUser, nil
+	if err := checkUserLoginStatus(&user); err != nil {
+		return nil, err
+	}
+	setUserInfoCache(user)
+	return &user, nil
 }
 
 // 获取当前登录用户信息
 // 需要缓存，减少数据库访问
+// 不过滤用户状态, 会话期间被禁用的用户同样能获取到, 由调用方根据状态给出"已被禁用"的提示
 func (ur UserRepository) GetCurrentUser(c *gin.Context) (model.User, error) {
 	var newUser model.User
+	// 同一个请求内已获取过则直接返回, 保证整个请求内的用户信息一致
+	if currentUser, exist := c.Get(currentUserContextKey); exist {
+		return currentUser.(model.User), nil
+	}
 	ctxUser, exist := c.Get("user")
 	if !exist {
-		return newUser, errors.New("用户未登录")
+		return newUser, ErrUserNotLoggedIn
 	}
 	u, _ := ctxUser.(model.User)
 
 	// 先获取缓存
-	cacheUser, found := userInfoCache.Get(u.Username)
-	var user model.User
+	user, found := getUserInfoCache(u.ID)
 	var err error
-	if found {
-		user = cacheUser.(model.User)
-		err = nil
-	} else {
+	if !found {
 		// 缓存中没有就获取数据库
+		common.LogWithContext(c).Debugw("用户信息缓存未命中, 查询数据库", "user_id", u.ID, "username", u.Username)
 		user, err = ur.GetUserById(u.ID)
 		// 获取成功就缓存
 		if err != nil {
-			userInfoCache.Delete(u.Username)
+			deleteUserInfoCache(u.ID)
 		} else {
-			userInfoCache.Set(u.Username, user, cache.DefaultExpiration)
+			setUserInfoCache(user)
 		}
 	}
+	if err == nil {
+		c.Set(currentUserContextKey, user)
+	}
 	return user, err
 }
 
@@ -129,127 +224,747 @@ func (ur UserRepository) GetCurrentUserMinRoleSort(c *gin.Context) (uint, model.
 	for _, role := range currentRoles {
 		currentRoleSorts = append(currentRoleSorts, int(role.Sort))
 	}
-	// 当前用户角色排序最小值（最高等级角色）
-	currentRoleSortMin := uint(funk.MinInt(currentRoleSorts).(int))
+	// 当前用户角色排序最小值（最高等级角色）, 没有有效角色(如角色都已过期)的用户等级最低
+	currentRoleSortMin := uint(999)
+	if len(currentRoleSorts) > 0 {
+		currentRoleSortMin = uint(funk.MinInt(currentRoleSorts).(int))
+	}
 
 	return currentRoleSortMin, ctxUser, nil
 }
 
-// 获取单个用户
+// 获取单个用户(不过滤用户状态), 用于管理用户(如启用被禁用的用户)
+// 登录和Casbin中间件获取用户后再校验状态, 以便返回禁用原因
 func (ur UserRepository) GetUserById(id uint) (model.User, error) {
-	fmt.Println("GetUserById---")
 	var user model.User
 	err := common.DB.Where("id = ?", id).Preload("Roles").First(&user).Error
+	if err != nil {
+		return user, err
+	}
+	err = fillUserRolesExpiresAt(&user)
+	return user, err
+}
+
+// 获取单个正常状态的用户, 用于需要用户处于正常状态的场景(如刷新token)
+// 管理用户(如启用被禁用的用户)时使用GetUserById
+func (ur UserRepository) GetActiveUserById(id uint) (model.User, error) {
+	var user model.User
+	err := common.DB.Where("id = ? AND status = ?", id, 1).Preload("Roles").First(&user).Error
+	if err != nil {
+		return user, err
+	}
+	err = fillUserRolesExpiresAt(&user)
+	return user, err
+}
+
+// 根据用户名获取单个用户(不过滤用户状态)
+// 直接查询数据库, 保证密码、状态和双因素认证等信息是最新的, 用户不存在时返回"用户不存在"
+func (ur UserRepository) GetUserByUsername(username string) (model.User, error) {
+	username = util.NormalizeUsername(username)
+	var user model.User
+	// 保存的用户名都已规范化(启动时规范化之前保存的用户名), 可以使用唯一索引
+	err := common.DB.Where("username = ?", username).Preload("Roles").First(&user).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return user, errors.New("用户不存在")
+		}
+		return user, err
+	}
+	// 过滤已过期的角色
+	err = fillUserRolesExpiresAt(&user)
 	return user, err
 }
 
 // 获取用户列表
-func (ur UserRepository) GetUsers(req *vo.UserListRequest) ([]*model.User, int64, error) {
+// 查询绑定请求上下文, 客户端断开连接时查询会被中止
+// 角色通过Preload批量加载(user_roles和roles各一次查询), 查询次数与用户数量无关, 但不分页时会一次加载全部用户和角色到内存
+func (ur UserRepository) GetUsers(ctx context.Context, req *vo.UserListRequest) ([]*model.User, int64, error) {
+	var list []*model.User
+	// 当pageNum > 0 且 pageSize > 0 才分页
+	//记录总条数
+	total, err := ur.CountUsers(ctx, req)
+	if err != nil {
+		return list, total, err
+	}
+	db := userListQuery(common.DB.WithContext(ctx), req)
+	pageNum := int(req.PageNum)
+	pageSize := int(req.PageSize)
+	if pageNum > 0 && pageSize > 0 {
+		err = db.Offset((pageNum - 1) * pageSize).Limit(pageSize).Preload("Roles").Find(&list).Error
+	} else {
+		err = db.Preload("Roles").Find(&list).Error
+	}
+	return list, total, err
+}
+
+// 用户列表允许排序的字段, 排序字段会拼接到SQL中, 只能使用白名单中的字段
+var userListOrderColumns = map[string]bool{
+	"username":      true,
+	"nickname":      true,
+	"mobile":        true,
+	"status":        true,
+	"created_at":    true,
+	"last_login_at": true,
+}
+
+// 用户列表的排序, 排序字段不在白名单中时按创建时间倒序
+// 排序字段的值可能相同(如状态、同一时间批量创建), 最后按ID排序保证分页时顺序稳定, 不会重复或遗漏
+func userListOrder(req *vo.UserListRequest) string {
+	if !userListOrderColumns[req.OrderBy] {
+		return "created_at DESC, id DESC"
+	}
+	if strings.ToLower(req.Order) == "asc" {
+		return req.OrderBy + " ASC, id ASC"
+	}
+	return req.OrderBy + " DESC, id DESC"
+}
+
+// 获取拥有指定角色的用户列表, 查询条件和分页与获取用户列表相同
+// 查询绑定请求上下文, 客户端断开连接时查询会被中止
+func (ur UserRepository) GetUsersByRoleId(ctx context.Context, roleId uint, req *vo.UserListRequest) ([]*model.User, int64, error) {
 	var list []*model.User
-	db := common.DB.Model(&model.User{}).Order("created_at DESC")
+	db := common.DB.WithContext(ctx)
+	roleUserIds := db.Table("user_roles").Select("user_id").Where("role_id = ?", roleId)
+	var total int64
+	err := userListQuery(db, req).Where("id IN (?)", roleUserIds).Count(&total).Error
+	if err != nil {
+		return list, total, err
+	}
+	db = userListQuery(db, req).Where("id IN (?)", roleUserIds)
+	pageNum := int(req.PageNum)
+	pageSize := int(req.PageSize)
+	if pageNum > 0 && pageSize > 0 {
+		err = db.Offset((pageNum - 1) * pageSize).Limit(pageSize).Preload("Roles").Find(&list).Error
+	} else {
+		err = db.Preload("Roles").Find(&list).Error
+	}
+	return list, total, err
+}
+
+// 获取符合查询条件的用户数量(查询条件与获取用户列表相同, 忽略分页参数)
+func (ur UserRepository) CountUsers(ctx context.Context, req *vo.UserListRequest) (int64, error) {
+	var total int64
+	err := userListQuery(common.DB.WithContext(ctx), req).Count(&total).Error
+	return total, err
+}
+
+// 用户列表查询条件, 获取用户列表、用户数量和流式导出共用
+func userListQuery(db *gorm.DB, req *vo.UserListRequest) *gorm.DB {
+	db = db.Model(&model.User{}).Order(userListOrder(req))
 
 	username := strings.TrimSpace(req.Username)
 	if username != "" {
-		db = db.Where("username LIKE ?", fmt.Sprintf("%%%s%%", username))
+		db = db.Where("username LIKE ? ESCAPE '!'", likeContains(username))
 	}
 	nickname := strings.TrimSpace(req.Nickname)
 	if nickname != "" {
-		db = db.Where("nickname LIKE ?", fmt.Sprintf("%%%s%%", nickname))
+		db = db.Where("nickname LIKE ? ESCAPE '!'", likeContains(nickname))
 	}
 	mobile := strings.TrimSpace(req.Mobile)
 	if mobile != "" {
-		db = db.Where("mobile LIKE ?", fmt.Sprintf("%%%s%%", mobile))
+		db = db.Where("mobile LIKE ? ESCAPE '!'", likeContains(mobile))
 	}
 	status := req.Status
 	if status != 0 {
 		db = db.Where("status = ?", status)
 	}
-	// 当pageNum > 0 且 pageSize > 0 才分页
-	//记录总条数
-	var total int64
-	err := db.Count(&total).Error
+	// 创建时间范围, 结束日期当天也包含在内
+	if startTime, err := time.ParseInLocation("2006-01-02", req.StartTime, time.Local); err == nil {
+		db = db.Where("created_at >= ?", startTime)
+	}
+	if endTime, err := time.ParseInLocation("2006-01-02", req.EndTime, time.Local); err == nil {
+		db = db.Where("created_at < ?", endTime.AddDate(0, 0, 1))
+	}
+	if req.HasLoggedIn != nil {
+		if *req.HasLoggedIn {
+			db = db.Where("last_login_at IS NOT NULL")
+		} else {
+			db = db.Where("last_login_at IS NULL")
+		}
+	}
+	// 按拥有的角色过滤, 使用子查询, 拥有多个匹配的角色时不会重复, 分页和总数不受影响
+	if req.RoleId != 0 {
+		db = db.Where("id IN (?)", common.DB.Table("user_roles").Select("user_id").Where("role_id = ?", req.RoleId))
+	}
+	roleName := strings.TrimSpace(req.RoleName)
+	if roleName != "" {
+		db = db.Where("id IN (?)", common.DB.Table("user_roles").Select("user_roles.user_id").
+			Joins("JOIN roles ON roles.id = user_roles.role_id AND roles.deleted_at IS NULL").
+			Where("roles.name LIKE ? ESCAPE '!'", likeContains(roleName)))
+	}
+	return db
+}
+
+// LIKE模糊匹配包含keyword的参数, 转义keyword中的通配符(%和_)
+// 使用!作为转义字符(MySQL和SQLite都没有特殊含义), 查询条件需要加上ESCAPE '!'
+func likeContains(keyword string) string {
+	keyword = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(keyword)
+	return "%" + keyword + "%"
+}
+
+// 流式读取用户时每批加载角色的用户数量
+const streamUsersBatchSize = 200
+
+// 流式获取用户列表(使用与获取用户列表相同的查询条件, 不分页)
+// 通过数据库游标逐行读取, 每streamUsersBatchSize个用户批量加载一次角色, 内存占用与数据量无关
+// fn 处理每个用户, 返回错误时停止读取
+func (ur UserRepository) StreamUsers(ctx context.Context, req *vo.UserListRequest, fn func(user *model.User) error) error {
+	db := userListQuery(common.DB.WithContext(ctx), req)
+	rows, err := db.Rows()
 	if err != nil {
-		return list, total, err
+		return err
 	}
-	pageNum := int(req.PageNum)
-	pageSize := int(req.PageSize)
-	if pageNum > 0 && pageSize > 0 {
-		err = db.Offset((pageNum - 1) * pageSize).Limit(pageSize).Preload("Roles").Find(&list).Error
-	} else {
-		err = db.Preload("Roles").Find(&list).Error
+	defer rows.Close()
+
+	batch := make([]*model.User, 0, streamUsersBatchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := loadUsersRoles(ctx, batch); err != nil {
+			return err
+		}
+		for _, user := range batch {
+			if err := fn(user); err != nil {
+				return err
+			}
+		}
+		batch = batch[:0]
+		return nil
 	}
-	return list, total, err
+
+	for rows.Next() {
+		var user model.User
+		if err := common.DB.ScanRows(rows, &user); err != nil {
+			return err
+		}
+		batch = append(batch, &user)
+		if len(batch) >= streamUsersBatchSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		common.LogWithContext(ctx).Errorf("流式读取用户列表失败: %v", err)
+		return err
+	}
+	return flush()
+}
+
+// 批量加载用户的角色
+func loadUsersRoles(ctx context.Context, users []*model.User) error {
+	userIds := make([]uint, 0, len(users))
+	for _, user := range users {
+		userIds = append(userIds, user.ID)
+	}
+	var userRoles []model.UserRole
+	err := common.DB.WithContext(ctx).Where("user_id IN (?)", userIds).Find(&userRoles).Error
+	if err != nil {
+		return err
+	}
+	roleIds := make([]uint, 0)
+	for _, userRole := range userRoles {
+		roleIds = append(roleIds, userRole.RoleId)
+	}
+	roleMap := make(map[uint]*model.Role)
+	if len(roleIds) > 0 {
+		var roles []*model.Role
+		err = common.DB.WithContext(ctx).Where("id IN (?)", funk.Uniq(roleIds).([]uint)).Find(&roles).Error
+		if err != nil {
+			return err
+		}
+		for _, role := range roles {
+			roleMap[role.ID] = role
+		}
+	}
+	userMap := make(map[uint]*model.User, len(users))
+	for _, user := range users {
+		user.Roles = make([]*model.Role, 0)
+		userMap[user.ID] = user
+	}
+	for _, userRole := range userRoles {
+		if role, ok := roleMap[userRole.RoleId]; ok {
+			if user, ok := userMap[userRole.UserId]; ok {
+				user.Roles = append(user.Roles, role)
+			}
+		}
+	}
+	return nil
 }
 
 // 更新密码
+// 开启历史密码限制时在同一事务中记录旧密码, 并只保留最近的记录
 func (ur UserRepository) ChangePwd(username string, hashNewPasswd string) error {
-	err := common.DB.Model(&model.User{}).Where("username = ?", username).Update("password", hashNewPasswd).Error
-	// 如果更新密码成功，则更新当前用户信息缓存
-	// 先获取缓存
-	cacheUser, found := userInfoCache.Get(username)
-	if err == nil {
-		if found {
-			user := cacheUser.(model.User)
-			user.Password = hashNewPasswd
-			userInfoCache.Set(username, user, cache.DefaultExpiration)
-		} else {
-			// 没有缓存就获取用户信息缓存
-			var user model.User
-			common.DB.Where("username = ?", username).First(&user)
-			userInfoCache.Set(username, user, cache.DefaultExpiration)
+	user, err := ur.GetUserByUsername(username)
+	if err != nil {
+		return err
+	}
+	err = common.DB.Transaction(func(tx *gorm.DB) error {
+		// 只限制当前密码时不需要记录历史密码
+		historyCount := config.Conf.Security.PasswordHistoryCount
+		if historyCount > 1 {
+			history := model.PasswordHistory{UserId: user.ID, PasswordHash: user.Password}
+			if err := tx.Create(&history).Error; err != nil {
+				return err
+			}
+			// 当前密码之外只需要保留historyCount-1条
+			var keepIds []uint
+			err := tx.Model(&model.PasswordHistory{}).Where("user_id = ?", user.ID).
+				Order("id DESC").Limit(historyCount-1).Pluck("id", &keepIds).Error
+			if err != nil {
+				return err
+			}
+			db := tx.Where("user_id = ?", user.ID)
+			if len(keepIds) > 0 {
+				db = db.Where("id NOT IN (?)", keepIds)
+			}
+			if err := db.Delete(&model.PasswordHistory{}).Error; err != nil {
+				return err
+			}
 		}
+		// 修改密码后不再需要强制修改密码
+		return tx.Model(&user).Updates(map[string]interface{}{
+			"password":                hashNewPasswd,
+			"password_reset_required": false,
+			"version":                 gorm.Expr("version + 1"),
+		}).Error
+	})
+	// 如果更新密码成功，则删除当前用户信息缓存, 下次访问时重新从数据库获取
+	// 删除失败最多是多一次数据库查询, 而更新缓存失败会留下旧密码, 所以只删除不更新
+	if err == nil {
+		invalidateUserInfoCache(user.ID)
+	}
+
+	return err
+}
+
+// 重置用户密码, 同时要求用户下次登录时修改密码, 版本号加1, 成功后删除该用户的信息缓存
+func (ur UserRepository) ResetPassword(id uint, hashPasswd string) error {
+	var user model.User
+	if err := common.DB.Select("id").Where("id = ?", id).First(&user).Error; err != nil {
+		return err
+	}
+	err := common.DB.Model(&user).Updates(map[string]interface{}{
+		"password":                hashPasswd,
+		"password_reset_required": true,
+		"version":                 gorm.Expr("version + 1"),
+	}).Error
+	if err == nil {
+		invalidateUserInfoCache(user.ID)
+	}
+	return err
+}
+
+// 更新用户状态, 只更新状态列(启用时清空禁用原因), 版本号加1, 成功后删除该用户的信息缓存
+func (ur UserRepository) UpdateStatus(id uint, status uint) error {
+	var user model.User
+	if err := common.DB.Select("id").Where("id = ?", id).First(&user).Error; err != nil {
+		return err
+	}
+	updates := map[string]interface{}{
+		"status":  status,
+		"version": gorm.Expr("version + 1"),
+	}
+	if status == 1 {
+		updates["disable_reason"] = ""
+	}
+	err := common.DB.Model(&user).Updates(updates).Error
+	if err == nil {
+		invalidateUserInfoCache(user.ID)
+	}
+	return err
+}
+
+// 更新个人资料, 只更新昵称、头像、手机号和简介, 版本号加1, 成功后删除该用户的信息缓存
+func (ur UserRepository) UpdateProfile(user *model.User) error {
+	err := common.DB.Model(&model.User{}).Where("id = ?", user.ID).Updates(map[string]interface{}{
+		"mobile":       user.Mobile,
+		"avatar":       user.Avatar,
+		"nickname":     user.Nickname,
+		"introduction": user.Introduction,
+		"version":      gorm.Expr("version + 1"),
+	}).Error
+	if err != nil {
+		return translateUserUniqueError(err)
+	}
+	invalidateUserInfoCache(user.ID)
+	return nil
+}
+
+// 批量更新用户状态, 在一个事务中更新(启用时清空禁用原因), 版本号加1, 成功后删除这些用户的信息缓存
+func (ur UserRepository) BatchUpdateStatus(ids []uint, status uint) error {
+	updates := map[string]interface{}{
+		"status":  status,
+		"version": gorm.Expr("version + 1"),
+	}
+	if status == 1 {
+		updates["disable_reason"] = ""
+	}
+	err := common.DB.Transaction(func(tx *gorm.DB) error {
+		return tx.Model(&model.User{}).Where("id IN (?)", ids).Updates(updates).Error
+	})
+	if err == nil {
+		invalidateUserInfoCache(ids...)
+	}
+	return err
+}
+
+// 更新用户头像, 版本号加1, 成功后删除该用户的信息缓存
+func (ur UserRepository) UpdateAvatar(id uint, avatar string) error {
+	err := common.DB.Model(&model.User{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"avatar":  avatar,
+		"version": gorm.Expr("version + 1"),
+	}).Error
+	if err == nil {
+		invalidateUserInfoCache(id)
 	}
+	return err
+}
 
+// 更新用户的两步验证密钥(已加密)和开启状态, 版本号加1, 成功后删除该用户的信息缓存
+func (ur UserRepository) UpdateTwoFactor(id uint, secret string, enabled bool) error {
+	err := common.DB.Model(&model.User{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"two_factor_secret":  secret,
+		"two_factor_enabled": enabled,
+		"version":            gorm.Expr("version + 1"),
+	}).Error
+	if err == nil {
+		invalidateUserInfoCache(id)
+	}
 	return err
 }
 
+// 是否为最近使用过的密码(当前密码和最近的历史密码, 共password-history-count个)
+func (ur UserRepository) IsRecentPassword(user model.User, passwd string) (bool, error) {
+	historyCount := config.Conf.Security.PasswordHistoryCount
+	if historyCount <= 0 {
+		return false, nil
+	}
+	if util.ComparePasswd(user.Password, passwd) == nil {
+		return true, nil
+	}
+	if historyCount == 1 {
+		return false, nil
+	}
+	var histories []model.PasswordHistory
+	err := common.DB.Where("user_id = ?", user.ID).Order("id DESC").Limit(historyCount - 1).Find(&histories).Error
+	if err != nil {
+		return false, err
+	}
+	for _, history := range histories {
+		if util.ComparePasswd(history.PasswordHash, passwd) == nil {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
 // 创建用户
 func (ur UserRepository) CreateUser(user *model.User) error {
+	user.Username = util.NormalizeUsername(user.Username)
 	err := common.DB.Create(user).Error
-	return err
+	return translateUserUniqueError(err)
+}
+
+// 批量创建用户, 在一个事务中创建, 任一用户创建失败则全部回滚
+func (ur UserRepository) BatchCreateUsers(users []*model.User) error {
+	return common.DB.Transaction(func(tx *gorm.DB) error {
+		for _, user := range users {
+			user.Username = util.NormalizeUsername(user.Username)
+			if err := tx.Create(user).Error; err != nil {
+				return fmt.Errorf("创建用户%s失败: %v", user.Username, err)
+			}
+		}
+		return nil
+	})
 }
 
 // 更新用户
+// 用户名变化时在同一事务中更新以用户名关联的数据(创建人、操作日志等), 成功后删除用户信息缓存
+// 版本号与数据库中不一致(已被他人修改)时不更新
 func (ur UserRepository) UpdateUser(user *model.User) error {
-	err := common.DB.Model(user).Updates(user).Error
-	if err != nil {
-		return err
-	}
-	err = common.DB.Model(user).Association("Roles").Replace(user.Roles)
+	var oldUser model.User
+	user.Username = util.NormalizeUsername(user.Username)
+	err := common.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Select("username").Where("id = ?", user.ID).First(&oldUser).Error; err != nil {
+			return err
+		}
+		// 乐观锁, 只有版本号与读取时一致才更新, 同时版本号加1
+		expectVersion := user.Version
+		user.Version = expectVersion + 1
+		result := tx.Model(user).Where("version = ?", expectVersion).Updates(user)
+		if result.Error != nil {
+			return translateUserUniqueError(result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("数据已被他人修改，请刷新后重试")
+		}
+		// 启用用户时清空禁用原因(Updates不会更新空值)
+		if user.Status == 1 {
+			if err := tx.Model(user).Update("disable_reason", "").Error; err != nil {
+				return err
+			}
+		}
+		// 先删除已过期的角色, 重新分配已过期的角色时作为新角色(永不过期)添加
+		if err := deleteExpiredUserRoles(tx, "user_id = ?", user.ID); err != nil {
+			return err
+		}
+		if err := tx.Model(user).Association("Roles").Replace(user.Roles); err != nil {
+			return err
+		}
+		if oldUser.Username == user.Username {
+			return nil
+		}
+		// 用户名变化, 更新以用户名关联的数据
+		renames := []struct {
+			model  interface{}
+			column string
+		}{
+			{&model.User{}, "creator"},
+			{&model.Role{}, "creator"},
+			{&model.Menu{}, "creator"},
+			{&model.Api{}, "creator"},
+			{&model.OperationLog{}, "username"},
+			{&model.PolicyChangeLog{}, "operator"},
+		}
+		for _, rename := range renames {
+			updates := map[string]interface{}{rename.column: user.Username}
+			if _, ok := rename.model.(*model.User); ok {
+				updates["version"] = gorm.Expr("version + 1")
+			}
+			err := tx.Model(rename.model).Where(rename.column+" = ?", oldUser.Username).Updates(updates).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 
 	//err := common.DB.Session(&gorm.Session{FullSaveAssociations: true}).Updates(&user).Error
 
-	// 如果更新成功就更新用户信息缓存
+	// 如果更新成功就删除用户信息缓存(缓存key为用户ID, 用户名变化不影响)
+	// 传入的user只包含更新的字段(没有密码、登录时间等), 不能直接写入缓存, 下次访问时重新从数据库获取
 	if err == nil {
-		userInfoCache.Set(user.Username, *user, cache.DefaultExpiration)
+		invalidateUserInfoCache(user.ID)
 	}
 	return err
 }
 
-// 批量删除
-func (ur UserRepository) BatchDeleteUserByIds(ids []uint) error {
-	// 用户和角色存在多对多关联关系
-	var users []model.User
-	for _, id := range ids {
-		// 根据ID获取用户
-		user, err := ur.GetUserById(id)
+// 每批新增或更新的用户数量
+const upsertUsersBatchSize = 200
+
+// 新增或更新时更新的字段, 用户名冲突时只更新这些字段
+// 不更新创建人和角色, 密码只在提供时更新
+var upsertUserColumns = []string{"mobile", "avatar", "nickname", "introduction", "status", "updated_at"}
+
+// 按用户名批量新增或更新用户, 用于从外部数据源(LDAP、HR系统等)同步用户
+// 在一个事务中执行, 提供了密码(已加密)的用户同时更新密码, 未提供密码的用户不会覆盖原密码
+// 不处理用户的角色关联, 已删除的用户只更新不恢复, 更新的用户版本号加1
+// 手机号已被其他用户(包括已删除的用户)使用时整批失败, 不做任何修改
+func (ur UserRepository) UpsertUsers(users []model.User) error {
+	if len(users) == 0 {
+		return nil
+	}
+	// 按是否提供密码分组, 两组更新的字段不同
+	var withPasswd, withoutPasswd []model.User
+	usernames := make([]string, 0, len(users))
+	mobiles := make([]string, 0, len(users))
+	// 手机号对应的用户名, 用于校验手机号是否被其他用户使用
+	mobileUsernames := make(map[string]string, len(users))
+	seenUsernames := make(map[string]bool, len(users))
+	for _, user := range users {
+		user.Username = util.NormalizeUsername(user.Username)
+		if user.Username == "" {
+			return errors.New("用户名不能为空")
+		}
+		if seenUsernames[user.Username] {
+			return fmt.Errorf("用户名%s重复", user.Username)
+		}
+		seenUsernames[user.Username] = true
+		if username, ok := mobileUsernames[user.Mobile]; ok {
+			return fmt.Errorf("用户%s与用户%s的手机号重复", username, user.Username)
+		}
+		mobileUsernames[user.Mobile] = user.Username
+		user.Roles = nil
+		if user.Password != "" {
+			withPasswd = append(withPasswd, user)
+		} else {
+			withoutPasswd = append(withoutPasswd, user)
+		}
+		usernames = append(usernames, user.Username)
+		mobiles = append(mobiles, user.Mobile)
+	}
+	err := common.DB.Transaction(func(tx *gorm.DB) error {
+		// MySQL的ON DUPLICATE KEY UPDATE在任意唯一索引冲突时都会更新,
+		// 手机号属于其他用户时会覆盖该用户(包括密码), 需要提前校验
+		var existing []model.User
+		err := tx.Unscoped().Select("id, username, mobile").
+			Where("username IN (?) OR mobile IN (?)", usernames, mobiles).
+			Find(&existing).Error
 		if err != nil {
-			return errors.New(fmt.Sprintf("未获取到ID为%d的用户", id))
+			return err
+		}
+		for _, user := range existing {
+			if username, ok := mobileUsernames[user.Mobile]; ok && util.NormalizeUsername(user.Username) != username {
+				return fmt.Errorf("用户%s的手机号%s已被用户%s使用", username, user.Mobile, user.Username)
+			}
 		}
-		users = append(users, user)
+
+		groups := []struct {
+			users   []model.User
+			columns []string
+		}{
+			{withPasswd, append([]string{"password"}, upsertUserColumns...)},
+			{withoutPasswd, upsertUserColumns},
+		}
+		for _, group := range groups {
+			if len(group.users) == 0 {
+				continue
+			}
+			updates := append(clause.AssignmentColumns(group.columns), clause.Assignment{
+				Column: clause.Column{Name: "version"},
+				Value:  gorm.Expr("version + 1"),
+			})
+			err := tx.Omit("Roles").Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "username"}},
+				DoUpdates: updates,
+			}).CreateInBatches(group.users, upsertUsersBatchSize).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	// 更新成功后删除这些用户的信息缓存, 下次获取时重新加载
+	if err == nil {
+		ur.EvictUserInfoCache(usernames)
 	}
+	return err
+}
 
-	err := common.DB.Select("Roles").Unscoped().Delete(&users).Error
+// 批量删除
+// 在一个事务中删除用户的角色关联并软删除用户
+func (ur UserRepository) BatchDeleteUserByIds(ids []uint) error {
+	ids = funk.Uniq(ids).([]uint)
+	var users []model.User
+	err := common.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id IN (?)", ids).Find(&users).Error; err != nil {
+			return err
+		}
+		// 所有用户都存在才删除
+		exists := make(map[uint]bool, len(users))
+		for _, user := range users {
+			exists[user.ID] = true
+		}
+		for _, id := range ids {
+			if !exists[id] {
+				return fmt.Errorf("未获取到ID为%d的用户", id)
+			}
+		}
+		// 软删除, 保留用户角色关联, 恢复用户时角色不变
+		if err := tx.Where("user_id IN (?)", ids).Delete(&model.PasswordHistory{}).Error; err != nil {
+			return err
+		}
+		if err := increaseUserVersion(tx, ids...); err != nil {
+			return err
+		}
+		return tx.Where("id IN (?)", ids).Delete(&model.User{}).Error
+	})
 	// 删除用户成功，则删除用户信息缓存
 	if err == nil {
-		for _, user := range users {
-			userInfoCache.Delete(user.Username)
+		invalidateUserInfoCache(ids...)
+	}
+	return err
+}
+
+// 获取已删除的用户列表(回收站), 查询条件与获取用户列表相同
+func (ur UserRepository) GetDeletedUsers(req *vo.UserListRequest) ([]*model.User, int64, error) {
+	var list []*model.User
+	db := userListQuery(common.DB.Unscoped(), req).Where("deleted_at IS NOT NULL")
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return list, total, err
+	}
+	pageNum := int(req.PageNum)
+	pageSize := int(req.PageSize)
+	var err error
+	if pageNum > 0 && pageSize > 0 {
+		err = db.Offset((pageNum - 1) * pageSize).Limit(pageSize).Preload("Roles").Find(&list).Error
+	} else {
+		err = db.Preload("Roles").Find(&list).Error
+	}
+	return list, total, err
+}
+
+// 批量恢复已删除的用户, 所有用户都是已删除状态才恢复
+// 删除用户时保留了角色关联, 恢复后角色不变, 历史密码记录已删除不会恢复
+func (ur UserRepository) RestoreUsers(ids []uint) error {
+	ids = funk.Uniq(ids).([]uint)
+	err := common.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Unscoped().Model(&model.User{}).
+			Where("id IN (?) AND deleted_at IS NOT NULL", ids).
+			Updates(map[string]interface{}{"deleted_at": nil, "version": gorm.Expr("version + 1")})
+		if result.Error != nil {
+			return result.Error
 		}
+		if result.RowsAffected != int64(len(ids)) {
+			return errors.New("存在未删除或不存在的用户")
+		}
+		return nil
+	})
+	// 删除可能残留的缓存, 下次访问时从数据库重新获取
+	if err == nil {
+		invalidateUserInfoCache(ids...)
 	}
 	return err
 }
 
+// 根据用户ID获取每个用户的角色排序最小值(key为用户ID)
+// 没有角色的用户排序最小值为999, 未获取到的用户不在结果中
+func (ur UserRepository) GetUserMinRoleSortMapByIds(ids []uint) (map[uint]uint, error) {
+	return userMinRoleSortMap(common.DB.Where("id IN (?)", ids))
+}
+
+// 根据用户ID获取每个已删除用户的角色排序最小值(key为用户ID), 用于恢复用户时的角色等级校验
+func (ur UserRepository) GetDeletedUserMinRoleSortMapByIds(ids []uint) (map[uint]uint, error) {
+	return userMinRoleSortMap(common.DB.Unscoped().Where("id IN (?) AND deleted_at IS NOT NULL", ids))
+}
+
+// 获取查询到的每个用户的角色排序最小值, 与GetUserMinRoleSortsByIds相同, 已过期的角色不计算在内
+func userMinRoleSortMap(db *gorm.DB) (map[uint]uint, error) {
+	var userList []model.User
+	err := db.Preload("Roles").Find(&userList).Error
+	if err != nil {
+		return nil, err
+	}
+	userPtrs := make([]*model.User, len(userList))
+	for i := range userList {
+		userPtrs[i] = &userList[i]
+	}
+	err = fillUserRolesExpiresAt(userPtrs...)
+	if err != nil {
+		return nil, err
+	}
+	sortMap := make(map[uint]uint, len(userList))
+	for _, user := range userList {
+		// 没有有效角色(如角色都已过期)的用户等级最低
+		var minSort uint = 999
+		for _, role := range user.Roles {
+			if role.IsExpired() {
+				continue
+			}
+			if role.Sort < minSort {
+				minSort = role.Sort
+			}
+		}
+		sortMap[user.ID] = minSort
+	}
+	return sortMap, nil
+}
+
 // 根据用户ID获取用户角色排序最小值
+// 没有角色的用户排序最小值为999, 未获取到的用户不在结果中(都未获取到时返回空列表)
 func (ur UserRepository) GetUserMinRoleSortsByIds(ids []uint) ([]int, error) {
 	// 根据用户ID获取用户信息
 	var userList []model.User
@@ -257,8 +972,13 @@ func (ur UserRepository) GetUserMinRoleSortsByIds(ids []uint) ([]int, error) {
 	if err != nil {
 		return []int{}, err
 	}
-	if len(userList) == 0 {
-		return []int{}, errors.New("未获取到任何用户信息")
+	userPtrs := make([]*model.User, len(userList))
+	for i := range userList {
+		userPtrs[i] = &userList[i]
+	}
+	err = fillUserRolesExpiresAt(userPtrs...)
+	if err != nil {
+		return []int{}, err
 	}
 	var roleMinSortList []int
 	for _, user := range userList {
@@ -267,15 +987,19 @@ func (ur UserRepository) GetUserMinRoleSortsByIds(ids []uint) ([]int, error) {
 		for _, role := range roles {
 			roleSortList = append(roleSortList, int(role.Sort))
 		}
-		roleMinSort := funk.MinInt(roleSortList).(int)
+		// 没有有效角色(如角色都已过期)的用户等级最低
+		roleMinSort := 999
+		if len(roleSortList) > 0 {
+			roleMinSort = funk.MinInt(roleSortList).(int)
+		}
 		roleMinSortList = append(roleMinSortList, roleMinSort)
 	}
 	return roleMinSortList, nil
 }
 
 // 设置用户信息缓存
-func (ur UserRepository) SetUserInfoCache(username string, user model.User) {
-	userInfoCache.Set(username, user, cache.DefaultExpiration)
+func (ur UserRepository) SetUserInfoCache(user model.User) {
+	setUserInfoCache(user)
 }
 
 // 根据角色ID更新拥有该角色的用户信息缓存
@@ -293,17 +1017,535 @@ func (ur UserRepository) UpdateUserInfoCacheByRoleId(roleId uint) error {
 	}
 
 	// 更新用户信息缓存
+	userIds := make([]uint, 0, len(users))
 	for _, user := range users {
-		_, found := userInfoCache.Get(user.Username)
-		if found {
-			userInfoCache.Set(user.Username, *user, cache.DefaultExpiration)
+		if _, found := getUserInfoCache(user.ID); found {
+			setUserInfoCache(*user)
 		}
+		userIds = append(userIds, user.ID)
 	}
+	deleteUserPermissionCache(userIds...)
+	publishUserCacheInvalidation(userIds...)
 
 	return err
 }
 
-//清理所有用户信息缓存
+// 清理所有用户信息缓存
 func (ur UserRepository) ClearUserInfoCache() {
 	userInfoCache.Flush()
+	publishUserCacheInvalidation(cacheInvalidationFlushAll)
+}
+
+// 预热用户信息缓存(ids为空时预热所有正常状态的用户)
+// 按批次分组, 由有限数量的goroutine并发查询数据库, 避免瞬间压垮数据库
+func (ur UserRepository) WarmUserInfoCache(ids []uint) (int, error) {
+	if len(ids) == 0 {
+		err := common.DB.Model(&model.User{}).Where("status = ?", 1).Pluck("id", &ids).Error
+		if err != nil {
+			return 0, err
+		}
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	// 每批查询的用户数量
+	batchSize := 50
+	batches := make(chan []uint)
+	go func() {
+		for i := 0; i < len(ids); i += batchSize {
+			end := i + batchSize
+			if end > len(ids) {
+				end = len(ids)
+			}
+			batches <- ids[i:end]
+		}
+		close(batches)
+	}()
+
+	concurrency := config.Conf.Cache.WarmConcurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		count    int
+		firstErr error
+	)
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for batch := range batches {
+				// 查询数据库和写入缓存不持有锁, 多个批次并发执行
+				users, err := loadWarmUsers(batch)
+				if err == nil {
+					for _, user := range users {
+						setUserInfoCache(user)
+					}
+				}
+				mu.Lock()
+				if err != nil && firstErr == nil {
+					firstErr = err
+				}
+				count += len(users)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	return count, firstErr
+}
+
+// 获取预热缓存的一批用户(预加载角色并过滤已过期的角色)
+func loadWarmUsers(ids []uint) ([]model.User, error) {
+	var users []model.User
+	err := common.DB.Where("id IN (?)", ids).Preload("Roles").Find(&users).Error
+	if err != nil {
+		return nil, err
+	}
+	userPtrs := make([]*model.User, len(users))
+	for i := range users {
+		userPtrs[i] = &users[i]
+	}
+	if err := fillUserRolesExpiresAt(userPtrs...); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// 填充用户角色的过期时间, 并过滤掉已过期的角色
+// 预加载的角色可能被多个用户共享, 这里为每个用户复制一份角色再填充过期时间
+func fillUserRolesExpiresAt(users ...*model.User) error {
+	if len(users) == 0 {
+		return nil
+	}
+	userIds := make([]uint, 0, len(users))
+	for _, user := range users {
+		userIds = append(userIds, user.ID)
+	}
+	var userRoles []model.UserRole
+	err := common.DB.Where("user_id IN (?)", userIds).Where("expires_at IS NOT NULL").Find(&userRoles).Error
+	if err != nil {
+		return err
+	}
+	if len(userRoles) == 0 {
+		return nil
+	}
+	expiresAtMap := make(map[string]time.Time)
+	for _, userRole := range userRoles {
+		expiresAtMap[fmt.Sprintf("%d-%d", userRole.UserId, userRole.RoleId)] = *userRole.ExpiresAt
+	}
+
+	for _, user := range users {
+		roles := make([]*model.Role, 0, len(user.Roles))
+		for _, role := range user.Roles {
+			expiresAt, ok := expiresAtMap[fmt.Sprintf("%d-%d", user.ID, role.ID)]
+			if !ok {
+				roles = append(roles, role)
+				continue
+			}
+			if !expiresAt.After(time.Now()) {
+				continue
+			}
+			roleCopy := *role
+			roleCopy.ExpiresAt = &expiresAt
+			roles = append(roles, &roleCopy)
+		}
+		user.Roles = roles
+	}
+	return nil
+}
+
+// 删除符合条件的已过期的用户角色
+func deleteExpiredUserRoles(tx *gorm.DB, query string, args ...interface{}) error {
+	return tx.Where(query, args...).Where("expires_at IS NOT NULL AND expires_at <= ?", time.Now()).Delete(&model.UserRole{}).Error
+}
+
+// 获取角色在指定时间内即将过期的用户
+func (ur UserRepository) GetUsersWithExpiringRoles(within time.Duration) ([]*dto.UserRoleExpiringDto, error) {
+	list := make([]*dto.UserRoleExpiringDto, 0)
+	now := time.Now()
+	err := common.DB.Table("user_roles").
+		Select("users.id AS user_id, users.username, users.nickname, roles.id AS role_id, roles.name AS role_name, user_roles.expires_at").
+		Joins("JOIN users ON users.id = user_roles.user_id AND users.deleted_at IS NULL").
+		Joins("JOIN roles ON roles.id = user_roles.role_id AND roles.deleted_at IS NULL").
+		Where("user_roles.expires_at > ? AND user_roles.expires_at <= ?", now, now.Add(within)).
+		Order("user_roles.expires_at").
+		Scan(&list).Error
+	return list, err
+}
+
+// 更新用户角色的过期时间, 用户的版本号加1
+func (ur UserRepository) UpdateUserRoleExpiresAt(userId uint, roleId uint, expiresAt *time.Time) error {
+	var count int64
+	err := common.DB.Model(&model.UserRole{}).Where("user_id = ? AND role_id = ?", userId, roleId).Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return errors.New("用户未拥有该角色")
+	}
+	err = common.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&model.UserRole{}).
+			Where("user_id = ? AND role_id = ?", userId, roleId).
+			Update("expires_at", expiresAt).Error
+		if err != nil {
+			return err
+		}
+		return increaseUserVersion(tx, userId)
+	})
+	if err != nil {
+		return err
+	}
+
+	// 更新成功则删除该用户的信息缓存, 下次访问时重新获取
+	invalidateUserInfoCache(userId)
+	return nil
+}
+
+// 为多个用户分配同一个角色, 在一个事务中添加用户角色关联, 返回新增的关联数量
+// 已拥有该角色的用户保持不变(包括角色过期时间和版本号), 新增角色的用户版本号加1, 成功后删除这些用户的信息缓存
+func (ur UserRepository) BatchAssignRole(roleId uint, userIds []uint) (int64, error) {
+	userIds = funk.Uniq(userIds).([]uint)
+	userRoles := make([]model.UserRole, 0, len(userIds))
+	for _, userId := range userIds {
+		userRoles = append(userRoles, model.UserRole{UserId: userId, RoleId: roleId})
+	}
+	var added int64
+	err := common.DB.Transaction(func(tx *gorm.DB) error {
+		// 已过期的角色重新分配
+		if err := deleteExpiredUserRoles(tx, "role_id = ? AND user_id IN (?)", roleId, userIds); err != nil {
+			return err
+		}
+		var assignedIds []uint
+		err := tx.Model(&model.UserRole{}).Where("role_id = ? AND user_id IN (?)", roleId, userIds).Pluck("user_id", &assignedIds).Error
+		if err != nil {
+			return err
+		}
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&userRoles)
+		added = result.RowsAffected
+		if result.Error != nil {
+			return result.Error
+		}
+		return increaseUserVersion(tx, funk.Subtract(userIds, assignedIds).([]uint)...)
+	})
+	if err == nil {
+		invalidateUserInfoCache(userIds...)
+	}
+	return added, err
+}
+
+// 合并用户(将源用户合并到目标用户)
+// 在一个事务中将源用户的操作日志、创建的用户转移给目标用户, 合并双方角色(不合并已过期的角色), 最后软删除源用户
+func (ur UserRepository) MergeUsers(sourceId uint, targetId uint) (*dto.MergeUserResultDto, error) {
+	result := &dto.MergeUserResultDto{
+		SourceId:     sourceId,
+		TargetId:     targetId,
+		AddedRoleIds: make([]uint, 0),
+	}
+	var source, target model.User
+	err := common.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ?", sourceId).First(&source).Error; err != nil {
+			return fmt.Errorf("未获取到ID为%d的用户", sourceId)
+		}
+		if err := tx.Where("id = ?", targetId).First(&target).Error; err != nil {
+			return fmt.Errorf("未获取到ID为%d的用户", targetId)
+		}
+
+		// 转移操作日志
+		res := tx.Model(&model.OperationLog{}).Where("username = ?", source.Username).Update("username", target.Username)
+		if res.Error != nil {
+			return res.Error
+		}
+		result.MovedOperationLogs = res.RowsAffected
+
+		// 转移源用户创建的用户
+		res = tx.Model(&model.User{}).Where("creator = ?", source.Username).
+			Updates(map[string]interface{}{"creator": target.Username, "version": gorm.Expr("version + 1")})
+		if res.Error != nil {
+			return res.Error
+		}
+		result.MovedCreatedUsers = res.RowsAffected
+
+		// 合并角色, 只追加源用户未过期且目标用户没有(或已过期)的角色, 保留源用户角色的过期时间
+		now := time.Now()
+		var targetRoleIds []uint
+		err := tx.Model(&model.UserRole{}).Where("user_id = ?", target.ID).
+			Where("expires_at IS NULL OR expires_at > ?", now).Pluck("role_id", &targetRoleIds).Error
+		if err != nil {
+			return err
+		}
+		var sourceUserRoles []model.UserRole
+		err = tx.Model(&model.UserRole{}).
+			Joins("JOIN roles ON roles.id = user_roles.role_id AND roles.deleted_at IS NULL").
+			Where("user_roles.user_id = ?", source.ID).
+			Where("user_roles.expires_at IS NULL OR user_roles.expires_at > ?", now).
+			Find(&sourceUserRoles).Error
+		if err != nil {
+			return err
+		}
+		addUserRoles := make([]model.UserRole, 0)
+		for _, userRole := range sourceUserRoles {
+			if !funk.Contains(targetRoleIds, userRole.RoleId) {
+				addUserRoles = append(addUserRoles, model.UserRole{UserId: target.ID, RoleId: userRole.RoleId, ExpiresAt: userRole.ExpiresAt})
+				result.AddedRoleIds = append(result.AddedRoleIds, userRole.RoleId)
+			}
+		}
+		if len(addUserRoles) > 0 {
+			// 目标用户已过期的角色使用源用户角色的过期时间
+			err := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "user_id"}, {Name: "role_id"}},
+				DoUpdates: clause.AssignmentColumns([]string{"expires_at"}),
+			}).Create(&addUserRoles).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		// 软删除源用户
+		if err := tx.Where("user_id = ?", source.ID).Delete(&model.PasswordHistory{}).Error; err != nil {
+			return err
+		}
+		if err := increaseUserVersion(tx, source.ID, target.ID); err != nil {
+			return err
+		}
+		return tx.Delete(&source).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// 合并成功则删除双方的用户信息缓存
+	invalidateUserInfoCache(source.ID, target.ID)
+	return result, nil
+}
+
+// 根据用户名批量获取用户的最大数量
+const maxGetUsersByUsernamesSize = 500
+
+// 根据用户名批量获取用户
+// 只返回存在的用户, 调用方根据返回结果判断哪些用户名不存在
+func (ur UserRepository) GetUsersByUsernames(names []string) ([]model.User, error) {
+	if len(names) > maxGetUsersByUsernamesSize {
+		return nil, fmt.Errorf("一次最多查询%d个用户名", maxGetUsersByUsernamesSize)
+	}
+	// 与登录时使用相同的用户名规范化处理
+	usernames := make([]string, 0, len(names))
+	for _, name := range names {
+		if name = util.NormalizeUsername(name); name != "" {
+			usernames = append(usernames, name)
+		}
+	}
+	var users []model.User
+	if len(usernames) == 0 {
+		return users, nil
+	}
+	err := common.DB.Where("username IN (?)", funk.UniqString(usernames)).Find(&users).Error
+	return users, err
+}
+
+// 将用户名、手机号唯一索引冲突的数据库错误转换为友好的提示
+// 调用方已提前校验, 这里作为并发创建时的兜底
+func translateUserUniqueError(err error) error {
+	return translateDuplicateKeyError(err, map[string]string{"username": MsgUsernameExists, "mobile": MsgMobileUsed})
+}
+
+// 获取用户名或手机号已被占用的用户(包括已删除的用户)
+// 用户名和手机号是唯一索引, 已删除的用户同样占用, 用户名不区分大小写
+func (ur UserRepository) GetConflictUsers(names []string, mobiles []string) ([]model.User, error) {
+	var users []model.User
+	usernames := make([]string, 0, len(names))
+	for _, name := range names {
+		if name = util.NormalizeUsername(name); name != "" {
+			usernames = append(usernames, name)
+		}
+	}
+	if len(usernames) == 0 && len(mobiles) == 0 {
+		return users, nil
+	}
+	db := common.DB.Unscoped().Model(&model.User{})
+	if len(usernames) > 0 && len(mobiles) > 0 {
+		db = db.Where("username IN (?) OR mobile IN (?)", usernames, mobiles)
+	} else if len(usernames) > 0 {
+		db = db.Where("username IN (?)", usernames)
+	} else {
+		db = db.Where("mobile IN (?)", mobiles)
+	}
+	err := db.Find(&users).Error
+	return users, err
+}
+
+// 获取用户未拥有的且排序大于minSort的正常状态角色
+// minSort 当前用户角色排序最小值, 只返回当前用户可以分配的角色
+// 已过期的角色视为未拥有, 可以重新分配
+func (ur UserRepository) GetUserAvailableRoles(userId uint, minSort uint) ([]*model.Role, error) {
+	var user model.User
+	err := common.DB.Where("id = ?", userId).Preload("Roles").First(&user).Error
+	if err != nil {
+		return nil, err
+	}
+	if err := fillUserRolesExpiresAt(&user); err != nil {
+		return nil, err
+	}
+	userRoleIds := make([]uint, 0, len(user.Roles))
+	for _, role := range user.Roles {
+		userRoleIds = append(userRoleIds, role.ID)
+	}
+
+	var roles []*model.Role
+	db := common.DB.Where("status = ? AND sort > ?", 1, minSort)
+	if len(userRoleIds) > 0 {
+		db = db.Where("id NOT IN (?)", userRoleIds)
+	}
+	err = db.Order("sort").Find(&roles).Error
+	return roles, err
+}
+
+// 获取用户权限指纹(角色、接口权限、菜单的hash)
+// 输入排序后再计算, 权限未变化时指纹保持不变, 前端可据此判断是否需要重新加载菜单和权限
+func (ur UserRepository) GetUserPermissionFingerprint(user model.User) (string, error) {
+	// 用户全部未被禁用且未过期的角色, 开启角色继承时加上继承的角色
+	roles, err := activeEffectiveRoles(user.Roles)
+	if err != nil {
+		return "", err
+	}
+
+	items := make([]string, 0)
+	for _, role := range roles {
+		items = append(items, fmt.Sprintf("role:%d:%s", role.ID, role.Keyword))
+		for _, policy := range common.CasbinEnforcer.GetFilteredPolicy(0, role.Keyword) {
+			items = append(items, "policy:"+strings.Join(policy, ":"))
+		}
+	}
+	menus, err := MenuRepository{}.GetUserMenusByUserId(user.ID)
+	if err != nil {
+		return "", err
+	}
+	for _, menu := range menus {
+		items = append(items, fmt.Sprintf("menu:%d:%d", menu.ID, menu.UpdatedAt.UnixNano()))
+	}
+	sort.Strings(items)
+
+	hash := sha256.Sum256([]byte(strings.Join(items, "\n")))
+	return hex.EncodeToString(hash[:]), nil
+}
+
+// 删除指定用户的用户信息缓存, 返回缓存中存在并被删除的用户名
+func (ur UserRepository) EvictUserInfoCache(names []string) []string {
+	evicted := make([]string, 0)
+	usernames := make([]string, 0, len(names))
+	for _, name := range names {
+		usernames = append(usernames, util.NormalizeUsername(name))
+	}
+	usernames = funk.UniqString(usernames)
+	if len(usernames) == 0 {
+		return evicted
+	}
+	// 缓存key为用户ID, 先根据用户名获取用户ID
+	var users []model.User
+	if err := common.DB.Select("id, username").Where("username IN (?)", usernames).Find(&users).Error; err != nil {
+		common.Log.Errorf("根据用户名获取用户失败: %v", err)
+		return evicted
+	}
+	userIds := make([]uint, 0, len(users))
+	for _, user := range users {
+		if _, found := getUserInfoCache(user.ID); found {
+			evicted = append(evicted, user.Username)
+		}
+		userIds = append(userIds, user.ID)
+	}
+	// 其他节点的缓存同样删除
+	invalidateUserInfoCache(userIds...)
+	return evicted
+}
+
+// 长期未登录自动禁用的禁用原因
+const InactiveDisableReason = "长期未登录自动禁用"
+
+// 禁用长期未登录的用户, 返回被禁用的用户名
+// 从未登录的用户按创建时间计算, 跳过超级管理员(拥有排序为1的角色)和豁免的用户
+func (ur UserRepository) DisableInactiveUsers(inactiveDays uint, exemptUsernames []string) ([]string, error) {
+	disabled := make([]string, 0)
+	if inactiveDays == 0 {
+		return disabled, nil
+	}
+	threshold := time.Now().AddDate(0, 0, -int(inactiveDays))
+	var users []*model.User
+	err := common.DB.
+		Where("status = ? AND COALESCE(last_login_at, created_at) < ?", 1, threshold).
+		Preload("Roles").
+		Find(&users).Error
+	if err != nil {
+		return disabled, err
+	}
+	for _, user := range users {
+		if funk.ContainsString(exemptUsernames, user.Username) {
+			continue
+		}
+		isSuperAdmin := false
+		for _, role := range user.Roles {
+			if role.Sort == 1 {
+				isSuperAdmin = true
+				break
+			}
+		}
+		if isSuperAdmin {
+			continue
+		}
+		err := common.DB.Model(user).Updates(map[string]interface{}{
+			"status":         2,
+			"disable_reason": InactiveDisableReason,
+			"version":        gorm.Expr("version + 1"),
+		}).Error
+		if err != nil {
+			return disabled, err
+		}
+		invalidateUserInfoCache(user.ID)
+		disabled = append(disabled, user.Username)
+		common.Log.Infof("用户[%s]超过%d天未登录, 已自动禁用", user.Username, inactiveDays)
+	}
+	return disabled, nil
+}
+
+// 是否需要首次安装初始化(没有任何用户, 包括已删除的用户)
+func (ur UserRepository) NeedInitialSetup() (bool, error) {
+	var count int64
+	err := common.DB.Unscoped().Model(&model.User{}).Count(&count).Error
+	return count == 0, err
+}
+
+// 首次安装初始化, 创建超级管理员(拥有排序为1的角色)
+// 在事务中再次确认没有任何用户, 已有用户时初始化失败
+func (ur UserRepository) InitialSetup(user *model.User) error {
+	return common.DB.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Unscoped().Model(&model.User{}).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return errors.New("系统已初始化")
+		}
+		var adminRole model.Role
+		err := tx.Where("sort = ? AND status = ?", 1, 1).Order("id").Limit(1).Find(&adminRole).Error
+		if err != nil {
+			return err
+		}
+		if adminRole.ID == 0 {
+			return errors.New("未获取到超级管理员角色, 请先初始化基础数据")
+		}
+		user.Roles = []*model.Role{&adminRole}
+		return tx.Create(user).Error
+	})
+}
+
+// 用户数据(包括角色)修改后版本号加1, 修改之前读取的数据不能再用于更新用户(乐观锁)
+// 包括已删除的用户, 恢复后同样需要重新读取
+func increaseUserVersion(tx *gorm.DB, ids ...uint) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return tx.Unscoped().Model(&model.User{}).Where("id IN (?)", ids).Update("version", gorm.Expr("version + 1")).Error
 }
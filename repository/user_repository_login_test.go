@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"go-web-mini/common"
+	"go-web-mini/model"
+	"go-web-mini/testutil"
+	"go-web-mini/util"
+	"testing"
+)
+
+// 创建测试角色
+func createTestRole(t *testing.T, keyword string, sort uint) *model.Role {
+	t.Helper()
+	return testutil.CreateRole(t, keyword, sort)
+}
+
+// 创建可以登录的测试用户
+func createTestLoginUser(t *testing.T, username string, passwd string, role *model.Role) *model.User {
+	t.Helper()
+	return testutil.CreateUser(t, username, passwd, role)
+}
+
+func TestLoginUsesLatestPassword(t *testing.T) {
+	setupTestDB(t)
+	ur := UserRepository{}
+	user := createTestLoginUser(t, "alice", "old-passwd", createTestRole(t, "user", 3))
+
+	// 登录后缓存中是旧密码
+	if _, err := ur.Login(&model.User{Username: "alice", Password: "old-passwd"}); err != nil {
+		t.Fatalf("登录失败: %v", err)
+	}
+	// 其他节点修改了密码, 本节点缓存未删除
+	common.DB.Model(user).UpdateColumn("password", util.GenPasswd("new-passwd"))
+
+	if _, err := ur.Login(&model.User{Username: "alice", Password: "new-passwd"}); err != nil {
+		t.Errorf("使用新密码登录失败: %v", err)
+	}
+	if _, err := ur.Login(&model.User{Username: "alice", Password: "old-passwd"}); err == nil {
+		t.Error("使用旧密码登录成功, 期望失败")
+	}
+}
+
+func TestLoginUsesLatestStatus(t *testing.T) {
+	setupTestDB(t)
+	ur := UserRepository{}
+	user := createTestLoginUser(t, "alice", "passwd", createTestRole(t, "user", 3))
+
+	if _, err := ur.Login(&model.User{Username: "alice", Password: "passwd"}); err != nil {
+		t.Fatalf("登录失败: %v", err)
+	}
+	common.DB.Model(user).UpdateColumn("status", 2)
+
+	if _, err := ur.Login(&model.User{Username: "alice", Password: "passwd"}); err == nil || err.Error() != "用户被禁用" {
+		t.Errorf("被禁用的用户登录返回%v, 期望为用户被禁用", err)
+	}
+}
+
+func TestLoginIgnoresUsernameCase(t *testing.T) {
+	setupTestDB(t)
+	ur := UserRepository{}
+	createTestLoginUser(t, "alice", "passwd", createTestRole(t, "user", 3))
+
+	for _, username := range []string{"alice", "Alice", "ALICE", " alice "} {
+		user, err := ur.Login(&model.User{Username: username, Password: "passwd"})
+		if err != nil {
+			t.Errorf("使用用户名%q登录失败: %v", username, err)
+			continue
+		}
+		if user.Username != "alice" {
+			t.Errorf("使用用户名%q登录的用户为%s, 期望为alice", username, user.Username)
+		}
+	}
+}
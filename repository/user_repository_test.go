@@ -0,0 +1,316 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-web-mini/common"
+	"go-web-mini/model"
+	"go-web-mini/testutil"
+	"go-web-mini/vo"
+	"gorm.io/gorm"
+	"testing"
+	"time"
+)
+
+func TestGetUserMinRoleSortsByIdsNotFound(t *testing.T) {
+	setupTestDB(t)
+	ur := UserRepository{}
+
+	sorts, err := ur.GetUserMinRoleSortsByIds([]uint{1})
+	if err != nil || len(sorts) != 0 {
+		t.Errorf("用户不存在时返回%v, %v, 期望为空列表", sorts, err)
+	}
+}
+
+func TestCountUsersMatchesGetUsers(t *testing.T) {
+	setupTestDB(t)
+	ur := UserRepository{}
+	admin := createTestRole(t, "admin", 1)
+	user := createTestRole(t, "user", 3)
+	for i := 1; i <= 7; i++ {
+		role := user
+		if i%3 == 0 {
+			role = admin
+		}
+		u := createTestLoginUser(t, fmt.Sprintf("user%d", i), "passwd", role)
+		if i%2 == 0 {
+			common.DB.Model(u).UpdateColumn("status", 2)
+		}
+	}
+	createTestLoginUser(t, "other", "passwd", user)
+
+	requests := map[string]vo.UserListRequest{
+		"不过滤":    {},
+		"按状态过滤":  {Status: 1},
+		"按用户名过滤": {Username: "user"},
+		"按角色过滤":  {RoleId: admin.ID},
+		"组合过滤":   {Username: "user", Status: 2, RoleName: "user"},
+	}
+	for name, req := range requests {
+		count, err := ur.CountUsers(context.Background(), &req)
+		if err != nil {
+			t.Fatalf("%s: 获取用户数量失败: %v", name, err)
+		}
+		fetched := make(map[uint]bool)
+		for pageNum := uint(1); ; pageNum++ {
+			req.PageNum, req.PageSize = pageNum, 3
+			list, total, err := ur.GetUsers(context.Background(), &req)
+			if err != nil {
+				t.Fatalf("%s: 获取用户列表失败: %v", name, err)
+			}
+			if total != count {
+				t.Errorf("%s: 第%d页的总数为%d, 用户数量为%d", name, pageNum, total, count)
+			}
+			if len(list) == 0 {
+				break
+			}
+			for _, u := range list {
+				fetched[u.ID] = true
+			}
+		}
+		if int64(len(fetched)) != count {
+			t.Errorf("%s: 分页获取到%d个用户, 用户数量为%d", name, len(fetched), count)
+		}
+	}
+}
+
+// 统计获取用户列表执行的查询次数
+func countGetUsersQueries(t *testing.T, req *vo.UserListRequest) (int, []*model.User) {
+	count := 0
+	name := fmt.Sprintf("test:count_queries_%s", t.Name())
+	if err := common.DB.Callback().Query().After("gorm:query").Register(name, func(*gorm.DB) { count++ }); err != nil {
+		t.Fatalf("注册查询回调失败: %v", err)
+	}
+	defer common.DB.Callback().Query().Remove(name)
+
+	users, _, err := UserRepository{}.GetUsers(context.Background(), req)
+	if err != nil {
+		t.Fatalf("获取用户列表失败: %v", err)
+	}
+	return count, users
+}
+
+func TestGetUsersQueryCountIndependentOfUsers(t *testing.T) {
+	setupTestDB(t)
+	admin := createTestRole(t, "admin", 1)
+	guest := createTestRole(t, "guest", 5)
+	req := &vo.UserListRequest{PageNum: 1, PageSize: 20}
+
+	createTestLoginUser(t, "user0", "passwd", admin)
+	single, _ := countGetUsersQueries(t, req)
+
+	for i := 1; i < 10; i++ {
+		testutil.CreateUser(t, fmt.Sprintf("user%d", i), "passwd", admin, guest)
+	}
+	many, users := countGetUsersQueries(t, req)
+	if many != single {
+		t.Errorf("10个用户时执行了%d次查询, 1个用户时为%d次, 查询次数不应随用户数量增加", many, single)
+	}
+	if len(users) != 10 {
+		t.Fatalf("获取到%d个用户, 期望为10个", len(users))
+	}
+	for _, user := range users {
+		if len(user.Roles) == 0 {
+			t.Errorf("用户%s未加载角色", user.Username)
+		}
+	}
+}
+
+func TestGetUserByIdReturnsDisabledUser(t *testing.T) {
+	setupTestDB(t)
+	ur := UserRepository{}
+	role := createTestRole(t, "user", 3)
+	bob := createTestLoginUser(t, "bob", "passwd", role)
+	common.DB.Model(bob).Updates(map[string]interface{}{"status": 2, "disable_reason": "离职"})
+
+	// 管理员可以获取被禁用的用户以重新启用
+	user, err := ur.GetUserById(bob.ID)
+	if err != nil || user.Status != 2 {
+		t.Fatalf("获取被禁用的用户返回%v, %v", user.Status, err)
+	}
+	// 需要正常状态的用户时获取不到被禁用的用户
+	if _, err := ur.GetActiveUserById(bob.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("获取被禁用的正常状态用户返回%v, 期望为记录不存在", err)
+	}
+	// 当前用户同样不过滤状态, 由调用方校验状态并返回禁用原因
+	current, err := ur.GetCurrentUser(newTestUserContext(*bob))
+	if err != nil || current.Status != 2 || current.DisableReason != "离职" {
+		t.Errorf("获取被禁用的当前用户返回%v(%s), %v", current.Status, current.DisableReason, err)
+	}
+	if _, err := ur.GetRefreshTokenUser(bob.ID); err == nil || err.Error() != "用户被禁用: 离职" {
+		t.Errorf("被禁用的用户刷新token返回%v, 期望为用户被禁用: 离职", err)
+	}
+}
+
+func TestUserListFilterEscapesWildcards(t *testing.T) {
+	setupTestDB(t)
+	ur := UserRepository{}
+	literal := createTestRole(t, "r_1", 3)
+	other := createTestRole(t, "rx1", 4)
+	testutil.CreateUser(t, "a_b", "passwd", literal)
+	testutil.CreateUser(t, "axb", "passwd", other)
+	testutil.CreateUser(t, "c!%d", "passwd", other)
+	testutil.CreateUser(t, "cxd", "passwd", other)
+
+	tests := []struct {
+		req  vo.UserListRequest
+		want string
+	}{
+		{vo.UserListRequest{Username: "a_b"}, "a_b"},
+		{vo.UserListRequest{Username: "!%"}, "c!%d"},
+		{vo.UserListRequest{RoleName: "r_1"}, "a_b"},
+	}
+	for _, tt := range tests {
+		users, total, err := ur.GetUsers(context.Background(), &tt.req)
+		if err != nil {
+			t.Fatalf("获取用户列表失败: %v", err)
+		}
+		if total != 1 || len(users) != 1 || users[0].Username != tt.want {
+			names := make([]string, 0, len(users))
+			for _, user := range users {
+				names = append(names, user.Username)
+			}
+			t.Errorf("查询条件%+v返回%v(总数%d), 期望只有%s", tt.req, names, total, tt.want)
+		}
+	}
+}
+
+func TestGetUsersPagesWithTiedSortValues(t *testing.T) {
+	setupTestDB(t)
+	ur := UserRepository{}
+	role := createTestRole(t, "user", 3)
+	// 状态全部相同, 只能按ID区分顺序
+	for i := 0; i < 7; i++ {
+		testutil.CreateUser(t, fmt.Sprintf("user%d", i), "passwd", role)
+	}
+
+	for _, order := range []string{"asc", "desc"} {
+		seen := make(map[uint]bool)
+		var lastId uint
+		for pageNum := uint(1); pageNum <= 3; pageNum++ {
+			req := &vo.UserListRequest{OrderBy: "status", Order: order, PageNum: pageNum, PageSize: 3}
+			users, _, err := ur.GetUsers(context.Background(), req)
+			if err != nil {
+				t.Fatalf("获取用户列表失败: %v", err)
+			}
+			for _, user := range users {
+				if seen[user.ID] {
+					t.Errorf("按status %s排序时用户%d在多页中重复出现", order, user.ID)
+				}
+				if lastId != 0 && (order == "asc") != (user.ID > lastId) {
+					t.Errorf("按status %s排序时用户%d排在用户%d之后", order, user.ID, lastId)
+				}
+				seen[user.ID] = true
+				lastId = user.ID
+			}
+		}
+		if len(seen) != 7 {
+			t.Errorf("按status %s排序分页获取到%d个用户, 期望为7个", order, len(seen))
+		}
+	}
+}
+
+func TestMergeUsersSkipsExpiredRoles(t *testing.T) {
+	setupTestDB(t)
+	ur := UserRepository{}
+	admin := createTestRole(t, "admin", 1)
+	manager := createTestRole(t, "manager", 2)
+	user := createTestRole(t, "user", 3)
+	alice := testutil.CreateUser(t, "alice", "passwd", admin, manager)
+	bob := createTestLoginUser(t, "bob", "passwd", user)
+	// alice的admin角色已过期, manager角色将在一小时后过期
+	expiredAt := time.Now().Add(-time.Hour)
+	expiresAt := time.Now().Add(time.Hour)
+	common.DB.Model(&model.UserRole{}).Where("user_id = ? AND role_id = ?", alice.ID, admin.ID).Update("expires_at", expiredAt)
+	common.DB.Model(&model.UserRole{}).Where("user_id = ? AND role_id = ?", alice.ID, manager.ID).Update("expires_at", expiresAt)
+
+	result, err := ur.MergeUsers(alice.ID, bob.ID)
+	if err != nil {
+		t.Fatalf("合并用户失败: %v", err)
+	}
+	if len(result.AddedRoleIds) != 1 || result.AddedRoleIds[0] != manager.ID {
+		t.Errorf("合并后新增的角色为%v, 期望只有manager", result.AddedRoleIds)
+	}
+	var userRoles []model.UserRole
+	common.DB.Where("user_id = ?", bob.ID).Find(&userRoles)
+	for _, userRole := range userRoles {
+		switch userRole.RoleId {
+		case admin.ID:
+			t.Error("已过期的admin角色被合并到目标用户")
+		case manager.ID:
+			if userRole.ExpiresAt == nil || !userRole.ExpiresAt.Equal(expiresAt) {
+				t.Errorf("合并后manager角色的过期时间为%v, 期望为%v", userRole.ExpiresAt, expiresAt)
+			}
+		}
+	}
+}
+
+func TestGetCurrentUserMinRoleSortWithExpiredRoles(t *testing.T) {
+	setupTestDB(t)
+	ur := UserRepository{}
+	admin := createTestRole(t, "admin", 1)
+	alice := createTestLoginUser(t, "alice", "passwd", admin)
+	// alice唯一的角色已过期
+	common.DB.Model(&model.UserRole{}).Where("user_id = ? AND role_id = ?", alice.ID, admin.ID).Update("expires_at", time.Now().Add(-time.Hour))
+
+	minSort, _, err := ur.GetCurrentUserMinRoleSort(newTestUserContext(*alice))
+	if err != nil || minSort != 999 {
+		t.Errorf("没有有效角色时返回%d, %v, 期望为999", minSort, err)
+	}
+}
+
+func TestGetUsersByRoleIdCancelled(t *testing.T) {
+	setupTestDB(t)
+	ur := UserRepository{}
+	role := createTestRole(t, "user", 3)
+	createTestLoginUser(t, "bob", "passwd", role)
+
+	// 客户端断开连接后不再查询
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, _, err := ur.GetUsersByRoleId(ctx, role.ID, &vo.UserListRequest{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("请求上下文已取消时返回%v, 期望为context.Canceled", err)
+	}
+}
+
+func TestExpiredRoleCanBeGrantedAgain(t *testing.T) {
+	setupTestDB(t)
+	ur := UserRepository{}
+	manager := createTestRole(t, "manager", 2)
+	user := createTestRole(t, "user", 3)
+	alice := testutil.CreateUser(t, "alice", "passwd", manager, user)
+	bob := testutil.CreateUser(t, "bob", "passwd", manager, user)
+	expiredAt := time.Now().Add(-time.Hour)
+	common.DB.Model(&model.UserRole{}).Where("role_id = ?", manager.ID).Update("expires_at", expiredAt)
+
+	// 已过期的角色出现在可添加的角色中
+	roles, err := ur.GetUserAvailableRoles(alice.ID, 1)
+	if err != nil {
+		t.Fatalf("获取用户可添加的角色失败: %v", err)
+	}
+	if len(roles) != 1 || roles[0].ID != manager.ID {
+		t.Fatalf("用户可添加的角色为%v, 期望为已过期的manager", roles)
+	}
+
+	// 更新用户时重新分配
+	current, err := ur.GetUserById(alice.ID)
+	if err != nil {
+		t.Fatalf("获取用户失败: %v", err)
+	}
+	current.Roles = []*model.Role{manager, user}
+	if err := ur.UpdateUser(&current); err != nil {
+		t.Fatalf("更新用户失败: %v", err)
+	}
+	// 批量分配时重新分配
+	if added, err := ur.BatchAssignRole(manager.ID, []uint{bob.ID}); err != nil || added != 1 {
+		t.Fatalf("批量分配角色返回%d, %v, 期望新增1个", added, err)
+	}
+	for _, userId := range []uint{alice.ID, bob.ID} {
+		var userRole model.UserRole
+		common.DB.Where("user_id = ? AND role_id = ?", userId, manager.ID).First(&userRole)
+		if userRole.ExpiresAt != nil {
+			t.Errorf("用户%d重新分配的manager角色过期时间为%v, 期望为永不过期", userId, userRole.ExpiresAt)
+		}
+	}
+}
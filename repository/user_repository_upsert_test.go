@@ -0,0 +1,139 @@
+package repository
+
+import (
+	"go-web-mini/common"
+	"go-web-mini/model"
+	"testing"
+)
+
+// 创建测试用户
+func createTestUser(t *testing.T, username string, mobile string) *model.User {
+	t.Helper()
+	user := &model.User{Username: username, Password: "hash-" + username, Mobile: mobile, Status: 1}
+	if err := common.DB.Create(user).Error; err != nil {
+		t.Fatalf("创建测试用户失败: %v", err)
+	}
+	return user
+}
+
+// 从数据库获取用户(包括已删除的用户)
+func loadTestUser(t *testing.T, id uint) model.User {
+	t.Helper()
+	var user model.User
+	if err := common.DB.Unscoped().First(&user, id).Error; err != nil {
+		t.Fatalf("获取测试用户失败: %v", err)
+	}
+	return user
+}
+
+func TestUpsertUsersInsert(t *testing.T) {
+	setupTestDB(t)
+	ur := UserRepository{}
+
+	err := ur.UpsertUsers([]model.User{
+		{Username: "Alice", Password: "hash-alice", Mobile: "13800000001", Status: 1},
+		{Username: "bob", Password: "hash-bob", Mobile: "13800000002", Status: 1},
+	})
+	if err != nil {
+		t.Fatalf("新增用户失败: %v", err)
+	}
+
+	var users []model.User
+	common.DB.Order("id").Find(&users)
+	if len(users) != 2 {
+		t.Fatalf("用户数量为%d, 期望为2", len(users))
+	}
+	if users[0].Username != "alice" {
+		t.Errorf("用户名为%s, 期望规范化为alice", users[0].Username)
+	}
+	if users[1].Password != "hash-bob" {
+		t.Errorf("新增用户的密码为%s, 期望为hash-bob", users[1].Password)
+	}
+}
+
+func TestUpsertUsersUpdate(t *testing.T) {
+	setupTestDB(t)
+	ur := UserRepository{}
+	alice := createTestUser(t, "alice", "13800000001")
+	bob := createTestUser(t, "bob", "13800000002")
+
+	nickname := "Alice"
+	err := ur.UpsertUsers([]model.User{
+		// 未提供密码, 不覆盖原密码
+		{Username: "ALICE", Mobile: "13800000011", Nickname: &nickname, Status: 2},
+		// 提供了密码, 同时更新密码
+		{Username: "bob", Password: "new-hash-bob", Mobile: "13800000002", Status: 1},
+	})
+	if err != nil {
+		t.Fatalf("更新用户失败: %v", err)
+	}
+
+	var count int64
+	common.DB.Model(&model.User{}).Count(&count)
+	if count != 2 {
+		t.Fatalf("用户数量为%d, 期望为2(不新增用户)", count)
+	}
+	gotAlice := loadTestUser(t, alice.ID)
+	if gotAlice.Password != "hash-alice" {
+		t.Errorf("未提供密码时覆盖了原密码: %s", gotAlice.Password)
+	}
+	if gotAlice.Mobile != "13800000011" || gotAlice.Status != 2 || gotAlice.Nickname == nil || *gotAlice.Nickname != "Alice" {
+		t.Errorf("用户信息未更新: %+v", gotAlice)
+	}
+	if gotAlice.Version != alice.Version+1 {
+		t.Errorf("版本号为%d, 期望为%d", gotAlice.Version, alice.Version+1)
+	}
+	gotBob := loadTestUser(t, bob.ID)
+	if gotBob.Password != "new-hash-bob" {
+		t.Errorf("提供密码时未更新密码: %s", gotBob.Password)
+	}
+	if gotBob.Version != bob.Version+1 {
+		t.Errorf("版本号为%d, 期望为%d", gotBob.Version, bob.Version+1)
+	}
+}
+
+func TestUpsertUsersRejectsMobileOfOtherUser(t *testing.T) {
+	setupTestDB(t)
+	ur := UserRepository{}
+	alice := createTestUser(t, "alice", "13800000001")
+	createTestUser(t, "bob", "13800000002")
+
+	err := ur.UpsertUsers([]model.User{
+		{Username: "carol", Password: "hash-carol", Mobile: "13800000003", Status: 1},
+		// 手机号属于alice, 不能覆盖alice
+		{Username: "bob", Password: "new-hash-bob", Mobile: "13800000001", Status: 1},
+	})
+	if err == nil {
+		t.Fatal("手机号属于其他用户时期望返回错误")
+	}
+
+	got := loadTestUser(t, alice.ID)
+	if got.Username != "alice" || got.Password != "hash-alice" || got.Version != alice.Version {
+		t.Errorf("其他用户被修改: %+v", got)
+	}
+	var count int64
+	common.DB.Model(&model.User{}).Count(&count)
+	if count != 2 {
+		t.Errorf("用户数量为%d, 校验失败时期望整批不新增", count)
+	}
+}
+
+func TestUpsertUsersRejectsDuplicatesInBatch(t *testing.T) {
+	setupTestDB(t)
+	ur := UserRepository{}
+
+	err := ur.UpsertUsers([]model.User{
+		{Username: "alice", Mobile: "13800000001"},
+		{Username: "Alice", Mobile: "13800000002"},
+	})
+	if err == nil {
+		t.Error("用户名重复(不区分大小写)时期望返回错误")
+	}
+	err = ur.UpsertUsers([]model.User{
+		{Username: "alice", Mobile: "13800000001"},
+		{Username: "bob", Mobile: "13800000001"},
+	})
+	if err == nil {
+		t.Error("手机号重复时期望返回错误")
+	}
+}
@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"go-web-mini/common"
+	"go-web-mini/model"
+	"testing"
+	"time"
+)
+
+func TestUpdateUserRejectsStaleVersion(t *testing.T) {
+	setupTestDB(t)
+	ur := UserRepository{}
+	role := createTestRole(t, "user", 3)
+	alice := createTestLoginUser(t, "alice", "passwd", role)
+
+	// 编辑前读取的用户
+	stale := loadTestUser(t, alice.ID)
+	// 编辑期间管理员重置了密码
+	if err := ur.ResetPassword(alice.ID, "new-hash"); err != nil {
+		t.Fatalf("重置密码失败: %v", err)
+	}
+
+	nickname := "Alice"
+	stale.Nickname = &nickname
+	stale.Roles = []*model.Role{role}
+	if err := ur.UpdateUser(&stale); err == nil || err.Error() != "数据已被他人修改，请刷新后重试" {
+		t.Errorf("使用过期的版本号更新用户返回%v, 期望提示数据已被他人修改", err)
+	}
+	if got := loadTestUser(t, alice.ID); *got.Nickname != "" {
+		t.Errorf("使用过期的版本号更新了用户, 昵称为%s", *got.Nickname)
+	}
+}
+
+func TestWritePathsIncreaseVersion(t *testing.T) {
+	setupTestDB(t)
+	ur := UserRepository{}
+	role := createTestRole(t, "user", 3)
+	other := createTestRole(t, "other", 4)
+	alice := createTestLoginUser(t, "alice", "passwd", role)
+
+	cases := []struct {
+		name  string
+		write func() error
+	}{
+		{"修改密码", func() error { return ur.ChangePwd("alice", "hash-1") }},
+		{"重置密码", func() error { return ur.ResetPassword(alice.ID, "hash-2") }},
+		{"更新两步验证", func() error { return ur.UpdateTwoFactor(alice.ID, "secret", true) }},
+		{"更新角色过期时间", func() error {
+			expiresAt := time.Now().Add(time.Hour)
+			return ur.UpdateUserRoleExpiresAt(alice.ID, role.ID, &expiresAt)
+		}},
+		{"分配角色", func() error {
+			_, err := ur.BatchAssignRole(other.ID, []uint{alice.ID})
+			return err
+		}},
+		{"删除用户", func() error { return ur.BatchDeleteUserByIds([]uint{alice.ID}) }},
+		{"恢复用户", func() error { return ur.RestoreUsers([]uint{alice.ID}) }},
+		{"禁用长期未登录的用户", func() error {
+			common.DB.Model(alice).UpdateColumn("last_login_at", time.Now().AddDate(0, 0, -10))
+			disabled, err := ur.DisableInactiveUsers(5, nil)
+			if err == nil && len(disabled) != 1 {
+				t.Errorf("禁用了%d个用户, 期望为1", len(disabled))
+			}
+			return err
+		}},
+	}
+	for _, tc := range cases {
+		before := loadTestUser(t, alice.ID).Version
+		if err := tc.write(); err != nil {
+			t.Fatalf("%s失败: %v", tc.name, err)
+		}
+		if after := loadTestUser(t, alice.ID).Version; after != before+1 {
+			t.Errorf("%s后版本号为%d, 期望为%d", tc.name, after, before+1)
+		}
+	}
+
+	// 已拥有该角色时不修改用户
+	before := loadTestUser(t, alice.ID).Version
+	if _, err := ur.BatchAssignRole(other.ID, []uint{alice.ID}); err != nil {
+		t.Fatalf("分配角色失败: %v", err)
+	}
+	if after := loadTestUser(t, alice.ID).Version; after != before {
+		t.Errorf("重复分配角色后版本号为%d, 期望不变(%d)", after, before)
+	}
+}
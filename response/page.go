@@ -0,0 +1,74 @@
+package response
+
+import (
+	"github.com/gin-gonic/gin"
+	"go-web-mini/config"
+	"strconv"
+)
+
+// 列表接口的分页信息
+type PageData struct {
+	Total      int64      `json:"total"`
+	PageNum    int        `json:"pageNum"`
+	PageSize   int        `json:"pageSize"`
+	TotalPages int        `json:"totalPages"` // 总页数, 不分页时为0
+	Links      *PageLinks `json:"links,omitempty"`
+}
+
+// 分页链接(在当前请求的查询参数基础上替换pageNum), 第一页没有prev, 最后一页没有next
+type PageLinks struct {
+	First string `json:"first"`
+	Prev  string `json:"prev,omitempty"`
+	Next  string `json:"next,omitempty"`
+	Last  string `json:"last"`
+}
+
+// 生成分页信息, 不分页(pageNum或pageSize小于等于0)时总页数为0且没有分页链接
+func NewPageData(c *gin.Context, total int64, pageNum int, pageSize int) *PageData {
+	page := &PageData{Total: total, PageNum: pageNum, PageSize: pageSize}
+	if pageNum <= 0 || pageSize <= 0 {
+		return page
+	}
+	page.TotalPages = int((total + int64(pageSize) - 1) / int64(pageSize))
+	if !config.Conf.System.PaginationLinks {
+		return page
+	}
+	// 没有数据时最后一页为第一页
+	lastPage := page.TotalPages
+	if lastPage < 1 {
+		lastPage = 1
+	}
+	page.Links = &PageLinks{
+		First: pageLink(c, 1),
+		Last:  pageLink(c, lastPage),
+	}
+	if pageNum > 1 {
+		page.Links.Prev = pageLink(c, minInt(pageNum-1, lastPage))
+	}
+	if pageNum < lastPage {
+		page.Links.Next = pageLink(c, pageNum+1)
+	}
+	return page
+}
+
+// 返回前端-分页列表成功
+// 返回数据为{key: 列表, "total": 总数, "pagination": 分页信息}, key为列表名称(如users、roles), 列表接口统一使用
+func SuccessPage(c *gin.Context, key string, list interface{}, page *PageData, message string) {
+	SuccessList(c, gin.H{key: list, "total": page.Total, "pagination": page}, message)
+}
+
+// 替换当前请求查询参数中的pageNum
+func pageLink(c *gin.Context, pageNum int) string {
+	u := *c.Request.URL
+	query := u.Query()
+	query.Set("pageNum", strconv.Itoa(pageNum))
+	u.RawQuery = query.Encode()
+	return u.RequestURI()
+}
+
+func minInt(a int, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
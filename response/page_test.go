@@ -0,0 +1,89 @@
+package response
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"go-web-mini/config"
+	"net/http/httptest"
+	"testing"
+)
+
+// 设置是否返回分页链接, 测试结束后恢复配置
+func setPaginationLinks(t *testing.T, enabled bool) {
+	system := config.Conf.System
+	config.Conf.System = &config.SystemConfig{PaginationLinks: enabled}
+	t.Cleanup(func() { config.Conf.System = system })
+}
+
+func TestNewPageDataTotalPages(t *testing.T) {
+	setPaginationLinks(t, false)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	tests := []struct {
+		total    int64
+		pageNum  int
+		pageSize int
+		want     int
+	}{
+		{0, 1, 10, 0},
+		{1, 1, 10, 1},
+		{10, 1, 10, 1},
+		{11, 2, 10, 2},
+		// 不分页时总页数为0
+		{11, 0, 10, 0},
+		{11, 1, 0, 0},
+	}
+	for _, tt := range tests {
+		page := NewPageData(c, tt.total, tt.pageNum, tt.pageSize)
+		if page.TotalPages != tt.want {
+			t.Errorf("total=%d pageNum=%d pageSize=%d 时总页数为%d, 期望为%d", tt.total, tt.pageNum, tt.pageSize, page.TotalPages, tt.want)
+		}
+	}
+}
+
+func TestNewPageDataLinks(t *testing.T) {
+	setPaginationLinks(t, true)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("GET", "/api/user/list?pageNum=2&pageSize=10&status=1", nil)
+
+	page := NewPageData(c, 25, 2, 10)
+	if page.Links == nil {
+		t.Fatal("开启分页链接时没有返回分页链接")
+	}
+	want := PageLinks{
+		First: "/api/user/list?pageNum=1&pageSize=10&status=1",
+		Prev:  "/api/user/list?pageNum=1&pageSize=10&status=1",
+		Next:  "/api/user/list?pageNum=3&pageSize=10&status=1",
+		Last:  "/api/user/list?pageNum=3&pageSize=10&status=1",
+	}
+	if *page.Links != want {
+		t.Errorf("分页链接为%+v, 期望为%+v", *page.Links, want)
+	}
+	// 没有数据时只有第一页
+	empty := NewPageData(c, 0, 1, 10)
+	if empty.TotalPages != 0 || empty.Links.Last != empty.Links.First || empty.Links.Next != "" {
+		t.Errorf("没有数据时的分页信息为%+v, %+v", empty, *empty.Links)
+	}
+}
+
+func TestSuccessPageUsesListKey(t *testing.T) {
+	setPaginationLinks(t, false)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("GET", "/api/user/list?pageNum=1&pageSize=10", nil)
+
+	SuccessPage(c, "users", []string{"alice"}, NewPageData(c, 1, 1, 10), "获取用户列表成功")
+	var resp struct {
+		Data map[string]json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("解析返回数据失败: %v", err)
+	}
+	for _, key := range []string{"users", "total", "pagination"} {
+		if _, ok := resp.Data[key]; !ok {
+			t.Errorf("返回数据%s中没有%s", w.Body.String(), key)
+		}
+	}
+	if _, ok := resp.Data["list"]; ok {
+		t.Errorf("返回数据%s中不应有list", w.Body.String())
+	}
+}
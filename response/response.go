@@ -1,13 +1,23 @@
 package response
 
 import (
+	"encoding/json"
+	"fmt"
 	"github.com/gin-gonic/gin"
+	"go-web-mini/common"
+	"go-web-mini/config"
+	"go-web-mini/dto"
 	"net/http"
 )
 
-// 返回前端
+// 返回前端, 返回数据中带有请求ID
 func Response(c *gin.Context, httpStatus int, code int, data gin.H, message string) {
-	c.JSON(httpStatus, gin.H{"code": code, "data": data, "message": message})
+	c.JSON(httpStatus, gin.H{"code": code, "data": FilterUserData(c, data), "message": message, "requestId": common.GetRequestId(c)})
+}
+
+// 集成方请求按API Key允许的字段过滤返回数据中的用户数据, 所有返回用户数据的接口统一在这里过滤
+func FilterUserData(c *gin.Context, data interface{}) interface{} {
+	return dto.FilterUserData(data, common.GetApiKeyUserFields(c))
 }
 
 // 返回前端-成功
@@ -15,7 +25,52 @@ func Success(c *gin.Context, data gin.H, message string) {
 	Response(c, http.StatusOK, 200, data, message)
 }
 
-// 返回前端-失败
+// 返回前端-失败并指定HTTP状态码, 返回数据中的code与HTTP状态码一致
+func FailWithCode(c *gin.Context, httpStatus int, data gin.H, message string) {
+	Response(c, httpStatus, httpStatus, data, message)
+}
+
+// 返回前端-失败(参数绑定、校验失败等请求有误的情况)
 func Fail(c *gin.Context, data gin.H, message string) {
-	Response(c, http.StatusBadRequest, 400, data, message)
+	FailWithCode(c, http.StatusBadRequest, data, message)
+}
+
+// 返回前端-未登录或认证失败
+func Unauthorized(c *gin.Context, data gin.H, message string) {
+	FailWithCode(c, http.StatusUnauthorized, data, message)
+}
+
+// 返回前端-没有权限(已登录但无权操作, 如接口权限、角色等级不足)
+func Forbidden(c *gin.Context, data gin.H, message string) {
+	FailWithCode(c, http.StatusForbidden, data, message)
+}
+
+// 返回前端-服务端错误(数据库操作失败等)
+func ServerError(c *gin.Context, data gin.H, message string) {
+	FailWithCode(c, http.StatusInternalServerError, data, message)
+}
+
+// 返回前端-列表成功
+// 返回数据超过配置的大小上限时, 根据配置记录警告或返回错误
+func SuccessList(c *gin.Context, data gin.H, message string) {
+	maxBytes := config.Conf.System.ListResponseMaxBytes
+	if maxBytes <= 0 {
+		Success(c, data, message)
+		return
+	}
+	body, err := json.Marshal(gin.H{"code": 200, "data": FilterUserData(c, data), "message": message, "requestId": common.GetRequestId(c)})
+	if err != nil {
+		Success(c, data, message)
+		return
+	}
+	if len(body) > maxBytes {
+		common.Log.Warnf("列表接口返回数据过大: %s %s, 大小: %d字节, 上限: %d字节", c.Request.Method, c.FullPath(), len(body), maxBytes)
+		if config.Conf.System.ListResponseSizeMode == "reject" {
+			Fail(c, gin.H{"size": len(body), "maxSize": maxBytes}, "返回数据过大, 请减小分页大小或增加查询条件")
+			return
+		}
+		c.Header("X-Response-Size-Warning", fmt.Sprintf("%d bytes exceeds %d", len(body), maxBytes))
+	}
+	// 已序列化过, 直接返回避免重复序列化
+	c.Data(http.StatusOK, "application/json; charset=utf-8", body)
 }
@@ -11,7 +11,9 @@ func InitApiRoutes(r *gin.RouterGroup, authMiddleware *jwt.GinJWTMiddleware) gin
 	apiController := controller.NewApiController()
 	router := r.Group("/api")
 	// 开启jwt认证中间件
-	router.Use(authMiddleware.MiddlewareFunc())
+	router.Use(middleware.JwtMiddleware(authMiddleware))
+	// 开启只读模式中间件
+	router.Use(middleware.ReadOnlyMiddleware())
 	// 开启casbin鉴权中间件
 	router.Use(middleware.CasbinMiddleware())
 	{
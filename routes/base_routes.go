@@ -3,16 +3,37 @@ package routes
 import (
 	jwt "github.com/appleboy/gin-jwt/v2"
 	"github.com/gin-gonic/gin"
+	"go-web-mini/config"
+	"go-web-mini/controller"
+	"go-web-mini/middleware"
 )
 
 // 注册基础路由
 func InitBaseRoutes(r *gin.RouterGroup, authMiddleware *jwt.GinJWTMiddleware) gin.IRoutes {
+	userController := controller.NewUserController()
+	baseController := controller.NewBaseController()
 	router := r.Group("/base")
+	// 开启只读模式中间件(不能首次安装初始化)
+	router.Use(middleware.ReadOnlyMiddleware())
 	{
 		// 登录登出刷新token无需鉴权
-		router.POST("/login", authMiddleware.LoginHandler)
-		router.POST("/logout", authMiddleware.LogoutHandler)
-		router.POST("/refreshToken", authMiddleware.RefreshHandler)
+		router.POST("/login", middleware.KeyedRateLimitMiddleware("login"), authMiddleware.LoginHandler)
+		// 两步验证登录(登录第二步), 提交登录返回的挑战和验证码
+		router.POST("/login/2fa", middleware.KeyedRateLimitMiddleware("two-factor"), middleware.TwoFactorLoginStep(), authMiddleware.LoginHandler)
+		router.POST("/logout", middleware.RevokeTokenCheck(authMiddleware), authMiddleware.LogoutHandler)
+		router.POST("/refreshToken", middleware.RefreshTokenCheck(authMiddleware), authMiddleware.RefreshHandler)
+
+		// 检测密码强度无需鉴权(注册时使用)
+		router.POST("/password/strength", userController.CheckPasswordStrength)
+
+		// 首次安装初始化, 没有任何用户时创建超级管理员
+		router.GET("/setup/status", userController.GetInitialSetupStatus)
+		router.POST("/setup", userController.InitialSetup)
+
+		// 获取服务器时间
+		if config.Conf.Jwt.ServerTime {
+			router.GET("/serverTime", baseController.GetServerTime)
+		}
 	}
 	return r
 }
@@ -0,0 +1,15 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"go-web-mini/controller"
+)
+
+// 注册健康检查路由(存活、就绪探针)
+// 需要在注册全局中间件之前注册, 不受限流、HTTPS等中间件影响, 不需要认证
+func InitHealthRoutes(r *gin.Engine) gin.IRoutes {
+	healthController := controller.NewHealthController()
+	r.GET("/health", healthController.Health)
+	r.GET("/ready", healthController.Ready)
+	return r
+}
@@ -11,7 +11,9 @@ func InitMenuRoutes(r *gin.RouterGroup, authMiddleware *jwt.GinJWTMiddleware) gi
 	menuController := controller.NewMenuController()
 	router := r.Group("/menu")
 	// 开启jwt认证中间件
-	router.Use(authMiddleware.MiddlewareFunc())
+	router.Use(middleware.JwtMiddleware(authMiddleware))
+	// 开启只读模式中间件
+	router.Use(middleware.ReadOnlyMiddleware())
 	// 开启casbin鉴权中间件
 	router.Use(middleware.CasbinMiddleware())
 	{
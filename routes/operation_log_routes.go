@@ -3,6 +3,7 @@ package routes
 import (
 	jwt "github.com/appleboy/gin-jwt/v2"
 	"github.com/gin-gonic/gin"
+	"go-web-mini/config"
 	"go-web-mini/controller"
 	"go-web-mini/middleware"
 )
@@ -11,12 +12,15 @@ func InitOperationLogRoutes(r *gin.RouterGroup, authMiddleware *jwt.GinJWTMiddle
 	operationLogController := controller.NewOperationLogController()
 	router := r.Group("/log")
 	// 开启jwt认证中间件
-	router.Use(authMiddleware.MiddlewareFunc())
+	router.Use(middleware.JwtMiddleware(authMiddleware))
+	// 开启只读模式中间件
+	router.Use(middleware.ReadOnlyMiddleware())
 	// 开启casbin鉴权中间件
 	router.Use(middleware.CasbinMiddleware())
 	{
 		router.GET("/operation/list", operationLogController.GetOperationLogs)
-		router.DELETE("/operation/delete/batch", operationLogController.BatchDeleteOperationLogByIds)
+		router.GET("/operation/user/:userId", operationLogController.GetUserAuditTrail)
+		router.DELETE("/operation/delete/batch", middleware.RequireMinRoleSort(config.Conf.Security.SensitiveMinRoleSort), operationLogController.BatchDeleteOperationLogByIds)
 	}
 	return r
 }
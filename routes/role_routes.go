@@ -11,7 +11,9 @@ func InitRoleRoutes(r *gin.RouterGroup, authMiddleware *jwt.GinJWTMiddleware) gi
 	roleController := controller.NewRoleController()
 	router := r.Group("/role")
 	// 开启jwt认证中间件
-	router.Use(authMiddleware.MiddlewareFunc())
+	router.Use(middleware.JwtMiddleware(authMiddleware))
+	// 开启只读模式中间件
+	router.Use(middleware.ReadOnlyMiddleware())
 	// 开启casbin鉴权中间件
 	router.Use(middleware.CasbinMiddleware())
 	{
@@ -22,7 +24,12 @@ func InitRoleRoutes(r *gin.RouterGroup, authMiddleware *jwt.GinJWTMiddleware) gi
 		router.PATCH("/menus/update/:roleId", roleController.UpdateRoleMenusById)
 		router.GET("/apis/get/:roleId", roleController.GetRoleApisById)
 		router.PATCH("/apis/update/:roleId", roleController.UpdateRoleApisById)
+		router.GET("/apis/history/:roleId", roleController.GetRolePolicyChangeLogs)
 		router.DELETE("/delete/batch", roleController.BatchDeleteRoleByIds)
+		router.GET("/permissions/:roleId", roleController.GetRolePermissions)
+		router.PATCH("/status/batch", roleController.BatchSetRoleStatus)
+		router.GET("/disable/preview/:roleId", roleController.PreviewDisableRole)
+		router.GET("/users/:roleId", roleController.GetRoleUsers)
 	}
 	return r
 }
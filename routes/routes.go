@@ -6,6 +6,7 @@ import (
 	"go-web-mini/common"
 	"go-web-mini/config"
 	"go-web-mini/middleware"
+	"go-web-mini/storage"
 	"time"
 )
 
@@ -14,6 +15,11 @@ func InitRoutes() *gin.Engine {
 	//设置模式
 	gin.SetMode(config.Conf.System.Mode)
 
+	// 请求参数字符串自动去除首尾空白
+	if config.Conf.System.TrimInput {
+		common.InitTrimBinding()
+	}
+
 	// 创建带有默认中间件的路由:
 	// 日志与恢复中间件
 	r := gin.Default()
@@ -21,18 +27,35 @@ func InitRoutes() *gin.Engine {
 	// r := gin.New()
 	// r.Use(gin.Recovery())
 
+	// 启用请求ID中间件, 在健康检查路由之前注册, 所有响应都带有请求ID
+	r.Use(middleware.RequestIdMiddleware())
+
+	// 注册健康检查路由, 在全局中间件之前注册
+	InitHealthRoutes(r)
+
+	// 注册本地存储的静态文件路由(只公开上传的头像)
+	for urlPath, dir := range storage.PublicLocalDirs() {
+		r.Static(urlPath, dir)
+	}
+
 	// 启用限流中间件
 	// 默认每50毫秒填充一个令牌，最多填充200个
 	fillInterval := time.Duration(config.Conf.RateLimit.FillInterval)
 	capacity := config.Conf.RateLimit.Capacity
 	r.Use(middleware.RateLimitMiddleware(time.Millisecond*fillInterval, capacity))
 
+	// 启用HTTPS中间件
+	r.Use(middleware.HttpsMiddleware())
+
 	// 启用全局跨域中间件
 	r.Use(middleware.CORSMiddleware())
 
 	// 启用操作日志中间件
 	r.Use(middleware.OperationLogMiddleware())
 
+	// 启用集成方API Key中间件
+	r.Use(middleware.ApiKeyMiddleware())
+
 	// 初始化JWT认证中间件
 	authMiddleware, err := middleware.InitAuth()
 	if err != nil {
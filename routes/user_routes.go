@@ -3,25 +3,59 @@ package routes
 import (
 	jwt "github.com/appleboy/gin-jwt/v2"
 	"github.com/gin-gonic/gin"
+	"go-web-mini/config"
 	"go-web-mini/controller"
 	"go-web-mini/middleware"
+	"time"
 )
 
 // 注册用户路由
 func InitUserRoutes(r *gin.RouterGroup, authMiddleware *jwt.GinJWTMiddleware) gin.IRoutes {
 	userController := controller.NewUserController()
+	// 导出接口单独限流
+	exportFillInterval := time.Duration(config.Conf.RateLimit.ExportFillInterval)
+	exportRateLimit := middleware.RateLimitMiddleware(time.Millisecond*exportFillInterval, config.Conf.RateLimit.ExportCapacity)
 	router := r.Group("/user")
 	// 开启jwt认证中间件
-	router.Use(authMiddleware.MiddlewareFunc())
+	router.Use(middleware.JwtMiddleware(authMiddleware))
+	// 开启只读模式中间件
+	router.Use(middleware.ReadOnlyMiddleware())
 	// 开启casbin鉴权中间件
 	router.Use(middleware.CasbinMiddleware())
 	{
 		router.POST("/info", userController.GetUserInfo)
 		router.GET("/list", userController.GetUsers)
-		router.PUT("/changePwd", userController.ChangePwd)
+		router.PUT("/changePwd", middleware.KeyedRateLimitMiddleware("change-password"), userController.ChangePwd)
+		router.GET("/sessions", userController.GetMySessions)
+		router.DELETE("/sessions/:tokenId", userController.RevokeSession)
+		router.POST("/avatar", userController.UploadAvatar)
+		router.PATCH("/profile", userController.UpdateProfile)
+		router.POST("/2fa/enroll", userController.EnrollTwoFactor)
+		router.POST("/2fa/verify", middleware.KeyedRateLimitMiddleware("two-factor"), userController.VerifyTwoFactor)
+		router.POST("/2fa/disable", middleware.KeyedRateLimitMiddleware("two-factor"), userController.DisableTwoFactor)
 		router.POST("/create", userController.CreateUser)
 		router.PATCH("/update/:userId", userController.UpdateUserById)
+		router.PATCH("/password/reset/:userId", userController.ResetUserPassword)
+		router.PATCH("/status/:userId", userController.UpdateUserStatus)
+		router.PATCH("/batch/status", userController.BatchUpdateStatus)
 		router.DELETE("/delete/batch", userController.BatchDeleteUserByIds)
+		router.GET("/trash", userController.GetDeletedUsers)
+		router.PATCH("/restore/batch", userController.RestoreUsers)
+		router.POST("/batch/check", userController.CheckBatchPermission)
+		router.GET("/data/export/:userId", userController.ExportUserData)
+		router.POST("/merge", userController.MergeUsers)
+		router.POST("/import/validate", userController.ValidateImportUsers)
+		router.GET("/import/template", userController.GetImportTemplate)
+		router.POST("/import", userController.ImportUsers)
+		router.GET("/role/available/:userId", userController.GetUserAvailableRoles)
+		router.GET("/role/expiring", userController.GetUsersWithExpiringRoles)
+		router.PATCH("/role/expires/:userId", userController.UpdateUserRoleExpiresAt)
+		router.POST("/role/assign/batch", userController.BatchAssignRole)
+		router.GET("/stream", middleware.RequireMinRoleSort(config.Conf.Security.SensitiveMinRoleSort), exportRateLimit, userController.StreamUsers)
+		router.GET("/export", middleware.RequireMinRoleSort(config.Conf.Security.SensitiveMinRoleSort), exportRateLimit, userController.ExportUsers)
+		router.POST("/cache/warm", middleware.RequireMinRoleSort(config.Conf.Security.SensitiveMinRoleSort), userController.WarmUserInfoCache)
+		// 删除指定用户缓存仅超级管理员可用
+		router.POST("/cache/evict", middleware.RequireMinRoleSort(1), userController.EvictUserCache)
 	}
 	return r
 }
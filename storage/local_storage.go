@@ -0,0 +1,87 @@
+package storage
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// 本地文件存储
+type LocalStorage struct {
+	Dir       string // 保存目录
+	UrlPrefix string // 访问地址前缀
+}
+
+// 构造函数
+func NewLocalStorage(dir string, urlPrefix string) *LocalStorage {
+	return &LocalStorage{Dir: dir, UrlPrefix: urlPrefix}
+}
+
+// 保存文件, 先写入临时文件再重命名, 写入失败时删除临时文件, 不会留下不完整的文件
+func (ls *LocalStorage) Put(key string, r io.Reader) (string, error) {
+	key, err := cleanKey(key)
+	if err != nil {
+		return "", err
+	}
+	filename := filepath.Join(ls.Dir, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return "", err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(filename), filepath.Base(filename)+".*.tmp")
+	if err != nil {
+		return "", err
+	}
+	_, err = io.Copy(tmp, r)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), filename)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	return joinUrl(ls.UrlPrefix, key), nil
+}
+
+// 删除文件
+func (ls *LocalStorage) Delete(key string) error {
+	key, err := cleanKey(key)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(filepath.Join(ls.Dir, filepath.FromSlash(key)))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// 兼容旧配置user.avatar.dir/url-prefix的头像存储
+// 头像直接保存在旧配置的目录下(没有avatar子目录), 访问地址与之前相同, 之前上传的头像仍然可以访问和删除
+type LegacyAvatarStorage struct {
+	local *LocalStorage
+}
+
+// 构造函数
+func NewLegacyAvatarStorage(dir string, urlPrefix string) *LegacyAvatarStorage {
+	return &LegacyAvatarStorage{local: NewLocalStorage(dir, urlPrefix)}
+}
+
+// 保存文件
+func (ls *LegacyAvatarStorage) Put(key string, r io.Reader) (string, error) {
+	return ls.local.Put(legacyAvatarKey(key), r)
+}
+
+// 删除文件
+func (ls *LegacyAvatarStorage) Delete(key string) error {
+	return ls.local.Delete(legacyAvatarKey(key))
+}
+
+// 去掉key中的头像目录
+func legacyAvatarKey(key string) string {
+	return strings.TrimPrefix(strings.ReplaceAll(key, "\\", "/"), AvatarDir+"/")
+}
@@ -0,0 +1,101 @@
+package storage
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// 读取本地文件内容
+func readTestFile(t *testing.T, filename string) string {
+	t.Helper()
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("读取文件失败: %v", err)
+	}
+	return string(data)
+}
+
+func TestLocalStoragePutOverwrite(t *testing.T) {
+	dir := t.TempDir()
+	ls := NewLocalStorage(dir, "/upload/")
+
+	url, err := ls.Put("avatar/1_a.png", strings.NewReader("old"))
+	if err != nil {
+		t.Fatalf("保存文件失败: %v", err)
+	}
+	if url != "/upload/avatar/1_a.png" {
+		t.Errorf("访问地址为%s, 期望为/upload/avatar/1_a.png", url)
+	}
+	if _, err := ls.Put("avatar/1_a.png", strings.NewReader("new")); err != nil {
+		t.Fatalf("覆盖文件失败: %v", err)
+	}
+	if got := readTestFile(t, filepath.Join(dir, "avatar", "1_a.png")); got != "new" {
+		t.Errorf("文件内容为%s, 期望为new", got)
+	}
+	// 不会留下临时文件
+	files, _ := ioutil.ReadDir(filepath.Join(dir, "avatar"))
+	if len(files) != 1 {
+		t.Errorf("目录中有%d个文件, 期望为1", len(files))
+	}
+}
+
+func TestLocalStorageDelete(t *testing.T) {
+	dir := t.TempDir()
+	ls := NewLocalStorage(dir, "/upload")
+
+	if _, err := ls.Put("avatar/1_a.png", strings.NewReader("data")); err != nil {
+		t.Fatalf("保存文件失败: %v", err)
+	}
+	if err := ls.Delete("avatar/1_a.png"); err != nil {
+		t.Fatalf("删除文件失败: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "avatar", "1_a.png")); !os.IsNotExist(err) {
+		t.Errorf("文件删除后仍然存在: %v", err)
+	}
+	// 文件不存在时不返回错误
+	if err := ls.Delete("avatar/1_a.png"); err != nil {
+		t.Errorf("删除不存在的文件返回%v, 期望为nil", err)
+	}
+}
+
+func TestLocalStorageRejectsInvalidKey(t *testing.T) {
+	dir := t.TempDir()
+	ls := NewLocalStorage(filepath.Join(dir, "upload"), "/upload")
+
+	for _, key := range []string{"", "/etc/passwd", "../a.png", "avatar/../../a.png", "..\\a.png"} {
+		if _, err := ls.Put(key, strings.NewReader("data")); err == nil {
+			t.Errorf("保存文件%q成功, 期望失败", key)
+		}
+		if err := ls.Delete(key); err == nil {
+			t.Errorf("删除文件%q成功, 期望失败", key)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "a.png")); !os.IsNotExist(err) {
+		t.Errorf("文件保存到了存储目录之外: %v", err)
+	}
+}
+
+func TestLegacyAvatarStorage(t *testing.T) {
+	dir := t.TempDir()
+	ls := NewLegacyAvatarStorage(dir, "/upload/avatar")
+
+	url, err := ls.Put("avatar/1_a.png", strings.NewReader("data"))
+	if err != nil {
+		t.Fatalf("保存文件失败: %v", err)
+	}
+	if url != "/upload/avatar/1_a.png" {
+		t.Errorf("访问地址为%s, 期望与旧配置相同", url)
+	}
+	if got := readTestFile(t, filepath.Join(dir, "1_a.png")); got != "data" {
+		t.Errorf("文件内容为%s, 期望为data", got)
+	}
+	if err := ls.Delete("avatar/1_a.png"); err != nil {
+		t.Fatalf("删除文件失败: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "1_a.png")); !os.IsNotExist(err) {
+		t.Errorf("文件删除后仍然存在: %v", err)
+	}
+}
@@ -0,0 +1,35 @@
+package storage
+
+import (
+	"errors"
+	"go-web-mini/config"
+	"io"
+)
+
+// S3兼容的对象存储(如MinIO), 暂未实现上传和删除, 预留配置和接口
+type S3Storage struct {
+	Conf *config.S3StorageConfig
+}
+
+// 构造函数
+func NewS3Storage(conf *config.S3StorageConfig) *S3Storage {
+	return &S3Storage{Conf: conf}
+}
+
+var errS3NotImplemented = errors.New("S3存储暂未实现, 请使用本地存储")
+
+// 保存文件
+func (ss *S3Storage) Put(key string, r io.Reader) (string, error) {
+	if _, err := cleanKey(key); err != nil {
+		return "", err
+	}
+	return "", errS3NotImplemented
+}
+
+// 删除文件
+func (ss *S3Storage) Delete(key string) error {
+	if _, err := cleanKey(key); err != nil {
+		return err
+	}
+	return errS3NotImplemented
+}
@@ -0,0 +1,119 @@
+package storage
+
+import (
+	"fmt"
+	"go-web-mini/common"
+	"go-web-mini/config"
+	"io"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// 文件存储, key为以/分隔的相对路径(如avatar/1_xxx.png)
+type Storage interface {
+	Put(key string, r io.Reader) (url string, err error) // 保存文件(已存在时覆盖), 返回访问地址
+	Delete(key string) error                             // 删除文件, 文件不存在时不返回错误
+}
+
+// 全局文件存储
+var Store Storage
+
+// 头像在文件存储中的目录
+const AvatarDir = "avatar"
+
+// 初始化文件存储, 按storage.type选择存储后端
+// 未配置storage.local.dir时兼容旧配置user.avatar.dir/url-prefix
+func InitStorage() {
+	var store Storage
+	var err error
+	if legacy := legacyAvatarConfig(); legacy != nil {
+		common.Log.Warn("user.avatar.dir和user.avatar.url-prefix已废弃, 请改为配置storage.local")
+		store = NewLegacyAvatarStorage(legacy.Dir, legacy.UrlPrefix)
+	} else {
+		store, err = NewStorage(config.Conf.Storage)
+	}
+	if err != nil {
+		common.Log.Panicf("初始化文件存储失败: %v", err)
+	}
+	Store = store
+	common.Log.Infof("初始化文件存储完成! 存储类型: %s", config.Conf.Storage.Type)
+}
+
+// 根据配置创建文件存储
+func NewStorage(conf *config.StorageConfig) (Storage, error) {
+	if conf == nil {
+		return nil, fmt.Errorf("缺少文件存储配置")
+	}
+	switch conf.Type {
+	case "local", "":
+		if conf.Local == nil || conf.Local.Dir == "" {
+			return nil, fmt.Errorf("缺少本地存储目录配置storage.local.dir")
+		}
+		return NewLocalStorage(conf.Local.Dir, conf.Local.UrlPrefix), nil
+	case "s3":
+		if conf.S3 == nil || conf.S3.Endpoint == "" || conf.S3.Bucket == "" {
+			return nil, fmt.Errorf("缺少S3存储配置storage.s3.endpoint, storage.s3.bucket")
+		}
+		return NewS3Storage(conf.S3), nil
+	default:
+		return nil, fmt.Errorf("不支持的存储类型: %s", conf.Type)
+	}
+}
+
+// 需要注册为静态文件路由的本地目录(key为访问路径, value为本地目录)
+// 只公开头像目录, 存储目录下的其他文件不能通过静态路由访问, 访问地址前缀不以/开头(如CDN地址)时不注册
+func PublicLocalDirs() map[string]string {
+	dirs := make(map[string]string)
+	if legacy := legacyAvatarConfig(); legacy != nil {
+		if strings.HasPrefix(legacy.UrlPrefix, "/") {
+			dirs[legacy.UrlPrefix] = legacy.Dir
+		}
+		return dirs
+	}
+	conf := config.Conf.Storage
+	if conf == nil || (conf.Type != "local" && conf.Type != "") || conf.Local == nil || !strings.HasPrefix(conf.Local.UrlPrefix, "/") {
+		return dirs
+	}
+	dirs[joinUrl(conf.Local.UrlPrefix, AvatarDir)] = filepath.Join(conf.Local.Dir, AvatarDir)
+	return dirs
+}
+
+// 是否为本地存储的头像访问路径(静态文件路由下的头像文件), 用于校验用户提交的头像地址
+func IsLocalAvatarPath(avatar string) bool {
+	if !strings.HasPrefix(avatar, "/") || strings.Contains(avatar, "..") {
+		return false
+	}
+	for urlPrefix := range PublicLocalDirs() {
+		if strings.HasPrefix(avatar, strings.TrimSuffix(urlPrefix, "/")+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// 获取已废弃的头像目录配置, 只在没有配置本地存储目录时使用, 不需要兼容时返回nil
+func legacyAvatarConfig() *config.AvatarConfig {
+	if conf := config.Conf.Storage; conf != nil && (conf.Type != "local" && conf.Type != "" || conf.Local != nil && conf.Local.Dir != "") {
+		return nil
+	}
+	if config.Conf.User == nil || config.Conf.User.Avatar == nil || config.Conf.User.Avatar.Dir == "" {
+		return nil
+	}
+	return config.Conf.User.Avatar
+}
+
+// 校验并规范化key, 不能为空, 不能以/开头, 不能包含..
+func cleanKey(key string) (string, error) {
+	key = strings.ReplaceAll(key, "\\", "/")
+	cleaned := path.Clean(key)
+	if key == "" || strings.HasPrefix(key, "/") || cleaned == "." || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", fmt.Errorf("文件路径有误: %s", key)
+	}
+	return cleaned, nil
+}
+
+// 拼接访问地址
+func joinUrl(urlPrefix string, key string) string {
+	return strings.TrimSuffix(urlPrefix, "/") + "/" + key
+}
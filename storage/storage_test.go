@@ -0,0 +1,54 @@
+package storage
+
+import (
+	"go-web-mini/config"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestPublicLocalDirs(t *testing.T) {
+	defer func(storage *config.StorageConfig, user *config.UserConfig) {
+		config.Conf.Storage, config.Conf.User = storage, user
+	}(config.Conf.Storage, config.Conf.User)
+
+	// 只公开头像目录
+	config.Conf.Storage = &config.StorageConfig{Type: "local", Local: &config.LocalStorageConfig{Dir: "upload", UrlPrefix: "/upload"}}
+	config.Conf.User = &config.UserConfig{Avatar: &config.AvatarConfig{Dir: "old", UrlPrefix: "/old"}}
+	want := map[string]string{"/upload/avatar": filepath.Join("upload", "avatar")}
+	if got := PublicLocalDirs(); !reflect.DeepEqual(got, want) {
+		t.Errorf("静态文件目录为%v, 期望为%v", got, want)
+	}
+
+	// 未配置本地存储目录时兼容旧配置
+	config.Conf.Storage = nil
+	want = map[string]string{"/old": "old"}
+	if got := PublicLocalDirs(); !reflect.DeepEqual(got, want) {
+		t.Errorf("静态文件目录为%v, 期望为%v", got, want)
+	}
+
+	// CDN地址不注册静态文件路由
+	config.Conf.Storage = &config.StorageConfig{Local: &config.LocalStorageConfig{Dir: "upload", UrlPrefix: "https://cdn.example.com"}}
+	if got := PublicLocalDirs(); len(got) != 0 {
+		t.Errorf("静态文件目录为%v, 期望为空", got)
+	}
+}
+
+func TestIsLocalAvatarPath(t *testing.T) {
+	defer func(storage *config.StorageConfig) { config.Conf.Storage = storage }(config.Conf.Storage)
+	config.Conf.Storage = &config.StorageConfig{Type: "local", Local: &config.LocalStorageConfig{Dir: "upload", UrlPrefix: "/upload"}}
+
+	tests := map[string]bool{
+		"/upload/avatar/1_abc.png":     true,
+		"/upload/avatar/../secret.txt": false,
+		"/upload/other/1_abc.png":      false,
+		"/upload/avatar":               false,
+		"upload/avatar/1_abc.png":      false,
+		"https://example.com/a.png":    false,
+	}
+	for avatar, want := range tests {
+		if got := IsLocalAvatarPath(avatar); got != want {
+			t.Errorf("%s是否为本地头像路径返回%v, 期望为%v", avatar, got, want)
+		}
+	}
+}
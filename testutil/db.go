@@ -0,0 +1,105 @@
+// 测试辅助方法, 只在测试中使用
+package testutil
+
+import (
+	"fmt"
+	"go-web-mini/common"
+	"go-web-mini/config"
+	"go-web-mini/model"
+	"go-web-mini/util"
+	"go.uber.org/zap"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"gorm.io/gorm/schema"
+	"strings"
+	"testing"
+)
+
+// 初始化测试使用的内存数据库(每个测试单独一个库)和默认配置, 测试结束后关闭数据库
+func SetupDB(t *testing.T) {
+	t.Helper()
+	common.Log = zap.NewNop().Sugar()
+	config.Conf.System = &config.SystemConfig{}
+	config.Conf.Logs = &config.LogsConfig{}
+	config.Conf.Casbin = &config.CasbinConfig{}
+	config.Conf.Jwt = &config.JwtConfig{}
+	config.Conf.Cache = &config.CacheConfig{}
+	config.Conf.Security = &config.SecurityConfig{}
+	config.Conf.User = &config.UserConfig{}
+	config.Conf.Storage = &config.StorageConfig{}
+
+	dsn := fmt.Sprintf("file:%s?mode=memory&cache=shared", strings.ReplaceAll(t.Name(), "/", "_"))
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("打开测试数据库失败: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("获取测试数据库连接失败: %v", err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	common.DB = db
+	// 菜单排序字段使用了MySQL特有的字段类型(int(3) unsigned), 测试中改为sqlite支持的类型
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(&model.Menu{}); err != nil {
+		t.Fatalf("解析菜单表结构失败: %v", err)
+	}
+	sortField := stmt.Schema.LookUpField("Sort")
+	sortField.DataType = schema.Uint
+	delete(sortField.TagSettings, "TYPE")
+	// 与common.dbAutoMigrate相同
+	if err := db.SetupJoinTable(&model.User{}, "Roles", &model.UserRole{}); err != nil {
+		t.Fatalf("设置用户角色中间表失败: %v", err)
+	}
+	if err := db.SetupJoinTable(&model.Role{}, "Users", &model.UserRole{}); err != nil {
+		t.Fatalf("设置用户角色中间表失败: %v", err)
+	}
+	err = db.AutoMigrate(
+		&model.User{},
+		&model.UserRole{},
+		&model.Role{},
+		&model.Menu{},
+		&model.Api{},
+		&model.OperationLog{},
+		&model.PolicyChangeLog{},
+		&model.CacheInvalidation{},
+		&model.PasswordHistory{},
+	)
+	if err != nil {
+		t.Fatalf("迁移测试数据库失败: %v", err)
+	}
+	t.Cleanup(func() {
+		sqlDB.Close()
+	})
+}
+
+// 创建测试角色(正常状态)
+func CreateRole(t *testing.T, keyword string, sort uint) *model.Role {
+	t.Helper()
+	role := &model.Role{Name: keyword, Keyword: keyword, Status: 1, Sort: sort}
+	if err := common.DB.Create(role).Error; err != nil {
+		t.Fatalf("创建测试角色失败: %v", err)
+	}
+	return role
+}
+
+// 创建可以登录的测试用户, 手机号根据用户ID生成
+func CreateUser(t *testing.T, username string, passwd string, roles ...*model.Role) *model.User {
+	t.Helper()
+	var count int64
+	common.DB.Unscoped().Model(&model.User{}).Count(&count)
+	user := &model.User{
+		Username:     username,
+		Password:     util.GenPasswd(passwd),
+		Mobile:       fmt.Sprintf("138%08d", count+1),
+		Nickname:     new(string),
+		Introduction: new(string),
+		Status:       1,
+		Roles:        roles,
+	}
+	if err := common.DB.Create(user).Error; err != nil {
+		t.Fatalf("创建测试用户失败: %v", err)
+	}
+	return user
+}
@@ -0,0 +1,11 @@
+package util
+
+import (
+	"context"
+	"errors"
+)
+
+// 判断错误是否由请求上下文取消或超时引起(如客户端中途断开连接)
+func IsContextDone(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
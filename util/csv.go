@@ -0,0 +1,45 @@
+package util
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// CSV单元格转义, 防止公式注入
+// 以=、+、-、@、制表符或回车开头的内容会被Excel等软件当作公式执行, 在前面加上单引号作为文本显示
+func EscapeCsvCell(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// 读取CSV文件的全部行, 超过maxRows行(包括表头)或某行超过maxColumns列时返回错误
+// 去掉Excel保存CSV时写入的UTF-8 BOM
+func ReadCsvRows(r io.Reader, maxRows int, maxColumns int) ([][]string, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	rows := make([][]string, 0)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(rows) >= maxRows {
+			return nil, fmt.Errorf("csv文件不能超过%d行", maxRows)
+		}
+		if len(record) > maxColumns {
+			return nil, fmt.Errorf("csv文件不能超过%d列", maxColumns)
+		}
+		rows = append(rows, record)
+	}
+	if len(rows) > 0 && len(rows[0]) > 0 {
+		rows[0][0] = strings.TrimPrefix(rows[0][0], "\xEF\xBB\xBF")
+	}
+	return rows, nil
+}
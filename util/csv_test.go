@@ -0,0 +1,22 @@
+package util
+
+import "testing"
+
+func TestEscapeCsvCell(t *testing.T) {
+	cases := map[string]string{
+		"":                  "",
+		"alice":             "alice",
+		"=HYPERLINK(\"x\")": "'=HYPERLINK(\"x\")",
+		"+86":               "'+86",
+		"-1":                "'-1",
+		"@SUM(A1)":          "'@SUM(A1)",
+		"\tcmd":             "'\tcmd",
+		"\rcmd":             "'\rcmd",
+		"a=b":               "a=b",
+	}
+	for value, want := range cases {
+		if got := EscapeCsvCell(value); got != want {
+			t.Errorf("EscapeCsvCell(%q)为%q, 期望为%q", value, got, want)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package util
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// 导入文件列名使用的结构体tag, 例如 `import:"username"`, 没有该tag或为"-"的字段不导入
+const importTagName = "import"
+
+// 导入文件中多个值(切片字段)的分隔符
+const ImportValueSep = ","
+
+// 获取结构体的导入列名
+func ImportHeaders(obj interface{}) []string {
+	headers := make([]string, 0)
+	t := reflect.Indirect(reflect.ValueOf(obj)).Type()
+	for i := 0; i < t.NumField(); i++ {
+		if header := importHeader(t.Field(i)); header != "" {
+			headers = append(headers, header)
+		}
+	}
+	return headers
+}
+
+// 获取结构体的导入列值, 与ImportHeaders顺序一致
+func ImportValues(obj interface{}) []string {
+	values := make([]string, 0)
+	v := reflect.Indirect(reflect.ValueOf(obj))
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if importHeader(t.Field(i)) == "" {
+			continue
+		}
+		field := v.Field(i)
+		if field.Kind() == reflect.Slice {
+			items := make([]string, 0, field.Len())
+			for j := 0; j < field.Len(); j++ {
+				items = append(items, fmt.Sprint(field.Index(j).Interface()))
+			}
+			values = append(values, strings.Join(items, ImportValueSep))
+			continue
+		}
+		values = append(values, fmt.Sprint(field.Interface()))
+	}
+	return values
+}
+
+// 导入数据中解析失败的字段
+type ImportFieldError struct {
+	Field string // 结构体字段名
+	Err   error
+}
+
+// 按表头将一行导入数据写入结构体(obj必须为结构体指针)
+// 表头不区分大小写, 没有对应字段的列忽略, 支持string、整数和整数切片字段
+// 返回解析失败的字段(按结构体字段顺序), 解析失败的字段保持零值
+func ParseImportRow(headers []string, values []string, obj interface{}) []ImportFieldError {
+	columns := make(map[string]int, len(headers))
+	for i, header := range headers {
+		columns[strings.ToLower(strings.TrimSpace(header))] = i
+	}
+
+	fieldErrors := make([]ImportFieldError, 0)
+	v := reflect.ValueOf(obj).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		header := importHeader(t.Field(i))
+		if header == "" {
+			continue
+		}
+		col, ok := columns[strings.ToLower(header)]
+		if !ok || col >= len(values) {
+			continue
+		}
+		if err := setImportValue(v.Field(i), strings.TrimSpace(values[col])); err != nil {
+			fieldErrors = append(fieldErrors, ImportFieldError{Field: t.Field(i).Name, Err: fmt.Errorf("%s格式有误: %s", header, values[col])})
+		}
+	}
+	return fieldErrors
+}
+
+// 将导入的文本值写入字段
+func setImportValue(field reflect.Value, value string) error {
+	if value == "" {
+		return nil
+	}
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		field.SetUint(n)
+	case reflect.Slice:
+		items := strings.Split(value, ImportValueSep)
+		slice := reflect.MakeSlice(field.Type(), 0, len(items))
+		for _, item := range items {
+			item = strings.TrimSpace(item)
+			if item == "" {
+				continue
+			}
+			elem := reflect.New(field.Type().Elem()).Elem()
+			if err := setImportValue(elem, item); err != nil {
+				return err
+			}
+			slice = reflect.Append(slice, elem)
+		}
+		field.Set(slice)
+	default:
+		return fmt.Errorf("不支持的字段类型: %s", field.Kind())
+	}
+	return nil
+}
+
+// 获取字段的导入列名
+func importHeader(field reflect.StructField) string {
+	header := field.Tag.Get(importTagName)
+	if header == "-" {
+		return ""
+	}
+	return header
+}
@@ -0,0 +1,225 @@
+package util
+
+import (
+	"bufio"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// 常见密码集合, 默认为内置列表, 可通过LoadCommonPasswordsFromFile追加
+var (
+	commonPasswords     = newCommonPasswordSet()
+	commonPasswordsLock sync.RWMutex
+)
+
+// 内置常见密码列表
+var builtinCommonPasswords = map[string]struct{}{
+	"123456":     {},
+	"12345678":   {},
+	"123456789":  {},
+	"1234567890": {},
+	"111111":     {},
+	"000000":     {},
+	"666666":     {},
+	"888888":     {},
+	"123123":     {},
+	"abc123":     {},
+	"abcd1234":   {},
+	"password":   {},
+	"password1":  {},
+	"password1!": {},
+	"passw0rd":   {},
+	"qwerty":     {},
+	"qwerty123":  {},
+	"1qaz2wsx":   {},
+	"iloveyou":   {},
+	"admin":      {},
+	"admin123":   {},
+	"admin@123":  {},
+	"root":       {},
+	"root123":    {},
+	"welcome":    {},
+	"welcome1":   {},
+	"a123456":    {},
+	"aa123456":   {},
+}
+
+// 密码强度检测结果
+type PasswordStrength struct {
+	LengthOk   bool `json:"lengthOk"`   // 长度是否满足要求
+	HasUpper   bool `json:"hasUpper"`   // 是否包含大写字母
+	HasLower   bool `json:"hasLower"`   // 是否包含小写字母
+	HasDigit   bool `json:"hasDigit"`   // 是否包含数字
+	HasSpecial bool `json:"hasSpecial"` // 是否包含特殊字符
+	NotCommon  bool `json:"notCommon"`  // 是否不在常见密码列表中
+	Score      int  `json:"score"`      // 强度评分(0-4)
+	// 强度等级(weak/medium/strong)
+	Level string `json:"level"`
+}
+
+// 包含的字符种类数
+func (ps PasswordStrength) ClassCount() int {
+	count := 0
+	for _, ok := range []bool{ps.HasUpper, ps.HasLower, ps.HasDigit, ps.HasSpecial} {
+		if ok {
+			count++
+		}
+	}
+	return count
+}
+
+// 检测密码强度, 只返回检测结果不做校验
+// minLength 密码最小长度
+func CheckPasswordStrength(passwd string, minLength int) *PasswordStrength {
+	ps := &PasswordStrength{
+		LengthOk:  len([]rune(passwd)) >= minLength,
+		NotCommon: !IsCommonPassword(passwd),
+	}
+	for _, r := range passwd {
+		switch {
+		case unicode.IsUpper(r):
+			ps.HasUpper = true
+		case unicode.IsLower(r):
+			ps.HasLower = true
+		case unicode.IsDigit(r):
+			ps.HasDigit = true
+		default:
+			ps.HasSpecial = true
+		}
+	}
+
+	// 长度或常见密码不满足时强度为0, 否则按字符种类计分, 长度达到最小长度2倍额外加1分
+	if ps.LengthOk && ps.NotCommon {
+		ps.Score = ps.ClassCount() - 1
+		if len([]rune(passwd)) >= minLength*2 {
+			ps.Score++
+		}
+		if ps.Score > 4 {
+			ps.Score = 4
+		}
+		if ps.Score < 0 {
+			ps.Score = 0
+		}
+	}
+	switch {
+	case ps.Score >= 3:
+		ps.Level = "strong"
+	case ps.Score == 2:
+		ps.Level = "medium"
+	default:
+		ps.Level = "weak"
+	}
+	return ps
+}
+
+// 校验密码强度, 密码必须同时包含字母和数字
+// minLength 密码最小长度, minClasses 至少包含的字符种类数(大写、小写、数字、特殊字符), 0表示不额外要求
+func ValidatePasswordStrength(passwd string, minLength int, minClasses int) error {
+	ps := CheckPasswordStrength(passwd, minLength)
+	if !ps.LengthOk {
+		return fmt.Errorf("密码长度至少为%d位", minLength)
+	}
+	if !(ps.HasUpper || ps.HasLower) || !ps.HasDigit {
+		return errors.New("密码必须同时包含字母和数字")
+	}
+	if ps.ClassCount() < minClasses {
+		return fmt.Errorf("密码至少需要包含大写字母、小写字母、数字、特殊字符中的%d种", minClasses)
+	}
+	if !ps.NotCommon {
+		return errors.New("密码过于常见")
+	}
+	return nil
+}
+
+// 是否为常见密码, 不区分大小写
+func IsCommonPassword(passwd string) bool {
+	commonPasswordsLock.RLock()
+	defer commonPasswordsLock.RUnlock()
+	_, ok := commonPasswords[strings.ToLower(passwd)]
+	return ok
+}
+
+// 从文件加载常见密码列表(每行一个密码, 空行和#开头的行忽略), 与内置列表合并后替换当前集合
+// 返回文件中加载的密码数量
+func LoadCommonPasswordsFromFile(filename string) (int, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	set := newCommonPasswordSet()
+	count := 0
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		set[strings.ToLower(line)] = struct{}{}
+		count++
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+
+	commonPasswordsLock.Lock()
+	commonPasswords = set
+	commonPasswordsLock.Unlock()
+	return count, nil
+}
+
+// 以内置列表初始化常见密码集合
+func newCommonPasswordSet() map[string]struct{} {
+	set := make(map[string]struct{}, len(builtinCommonPasswords))
+	for passwd := range builtinCommonPasswords {
+		set[passwd] = struct{}{}
+	}
+	return set
+}
+
+// 随机密码使用的字符, 去掉了容易混淆的字符(0O1lI)
+var randomPasswordCharsets = []string{
+	"ABCDEFGHJKLMNPQRSTUVWXYZ",
+	"abcdefghijkmnopqrstuvwxyz",
+	"23456789",
+	"!@#$%^&*-_=+",
+}
+
+// 生成随机密码, 包含大写字母、小写字母、数字、特殊字符各至少一个
+// length 密码长度, 小于字符种类数时按字符种类数生成
+func RandomPassword(length int) (string, error) {
+	if length < len(randomPasswordCharsets) {
+		length = len(randomPasswordCharsets)
+	}
+	all := strings.Join(randomPasswordCharsets, "")
+	chars := make([]byte, length)
+	for i := range chars {
+		// 前几位依次从每种字符中选取, 保证每种字符都有
+		charset := all
+		if i < len(randomPasswordCharsets) {
+			charset = randomPasswordCharsets[i]
+		}
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(charset))))
+		if err != nil {
+			return "", err
+		}
+		chars[i] = charset[n.Int64()]
+	}
+	// 打乱顺序, 避免固定位置的字符种类
+	for i := len(chars) - 1; i > 0; i-- {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(i+1)))
+		if err != nil {
+			return "", err
+		}
+		j := n.Int64()
+		chars[i], chars[j] = chars[j], chars[i]
+	}
+	return string(chars), nil
+}
@@ -0,0 +1,27 @@
+package util
+
+import "testing"
+
+func TestValidatePasswordStrength(t *testing.T) {
+	cases := []struct {
+		passwd     string
+		minClasses int
+		valid      bool
+	}{
+		{"abcd5678", 0, true},
+		{"ABCD5678", 0, true},
+		{"abcdEFGH", 0, false},
+		{"abcdEFGH", 2, false},
+		{"12345679", 0, false},
+		{"abc5678", 0, false},
+		{"abcd5678", 3, false},
+		{"abcD5678", 3, true},
+		{"abcd1234", 0, false},
+	}
+	for _, c := range cases {
+		err := ValidatePasswordStrength(c.passwd, 8, c.minClasses)
+		if (err == nil) != c.valid {
+			t.Errorf("ValidatePasswordStrength(%q, 8, %d)返回%v, 期望有效为%v", c.passwd, c.minClasses, err, c.valid)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package util
+
+import (
+	"net/url"
+	"strings"
+)
+
+// 校验登录后的跳转地址是否允许, 防止开放重定向
+// allowlist 允许的地址前缀(如https://admin.example.com/)或主机名(如admin.example.com)
+// allowlist为空时只允许同源地址(相对路径或主机与当前请求相同)
+func IsAllowedRedirect(redirect string, requestHost string, allowlist []string) bool {
+	redirect = strings.TrimSpace(redirect)
+	if redirect == "" {
+		return false
+	}
+	u, err := url.Parse(redirect)
+	if err != nil {
+		return false
+	}
+	// 相对路径(不允许//example.com这种协议相对地址)
+	if u.Scheme == "" && u.Host == "" {
+		return strings.HasPrefix(redirect, "/") && !strings.HasPrefix(redirect, "//") && !strings.HasPrefix(redirect, "/\\")
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return false
+	}
+	if len(allowlist) == 0 {
+		return strings.EqualFold(u.Host, requestHost)
+	}
+	for _, allowed := range allowlist {
+		allowed = strings.TrimSpace(allowed)
+		if allowed == "" {
+			continue
+		}
+		if strings.Contains(allowed, "://") {
+			if redirectMatchesUrl(u, allowed) {
+				return true
+			}
+		} else if strings.EqualFold(u.Hostname(), allowed) || strings.EqualFold(u.Host, allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+// 跳转地址是否匹配允许的地址前缀
+// 协议和主机(包括端口)必须完全相同, 路径按目录前缀匹配, 避免https://admin.example.com.evil.com或/admin-evil匹配
+func redirectMatchesUrl(u *url.URL, allowed string) bool {
+	allowedUrl, err := url.Parse(allowed)
+	if err != nil || allowedUrl.Host == "" {
+		return false
+	}
+	if !strings.EqualFold(u.Scheme, allowedUrl.Scheme) || !strings.EqualFold(u.Host, allowedUrl.Host) {
+		return false
+	}
+	prefix := allowedUrl.Path
+	if prefix == "" || prefix == "/" || u.Path == prefix {
+		return true
+	}
+	if !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return strings.HasPrefix(u.Path, prefix)
+}
@@ -0,0 +1,30 @@
+package util
+
+import "testing"
+
+func TestIsAllowedRedirect(t *testing.T) {
+	allowlist := []string{"https://admin.example.com/console", "app.example.com"}
+	cases := []struct {
+		redirect string
+		want     bool
+	}{
+		{"/dashboard", true},
+		{"//evil.com", false},
+		{"https://admin.example.com/console", true},
+		{"https://admin.example.com/console/users?id=1", true},
+		{"https://ADMIN.example.com/console/users", true},
+		{"https://admin.example.com.evil.com/console", false},
+		{"https://admin.example.com@evil.com/console", false},
+		{"https://admin.example.com:8443/console", false},
+		{"http://admin.example.com/console", false},
+		{"https://admin.example.com/console-evil", false},
+		{"https://admin.example.com/other", false},
+		{"https://app.example.com/anything", true},
+		{"javascript:alert(1)", false},
+	}
+	for _, tc := range cases {
+		if got := IsAllowedRedirect(tc.redirect, "api.example.com", allowlist); got != tc.want {
+			t.Errorf("IsAllowedRedirect(%q)为%v, 期望为%v", tc.redirect, got, tc.want)
+		}
+	}
+}
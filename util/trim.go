@@ -0,0 +1,38 @@
+package util
+
+import (
+	"reflect"
+	"strings"
+)
+
+// 去除结构体中所有字符串字段首尾的空白字符(包括嵌套结构体、切片和指针)
+// 字段标签为trim:"-"时不处理(如密码)
+func TrimStrings(obj interface{}) {
+	trimValue(reflect.ValueOf(obj))
+}
+
+func trimValue(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if !v.IsNil() {
+			trimValue(v.Elem())
+		}
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < v.NumField(); i++ {
+			field := t.Field(i)
+			if field.PkgPath != "" || field.Tag.Get("trim") == "-" {
+				continue
+			}
+			trimValue(v.Field(i))
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			trimValue(v.Index(i))
+		}
+	case reflect.String:
+		if v.CanSet() {
+			v.SetString(strings.TrimSpace(v.String()))
+		}
+	}
+}
@@ -0,0 +1,8 @@
+package util
+
+import "strings"
+
+// 用户名规范化处理(去除首尾空白并转为小写), 保存和查询用户名时使用, 用户名不区分大小写
+func NormalizeUsername(username string) string {
+	return strings.ToLower(strings.TrimSpace(username))
+}
@@ -0,0 +1,213 @@
+package util
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// xlsx共享字符串
+type xlsxSharedStrings struct {
+	Items []xlsxRichText `xml:"si"`
+}
+
+// xlsx文本(普通文本或富文本)
+type xlsxRichText struct {
+	T    string `xml:"t"`
+	Runs []struct {
+		T string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (rt xlsxRichText) String() string {
+	if len(rt.Runs) == 0 {
+		return rt.T
+	}
+	var sb strings.Builder
+	for _, run := range rt.Runs {
+		sb.WriteString(run.T)
+	}
+	return sb.String()
+}
+
+// xlsx工作簿中的工作表
+type xlsxWorkbook struct {
+	Sheets []struct {
+		Name string `xml:"name,attr"`
+		Rid  string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+// xlsx工作簿关系
+type xlsxRelationships struct {
+	Items []struct {
+		Id     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+// xlsx工作表
+type xlsxWorksheet struct {
+	Rows []struct {
+		R     int `xml:"r,attr"`
+		Cells []struct {
+			R  string        `xml:"r,attr"`
+			T  string        `xml:"t,attr"`
+			V  string        `xml:"v"`
+			Is *xlsxRichText `xml:"is"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+// 读取xlsx文件的限制, 防止很小的文件通过压缩或很大的行号、列号占用大量内存
+type XlsxLimits struct {
+	MaxRows      int   // 工作表最大行数(包括表头, 按行号计算)
+	MaxColumns   int   // 每行最大列数(按列号计算)
+	MaxEntrySize int64 // 压缩包中每个xml文件解压后的最大大小, 字节
+}
+
+// 读取xlsx文件第一个工作表的全部行, 只读取单元格的值, 不处理公式和样式
+// 返回的第i个元素为工作表的第i+1行, 中间的空行同样返回(为空切片), 方便按行号提示错误
+func ReadXlsxRows(r io.ReaderAt, size int64, limits XlsxLimits) ([][]string, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, errors.New("不是有效的xlsx文件")
+	}
+	files := make(map[string]*zip.File, len(zr.File))
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+
+	var sharedStrings xlsxSharedStrings
+	if f, ok := files["xl/sharedStrings.xml"]; ok {
+		if err := decodeZipXml(f, &sharedStrings, limits.MaxEntrySize); err != nil {
+			return nil, err
+		}
+	}
+
+	f, ok := files[firstXlsxSheetPath(files, limits.MaxEntrySize)]
+	if !ok {
+		return nil, errors.New("xlsx文件中没有工作表")
+	}
+	var sheet xlsxWorksheet
+	if err := decodeZipXml(f, &sheet, limits.MaxEntrySize); err != nil {
+		return nil, err
+	}
+
+	rows := make([][]string, 0, len(sheet.Rows))
+	for _, row := range sheet.Rows {
+		// 行号缺失时按顺序排列
+		rowNum := row.R
+		if rowNum <= 0 {
+			rowNum = len(rows) + 1
+		}
+		if rowNum > limits.MaxRows {
+			return nil, fmt.Errorf("xlsx文件不能超过%d行", limits.MaxRows)
+		}
+		for len(rows) < rowNum-1 {
+			rows = append(rows, []string{})
+		}
+		values := make([]string, 0, len(row.Cells))
+		for _, cell := range row.Cells {
+			col := xlsxColumnIndex(cell.R)
+			if col < 0 {
+				col = len(values)
+			}
+			if col >= limits.MaxColumns {
+				return nil, fmt.Errorf("xlsx文件不能超过%d列", limits.MaxColumns)
+			}
+			for len(values) < col {
+				values = append(values, "")
+			}
+			value := cell.V
+			switch cell.T {
+			case "s":
+				index, err := strconv.Atoi(cell.V)
+				if err != nil || index < 0 || index >= len(sharedStrings.Items) {
+					return nil, errors.New("xlsx文件共享字符串有误")
+				}
+				value = sharedStrings.Items[index].String()
+			case "inlineStr":
+				if cell.Is != nil {
+					value = cell.Is.String()
+				}
+			}
+			if col < len(values) {
+				values[col] = value
+			} else {
+				values = append(values, value)
+			}
+		}
+		rows = append(rows, values)
+	}
+	return rows, nil
+}
+
+// 获取第一个工作表在xlsx文件中的路径, 工作簿信息有误时使用默认路径
+func firstXlsxSheetPath(files map[string]*zip.File, maxSize int64) string {
+	defaultPath := "xl/worksheets/sheet1.xml"
+	var workbook xlsxWorkbook
+	var rels xlsxRelationships
+	workbookFile, ok1 := files["xl/workbook.xml"]
+	relsFile, ok2 := files["xl/_rels/workbook.xml.rels"]
+	if !ok1 || !ok2 || decodeZipXml(workbookFile, &workbook, maxSize) != nil || decodeZipXml(relsFile, &rels, maxSize) != nil {
+		return defaultPath
+	}
+	if len(workbook.Sheets) == 0 {
+		return defaultPath
+	}
+	for _, rel := range rels.Items {
+		if rel.Id != workbook.Sheets[0].Rid {
+			continue
+		}
+		// Target为相对xl目录的路径或以/开头的绝对路径
+		if strings.HasPrefix(rel.Target, "/") {
+			return strings.TrimPrefix(rel.Target, "/")
+		}
+		return "xl/" + rel.Target
+	}
+	return defaultPath
+}
+
+// xlsx最大列数(最后一列为XFD)
+const xlsxMaxColumns = 16384
+
+// 根据单元格引用(如B3)获取列序号(从0开始), 引用有误时返回-1
+// 超过3个字母的引用返回xlsxMaxColumns, 避免计算列序号时溢出
+func xlsxColumnIndex(ref string) int {
+	col := 0
+	n := 0
+	for _, r := range ref {
+		if r < 'A' || r > 'Z' {
+			break
+		}
+		if n == 3 {
+			return xlsxMaxColumns
+		}
+		col = col*26 + int(r-'A') + 1
+		n++
+	}
+	if n == 0 {
+		return -1
+	}
+	return col - 1
+}
+
+// 解析压缩包中的xml文件, 解压后超过maxSize字节时返回错误
+func decodeZipXml(f *zip.File, v interface{}, maxSize int64) error {
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	lr := &io.LimitedReader{R: rc, N: maxSize + 1}
+	err = xml.NewDecoder(lr).Decode(v)
+	if lr.N <= 0 {
+		return fmt.Errorf("xlsx文件中的%s解压后超过%d字节", f.Name, maxSize)
+	}
+	return err
+}
@@ -0,0 +1,46 @@
+package util
+
+import (
+	"archive/zip"
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// 创建只有一个工作表的xlsx文件
+func newTestXlsx(t *testing.T, sheetData string) *bytes.Reader {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		t.Fatalf("创建测试xlsx文件失败: %v", err)
+	}
+	w.Write([]byte(`<worksheet><sheetData>` + sheetData + `</sheetData></worksheet>`))
+	if err := zw.Close(); err != nil {
+		t.Fatalf("创建测试xlsx文件失败: %v", err)
+	}
+	return bytes.NewReader(buf.Bytes())
+}
+
+func TestReadXlsxRowsLimits(t *testing.T) {
+	limits := XlsxLimits{MaxRows: 10, MaxColumns: 5, MaxEntrySize: 1024}
+	cases := map[string]string{
+		"行号过大":  `<row r="2000000000"><c r="A2000000000" t="inlineStr"><is><t>a</t></is></c></row>`,
+		"列号过大":  `<row r="1"><c r="ZZZZZZ1" t="inlineStr"><is><t>a</t></is></c></row>`,
+		"列数过多":  `<row r="1"><c r="F1" t="inlineStr"><is><t>a</t></is></c></row>`,
+		"解压后过大": `<row r="1"><c r="A1" t="inlineStr"><is><t>` + strings.Repeat("a", 2048) + `</t></is></c></row>`,
+	}
+	for name, sheetData := range cases {
+		r := newTestXlsx(t, sheetData)
+		if rows, err := ReadXlsxRows(r, r.Size(), limits); err == nil {
+			t.Errorf("%s: 返回%d行, 期望返回错误", name, len(rows))
+		}
+	}
+
+	r := newTestXlsx(t, `<row r="1"><c r="A1" t="inlineStr"><is><t>username</t></is></c></row><row r="3"><c r="B3" t="inlineStr"><is><t>bob</t></is></c></row>`)
+	rows, err := ReadXlsxRows(r, r.Size(), limits)
+	if err != nil || len(rows) != 3 || len(rows[1]) != 0 || len(rows[2]) != 2 || rows[2][1] != "bob" {
+		t.Errorf("读取xlsx文件返回%q, %v", rows, err)
+	}
+}
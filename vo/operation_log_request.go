@@ -6,6 +6,7 @@ type OperationLogListRequest struct {
 	Ip       string `json:"ip" form:"ip"`
 	Path     string `json:"path" form:"path"`
 	Status   int    `json:"status" form:"status"`
+	Action   string `json:"action" form:"action"`
 	PageNum  int    `json:"pageNum" form:"pageNum"`
 	PageSize int    `json:"pageSize" form:"pageSize"`
 }
@@ -14,3 +15,9 @@ type OperationLogListRequest struct {
 type DeleteOperationLogRequest struct {
 	OperationLogIds []uint `json:"operationLogIds" form:"operationLogIds"`
 }
+
+// 用户操作记录请求结构体
+type UserAuditTrailRequest struct {
+	PageNum  int `json:"pageNum" form:"pageNum"`
+	PageSize int `json:"pageSize" form:"pageSize"`
+}
@@ -7,6 +7,8 @@ type CreateRoleRequest struct {
 	Desc    string `json:"desc" form:"desc" validate:"min=0,max=100"`
 	Status  uint   `json:"status" form:"status" validate:"oneof=1 2"`
 	Sort    uint   `json:"sort" form:"sort" validate:"gte=1,lte=999"`
+	// 父角色ID, 为空表示不继承其他角色
+	ParentRoleId *uint `json:"parentRoleId" form:"parentRoleId"`
 }
 
 // 获取用户角色结构体
@@ -16,6 +18,8 @@ type RoleListRequest struct {
 	Status   uint   `json:"status" form:"status"`
 	PageNum  uint   `json:"pageNum" form:"pageNum"`
 	PageSize uint   `json:"pageSize" form:"pageSize"`
+	// 是否返回角色的权限概要(菜单和接口权限数量)
+	WithSummary bool `json:"withSummary" form:"withSummary"`
 }
 
 // 批量删除角色结构体
@@ -32,3 +36,21 @@ type UpdateRoleMenusRequest struct {
 type UpdateRoleApisRequest struct {
 	ApiIds []uint `json:"apiIds" form:"apiIds"`
 }
+
+// 获取角色权限接口变更记录
+type RolePolicyChangeLogRequest struct {
+	PageNum  int `json:"pageNum" form:"pageNum"`
+	PageSize int `json:"pageSize" form:"pageSize"`
+}
+
+// 批量修改角色状态结构体
+type BatchSetRoleStatusRequest struct {
+	RoleIds []uint `json:"roleIds" form:"roleIds" validate:"required,min=1"`
+	Status  uint   `json:"status" form:"status" validate:"oneof=1 2"`
+}
+
+// 获取角色的全部有效权限结构体
+type RolePermissionsRequest struct {
+	// 是否同时返回角色的权限概要(菜单和接口权限数量)
+	WithSummary bool `json:"withSummary" form:"withSummary"`
+}
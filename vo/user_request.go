@@ -1,21 +1,41 @@
 package vo
 
+import "time"
+
 // 用户登录结构体
 type RegisterAndLoginRequest struct {
 	Username string `form:"username" json:"username" binding:"required"`
-	Password string `form:"password" json:"password" binding:"required"`
+	Password string `form:"password" json:"password" binding:"required" trim:"-"`
+}
+
+// 两步验证登录(登录第二步)结构体
+type TwoFactorLoginRequest struct {
+	Challenge string `form:"challenge" json:"challenge" binding:"required"`
+	Code      string `form:"code" json:"code" binding:"required"`
 }
 
 // 创建用户结构体
+// import为导入用户时的列名, 导入模板的表头由此生成
+// 密码为RSA加密后的值(导入时为明文), 不为空时解密后按password_strength规则校验强度
+// 版本号只在更新用户时使用, 为获取用户列表时返回的版本号
 type CreateUserRequest struct {
-	Username     string `form:"username" json:"username" validate:"required,min=2,max=20"`
-	Password     string `form:"password" json:"password"`
-	Mobile       string `form:"mobile" json:"mobile" validate:"required,checkMobile"`
+	Username     string `form:"username" json:"username" import:"username" validate:"required,min=2,max=20"`
+	Password     string `form:"password" json:"password" import:"password" trim:"-"`
+	Mobile       string `form:"mobile" json:"mobile" import:"mobile" validate:"required,checkMobile"`
 	Avatar       string `form:"avatar" json:"avatar"`
+	Nickname     string `form:"nickname" json:"nickname" import:"nickname" validate:"min=0,max=20"`
+	Introduction string `form:"introduction" json:"introduction" import:"introduction" validate:"min=0,max=255"`
+	Status       uint   `form:"status" json:"status" import:"status" validate:"oneof=1 2"`
+	RoleIds      []uint `form:"roleIds" json:"roleIds" import:"roleIds" validate:"required"`
+	Version      uint   `form:"version" json:"version"`
+}
+
+// 更新个人资料结构体(只能修改自己的昵称、头像、手机号和简介)
+type UpdateProfileRequest struct {
+	Mobile       string `form:"mobile" json:"mobile" validate:"required,checkMobile"`
+	Avatar       string `form:"avatar" json:"avatar" validate:"max=255"`
 	Nickname     string `form:"nickname" json:"nickname" validate:"min=0,max=20"`
 	Introduction string `form:"introduction" json:"introduction" validate:"min=0,max=255"`
-	Status       uint   `form:"status" json:"status" validate:"oneof=1 2"`
-	RoleIds      []uint `form:"roleIds" json:"roleIds" validate:"required"`
 }
 
 // 获取用户列表结构体
@@ -26,15 +46,119 @@ type UserListRequest struct {
 	Status   uint   `json:"status" form:"status" `
 	PageNum  uint   `json:"pageNum" form:"pageNum"`
 	PageSize uint   `json:"pageSize" form:"pageSize"`
+	// 是否登录过(为空不过滤), 用于查找创建后从未登录的用户
+	HasLoggedIn *bool `json:"hasLoggedIn" form:"hasLoggedIn"`
+	// 排序字段和排序方式(asc/desc), 为空时按创建时间倒序
+	OrderBy string `json:"orderBy" form:"orderBy" validate:"omitempty,oneof=username nickname mobile status created_at last_login_at"`
+	Order   string `json:"order" form:"order" validate:"omitempty,oneof=asc desc"`
+	// 创建时间范围(格式: 2006-01-02, 包含开始和结束日期), 只提供一个时只按该边界过滤
+	StartTime string `json:"startTime" form:"startTime" validate:"omitempty,datetime=2006-01-02"`
+	EndTime   string `json:"endTime" form:"endTime" validate:"omitempty,datetime=2006-01-02"`
+	// 按拥有的角色过滤, 角色名称为模糊查询
+	RoleId   uint   `json:"roleId" form:"roleId"`
+	RoleName string `json:"roleName" form:"roleName"`
+}
+
+// 是否提供了查询条件(不包括分页参数)
+func (req UserListRequest) HasFilter() bool {
+	return req.Username != "" || req.Mobile != "" || req.Nickname != "" || req.Status != 0 || req.HasLoggedIn != nil ||
+		req.StartTime != "" || req.EndTime != "" || req.RoleId != 0 || req.RoleName != ""
 }
 
 // 批量删除用户结构体
 type DeleteUserRequest struct {
-	UserIds []uint `json:"userIds" form:"userIds"`
+	UserIds []uint `json:"userIds" form:"userIds" validate:"required,min=1"`
+}
+
+// 批量恢复已删除用户结构体
+type RestoreUserRequest struct {
+	UserIds []uint `json:"userIds" form:"userIds" validate:"required,min=1"`
+}
+
+// 更新用户状态结构体
+type UpdateUserStatusRequest struct {
+	Status uint `json:"status" form:"status" validate:"required,oneof=1 2"`
+}
+
+// 批量更新用户状态结构体
+type BatchUpdateUserStatusRequest struct {
+	UserIds []uint `json:"userIds" form:"userIds" validate:"required,min=1,max=500"`
+	Status  uint   `json:"status" form:"status" validate:"required,oneof=1 2"`
+}
+
+// 重置用户密码结构体
+// 密码为RSA加密后的值, 为空时使用默认密码
+type ResetUserPasswordRequest struct {
+	Password string `json:"password" form:"password" trim:"-"`
 }
 
 // 更新密码结构体
 type ChangePwdRequest struct {
-	OldPassword string `json:"oldPassword" form:"oldPassword" validate:"required"`
-	NewPassword string `json:"newPassword" form:"newPassword" validate:"required"`
+	OldPassword string `json:"oldPassword" form:"oldPassword" validate:"required" trim:"-"`
+	// 新密码为RSA加密后的值, 解密后按password_strength规则校验强度
+	NewPassword string `json:"newPassword" form:"newPassword" validate:"required" trim:"-"`
+}
+
+// 预热用户信息缓存结构体
+type WarmUserCacheRequest struct {
+	// 为空时预热所有正常状态的用户, 最多指定cache.max-request-users个用户
+	UserIds []uint `json:"userIds" form:"userIds" validate:"maxCacheUsers"`
+}
+
+// 获取角色即将过期的用户列表结构体
+type ExpiringUserRoleListRequest struct {
+	Days uint `json:"days" form:"days"`
+}
+
+// 更新用户角色过期时间结构体
+type UpdateUserRoleExpiresRequest struct {
+	RoleId    uint       `json:"roleId" form:"roleId" validate:"required"`
+	ExpiresAt *time.Time `json:"expiresAt" form:"expiresAt"`
+}
+
+// 批量分配角色结构体
+type BatchAssignRoleRequest struct {
+	RoleId  uint   `json:"roleId" form:"roleId" validate:"required"`
+	UserIds []uint `json:"userIds" form:"userIds" validate:"required,min=1,max=500"`
+}
+
+// 合并用户结构体
+type MergeUserRequest struct {
+	SourceId uint `json:"sourceId" form:"sourceId" validate:"required"`
+	TargetId uint `json:"targetId" form:"targetId" validate:"required"`
+}
+
+// 密码强度检测结构体
+type PasswordStrengthRequest struct {
+	Password string `json:"password" form:"password" validate:"required" trim:"-"`
+}
+
+// 校验导入用户数据结构体
+type ValidateImportUsersRequest struct {
+	Users []*CreateUserRequest `json:"users" form:"users" validate:"required,min=1,max=500"`
+}
+
+// 删除用户信息缓存结构体
+type EvictUserCacheRequest struct {
+	Usernames []string `json:"usernames" form:"usernames" validate:"required,min=1,maxCacheUsers"`
+}
+
+// 首次安装初始化结构体
+type InitialSetupRequest struct {
+	Username string `json:"username" form:"username" validate:"required,min=2,max=20"`
+	Password string `json:"password" form:"password" validate:"required" trim:"-"`
+	Mobile   string `json:"mobile" form:"mobile" validate:"required,checkMobile"`
+	Nickname string `json:"nickname" form:"nickname" validate:"min=0,max=20"`
+}
+
+// 批量操作权限校验结构体
+type BatchPermissionCheckRequest struct {
+	// 批量操作: user-delete(删除用户), user-restore(恢复用户), user-disable(禁用用户), user-enable(启用用户), role-delete(删除角色), role-disable(禁用角色), role-enable(启用角色)
+	Operation string `json:"operation" form:"operation" validate:"required,oneof=user-delete user-restore user-disable user-enable role-delete role-disable role-enable"`
+	Ids       []uint `json:"ids" form:"ids" validate:"required,min=1"`
+}
+
+// 开启或关闭两步验证结构体
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" form:"code" validate:"required,len=6,numeric"`
 }